@@ -10,6 +10,19 @@ def get_client(api_key):
 def get_instructor_client(api_key):
     return instructor.from_anthropic(Anthropic(api_key=api_key))
 
+# the cheapest model, the credentials test asks it for a single token
+CREDENTIALS_TEST_MODEL = "claude-3-haiku-20240307"
+
+def test_credentials(api_keys:dict):
+    """
+    Sends a minimal message, which fails when the key is rejected.
+    """
+    get_client(api_keys["anthropic"]).messages.create(
+        model=CREDENTIALS_TEST_MODEL,
+        messages=[{"role": "user", "content": "ping"}],
+        max_tokens=1,
+    )
+
 def chat_completion(api_keys:dict, system_prompt, model, messages, temperature, timeout, max_tokens, response_format, tools):
     if response_format is None or response_format == {}:
         client = get_client(api_keys["anthropic"])
@@ -43,3 +56,27 @@ def chat_completion(api_keys:dict, system_prompt, model, messages, temperature,
         llm_response = json.dumps(llm_response)
 
     return llm_response
+
+def chat_completion_stream(api_keys:dict, system_prompt, model, messages, temperature, timeout, max_tokens, response_format, tools):
+    # structured outputs are parsed by instructor on the full response, so they are not streamed
+    if response_format is not None and response_format != {}:
+        llm_response = json.loads(chat_completion(api_keys, system_prompt, model, messages, temperature, timeout, max_tokens, response_format, tools))
+        yield {"type": "delta", "content": llm_response["content"][0]["text"]}
+        yield {"type": "completion", "response": llm_response}
+        return
+
+    client = get_client(api_keys["anthropic"])
+    with client.messages.stream(
+        model=model,
+        system=system_prompt if system_prompt else NOT_GIVEN,
+        messages=messages,
+        temperature=temperature,
+        timeout=timeout,
+        max_tokens=max_tokens,
+        tools=tools if tools else NOT_GIVEN
+    ) as stream:
+        for text in stream.text_stream:
+            yield {"type": "delta", "content": text}
+        final_message = stream.get_final_message()
+
+    yield {"type": "completion", "response": json.loads(final_message.model_dump_json())}
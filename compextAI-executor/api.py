@@ -1,10 +1,11 @@
 import fastapi
 import uvicorn
 import os
-from fastapi.responses import JSONResponse
+from fastapi.responses import JSONResponse, StreamingResponse
 from pydantic import BaseModel
 import openai_models as openai
 import anthropic_models as anthropic
+import google_models as google
 import json
 import litellm_base as litellm
 app = fastapi.FastAPI()
@@ -29,6 +30,19 @@ class ChatCompletionRequest(BaseModel):
     system_prompt: str = None
     tools: list[dict] = None
 
+class GenerateContentRequest(BaseModel):
+    """
+    Request body for the gemini endpoints, the contents are already in the
+    gemini format.
+    """
+    api_keys: dict
+    model: str
+    contents: list[dict]
+    system_instruction: dict = None
+    generation_config: dict = None
+    tools: list[dict] = None
+    timeout: int = 600
+
 @app.post("/chatcompletion/openai")
 def chat_completion_openai(request: ChatCompletionRequest):
     try:
@@ -56,6 +70,76 @@ def chat_completion_litellm(request: ChatCompletionRequest):
         print(e)
         return JSONResponse(status_code=500, content={"error": str(e)})
 
+@app.post("/chatcompletion/google")
+def chat_completion_google(request: GenerateContentRequest):
+    try:
+        status_code, response = google.generate_content(request.api_keys, request.model, request.contents, request.system_instruction, request.generation_config, request.tools, request.timeout)
+        return JSONResponse(status_code=status_code, content=response)
+    except Exception as e:
+        print(e)
+        return JSONResponse(status_code=500, content={"error": str(e)})
+
+class TestCredentialsRequest(BaseModel):
+    """
+    Request body for the credentials test, the api keys only hold the
+    credentials of the provider.
+    """
+    provider: str
+    api_keys: dict
+
+credentials_tests = {
+    "openai": openai.test_credentials,
+    "azure": openai.test_azure_credentials,
+    "anthropic": anthropic.test_credentials,
+    "google": google.test_credentials,
+}
+
+@app.post("/credentials/test")
+def test_credentials(request: TestCredentialsRequest):
+    test = credentials_tests.get(request.provider)
+    if test is None:
+        return JSONResponse(status_code=400, content={"error": f"unknown provider: {request.provider}"})
+    try:
+        test(request.api_keys)
+        return JSONResponse(status_code=200, content={"valid": True})
+    except Exception as e:
+        # the vendor rejected the credentials
+        print(e)
+        return JSONResponse(status_code=200, content={"valid": False, "error": str(e)})
+
+def stream_events(events):
+    """
+    Formats the events of a streaming chat completion as server-sent events.
+    Errors raised while streaming are sent as an error event, since the status
+    code has already been sent.
+    """
+    try:
+        for event in events:
+            yield f"data: {json.dumps(event)}\n\n"
+    except Exception as e:
+        print(e)
+        yield f"data: {json.dumps({'type': 'error', 'error': str(e)})}\n\n"
+
+@app.post("/chatcompletion/openai/stream")
+def chat_completion_openai_stream(request: ChatCompletionRequest):
+    events = openai.chat_completion_stream(request.api_keys, request.model, request.messages, request.temperature, request.timeout, request.max_completion_tokens, request.response_format, request.tools)
+    return StreamingResponse(stream_events(events), media_type="text/event-stream")
+
+@app.post("/chatcompletion/anthropic/stream")
+def chat_completion_anthropic_stream(request: ChatCompletionRequest):
+    events = anthropic.chat_completion_stream(request.api_keys, request.system_prompt, request.model, request.messages, request.temperature, request.timeout, request.max_tokens, request.response_format, request.tools)
+    return StreamingResponse(stream_events(events), media_type="text/event-stream")
+
+@app.post("/chatcompletion/litellm/stream")
+def chat_completion_litellm_stream(request: ChatCompletionRequest):
+    events = litellm.chat_completion_stream(request.api_keys, request.model, request.messages, request.temperature, request.timeout, request.max_completion_tokens, request.response_format, request.tools)
+    return StreamingResponse(stream_events(events), media_type="text/event-stream")
+
+@app.post("/chatcompletion/google/stream")
+def chat_completion_google_stream(request: GenerateContentRequest):
+    events = google.generate_content_stream(request.api_keys, request.model, request.contents, request.system_instruction, request.generation_config, request.tools, request.timeout)
+    return StreamingResponse(stream_events(events), media_type="text/event-stream")
+
 if __name__ == "__main__":
     port = 8889
     if os.getenv("SERVER_PORT"):
@@ -0,0 +1,94 @@
+import json
+import os
+import requests
+from google.oauth2 import service_account
+from google.auth.transport.requests import Request
+
+DEFAULT_VERTEX_LOCATION = "us-central1"
+VERTEX_SCOPES = ["https://www.googleapis.com/auth/cloud-platform"]
+
+def get_credentials(service_account_creds):
+    if isinstance(service_account_creds, str):
+        service_account_creds = json.loads(service_account_creds)
+    if not service_account_creds:
+        raise ValueError("google service account credentials are not set")
+    credentials = service_account.Credentials.from_service_account_info(service_account_creds, scopes=VERTEX_SCOPES)
+    credentials.refresh(Request())
+    return credentials
+
+def get_model_url(credentials, model, method):
+    location = os.getenv("GOOGLE_VERTEX_LOCATION", DEFAULT_VERTEX_LOCATION)
+    return f"https://{location}-aiplatform.googleapis.com/v1/projects/{credentials.project_id}/locations/{location}/publishers/google/models/{model}:{method}"
+
+def get_request_body(contents, system_instruction, generation_config, tools):
+    body = {"contents": contents}
+    if system_instruction:
+        body["systemInstruction"] = system_instruction
+    if generation_config:
+        body["generationConfig"] = generation_config
+    if tools:
+        body["tools"] = tools
+    return body
+
+def test_credentials(api_keys:dict):
+    """
+    Gets an access token for the service account, which fails when its key
+    is rejected.
+    """
+    get_credentials(api_keys.get("google_service_account_creds"))
+
+def generate_content(api_keys:dict, model, contents, system_instruction, generation_config, tools, timeout):
+    """
+    Sends the request, already in the gemini format, to vertex ai and returns
+    the generate content response as is.
+    """
+    credentials = get_credentials(api_keys.get("google_service_account_creds"))
+    response = requests.post(
+        get_model_url(credentials, model, "generateContent"),
+        headers={"Authorization": f"Bearer {credentials.token}"},
+        json=get_request_body(contents, system_instruction, generation_config, tools),
+        timeout=timeout,
+    )
+    return response.status_code, response.json()
+
+def generate_content_stream(api_keys:dict, model, contents, system_instruction, generation_config, tools, timeout):
+    credentials = get_credentials(api_keys.get("google_service_account_creds"))
+    response = requests.post(
+        get_model_url(credentials, model, "streamGenerateContent") + "?alt=sse",
+        headers={"Authorization": f"Bearer {credentials.token}"},
+        json=get_request_body(contents, system_instruction, generation_config, tools),
+        timeout=timeout,
+        stream=True,
+    )
+    if response.status_code != 200:
+        raise Exception(f"vertex ai responded with status code {response.status_code}: {response.text}")
+
+    # assemble the chunks into a single generate content response
+    parts = []
+    final_response = {}
+    for line in response.iter_lines(decode_unicode=True):
+        if not line or not line.startswith("data:"):
+            continue
+        chunk = json.loads(line[len("data:"):].strip())
+        for candidate in chunk.get("candidates", [])[:1]:
+            for part in candidate.get("content", {}).get("parts", []):
+                if "text" in part:
+                    yield {"type": "delta", "content": part["text"]}
+                    if parts and "text" in parts[-1]:
+                        parts[-1]["text"] += part["text"]
+                        continue
+                parts.append(part)
+            if "finishReason" in candidate:
+                final_response["finishReason"] = candidate["finishReason"]
+        if "usageMetadata" in chunk:
+            final_response["usageMetadata"] = chunk["usageMetadata"]
+        if "modelVersion" in chunk:
+            final_response["modelVersion"] = chunk["modelVersion"]
+        if "responseId" in chunk:
+            final_response["responseId"] = chunk["responseId"]
+
+    candidate = {"content": {"role": "model", "parts": parts}}
+    if "finishReason" in final_response:
+        candidate["finishReason"] = final_response.pop("finishReason")
+    final_response["candidates"] = [candidate]
+    yield {"type": "completion", "response": final_response}
@@ -14,6 +14,22 @@ def get_instructor_client(api_key):
         api_key=api_key
     ))
 
+# the api version of the azure openai resources, the same as the one of litellm
+AZURE_API_VERSION = "2024-08-01-preview"
+
+def test_credentials(api_keys:dict):
+    """
+    Lists the models, which fails when the key is rejected.
+    """
+    get_client(api_keys["openai"]).models.list()
+
+def test_azure_credentials(api_keys:dict):
+    openai.AzureOpenAI(
+        api_key=api_keys["azure"],
+        azure_endpoint=api_keys["azure_endpoint"],
+        api_version=AZURE_API_VERSION,
+    ).models.list()
+
 def chat_completion(api_keys:dict, model:str, messages:list, temperature:float, timeout:int, max_completion_tokens:int, response_format:dict, tools:list[dict]):
     if response_format is None or response_format == {}:
         client = get_client(api_keys["openai"])
@@ -45,3 +61,58 @@ def chat_completion(api_keys:dict, model:str, messages:list, temperature:float,
         llm_response["choices"][0]["message"]["content"] = answer.model_dump_json()
         llm_response = json.dumps(llm_response)
     return llm_response
+
+def chat_completion_stream(api_keys:dict, model:str, messages:list, temperature:float, timeout:int, max_completion_tokens:int, response_format:dict, tools:list[dict]):
+    # structured outputs are parsed by instructor on the full response, so they are not streamed
+    if response_format is not None and response_format != {}:
+        llm_response = json.loads(chat_completion(api_keys, model, messages, temperature, timeout, max_completion_tokens, response_format, tools))
+        yield {"type": "delta", "content": llm_response["choices"][0]["message"]["content"]}
+        yield {"type": "completion", "response": llm_response}
+        return
+
+    client = get_client(api_keys["openai"])
+    stream = client.chat.completions.create(
+        model=model,
+        messages=messages,
+        temperature=temperature,
+        timeout=timeout,
+        max_completion_tokens=max_completion_tokens,
+        tools=tools if tools else NOT_GIVEN,
+        stream=True,
+        stream_options={"include_usage": True}
+    )
+
+    llm_response = {"id": None, "object": "chat.completion", "created": None, "model": model, "usage": None}
+    role = "assistant"
+    content = ""
+    finish_reason = None
+    tool_calls = {}
+    for chunk in stream:
+        llm_response["id"] = chunk.id
+        llm_response["created"] = chunk.created
+        llm_response["model"] = chunk.model
+        if chunk.usage is not None:
+            llm_response["usage"] = chunk.usage.model_dump()
+        if not chunk.choices:
+            continue
+        choice = chunk.choices[0]
+        if choice.finish_reason is not None:
+            finish_reason = choice.finish_reason
+        if choice.delta.role:
+            role = choice.delta.role
+        if choice.delta.content:
+            content += choice.delta.content
+            yield {"type": "delta", "content": choice.delta.content}
+        for tool_call in choice.delta.tool_calls or []:
+            assembled = tool_calls.setdefault(tool_call.index, {"id": None, "type": "function", "function": {"name": "", "arguments": ""}})
+            if tool_call.id:
+                assembled["id"] = tool_call.id
+            if tool_call.function is not None:
+                assembled["function"]["name"] += tool_call.function.name or ""
+                assembled["function"]["arguments"] += tool_call.function.arguments or ""
+
+    message = {"role": role, "content": content}
+    if tool_calls:
+        message["tool_calls"] = [tool_calls[index] for index in sorted(tool_calls)]
+    llm_response["choices"] = [{"index": 0, "message": message, "finish_reason": finish_reason}]
+    yield {"type": "completion", "response": llm_response}
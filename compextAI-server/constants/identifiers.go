@@ -2,6 +2,8 @@ package constants
 
 import "fmt"
 
+const ADMIN_USERNAME = "admin"
+
 var (
 	THREAD_IDENTIFIER_FOR_NULL_THREAD = fmt.Sprintf("%snull", THREAD_ID_PREFIX)
 )
@@ -7,4 +7,26 @@ const (
 	THREAD_EXECUTION_PARAMS_ID_PREFIX          = "compext_thread_execution_params_"
 	THREAD_EXECUTION_PARAMS_TEMPLATE_ID_PREFIX = "compext_thread_execution_params_template_"
 	PROJECT_ID_PREFIX                          = "compext_project_"
+	EXECUTION_JOB_ID_PREFIX                    = "compext_execution_job_"
+	PROJECT_WEBHOOK_ID_PREFIX                  = "compext_webhook_"
+	WEBHOOK_DELIVERY_ID_PREFIX                 = "compext_webhook_delivery_"
+	IDEMPOTENCY_KEY_ID_PREFIX                  = "compext_idempotency_key_"
+	PROJECT_TOOL_ID_PREFIX                     = "compext_tool_"
+	PROJECT_ENDPOINT_ID_PREFIX                 = "compext_endpoint_"
+	PROJECT_BUDGET_ID_PREFIX                   = "compext_budget_"
+	RATE_LIMIT_ID_PREFIX                       = "compext_rate_limit_"
+	SESSION_ID_PREFIX                          = "compext_session_"
+	API_KEY_ID_PREFIX                          = "compext_api_key_"
+	ORGANIZATION_ID_PREFIX                     = "compext_organization_"
+	ORGANIZATION_MEMBER_ID_PREFIX              = "compext_organization_member_"
+	PROJECT_MEMBER_ID_PREFIX                   = "compext_project_member_"
+	INVITATION_ID_PREFIX                       = "compext_invitation_"
+	PROVIDER_CREDENTIAL_ID_PREFIX              = "compext_credential_"
+)
+
+const (
+	// the session tokens are told apart from the api keys by their prefix
+	SESSION_TOKEN_PREFIX    = "compext_st_"
+	API_KEY_PREFIX          = "compext_ak_"
+	INVITATION_TOKEN_PREFIX = "compext_inv_"
 )
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_API_KEY_NAME = "default"
+	// the characters of the key kept to tell the keys apart, after its prefix
+	API_KEY_VISIBLE_CHARACTERS = 6
+)
+
+func generateAPIKey() (string, error) {
+	secret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s", constants.API_KEY_PREFIX, secret), nil
+}
+
+func getAPIKeyPrefix(key string) string {
+	return key[:min(len(key), len(constants.API_KEY_PREFIX)+API_KEY_VISIBLE_CHARACTERS)]
+}
+
+// CreateAPIKey generates a key for the user. The key is only returned here,
+// the database keeps its hash.
+func CreateAPIKey(db *gorm.DB, request *CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	apiKey, err := models.CreateAPIKey(db, &models.APIKey{
+		UserID:    request.UserID,
+		Name:      request.Name,
+		KeyHash:   utils.HashToken(key),
+		KeyPrefix: getAPIKeyPrefix(key),
+		ProjectID: request.ProjectID,
+		Scope:     request.Scope,
+		ExpiresAt: request.ExpiresAt,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return apiKey, key, nil
+}
+
+// RotateAPIKey replaces the key with a new one with the same name, project and
+// scope. The old key keeps working for the grace period, so that the clients
+// can switch over.
+func RotateAPIKey(db *gorm.DB, apiKey *models.APIKey, gracePeriod time.Duration) (*models.APIKey, string, error) {
+	var newAPIKey *models.APIKey
+	var key string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		newAPIKey, key, err = CreateAPIKey(tx, &CreateAPIKeyRequest{
+			UserID:    apiKey.UserID,
+			Name:      apiKey.Name,
+			ProjectID: apiKey.ProjectID,
+			Scope:     apiKey.Scope,
+			ExpiresAt: apiKey.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if gracePeriod <= 0 {
+			return models.RevokeAPIKey(tx, apiKey.Identifier)
+		}
+		return models.ExpireAPIKey(tx, apiKey.Identifier, time.Now().Add(gracePeriod))
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return newAPIKey, key, nil
+}
+
+// MigrateLegacyAPITokens moves the api tokens of the users table to api keys,
+// it runs with the migrations. The tokens keep working as keys named default.
+func MigrateLegacyAPITokens(db *gorm.DB) error {
+	users, err := models.GetUsersWithLegacyAPITokens(db)
+	if err != nil {
+		return fmt.Errorf("error getting users with api tokens: %w", err)
+	}
+
+	for _, user := range users {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if _, err := models.CreateAPIKey(tx, &models.APIKey{
+				UserID:    user.ID,
+				Name:      DEFAULT_API_KEY_NAME,
+				KeyHash:   utils.HashToken(*user.APIToken),
+				KeyPrefix: getAPIKeyPrefix(*user.APIToken),
+				Scope:     models.APIKeyScope_EXECUTE,
+			}); err != nil {
+				return err
+			}
+			return models.ClearUserAPIToken(tx, user.ID)
+		}); err != nil {
+			return fmt.Errorf("error migrating api token of user %s: %w", user.Username, err)
+		}
+	}
+
+	if len(users) > 0 {
+		logger.GetLogger().Infof("Moved the api tokens of %d users to api keys", len(users))
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package controllers
+
+import "time"
+
+type CreateAPIKeyRequest struct {
+	UserID    uint
+	Name      string
+	ProjectID string
+	Scope     string
+	ExpiresAt *time.Time
+}
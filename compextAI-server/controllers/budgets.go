@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const BUDGET_THRESHOLD_REACHED_EVENT = "budget.threshold_reached"
+
+// BudgetExceededError is returned by ExecuteThread when a budget of the
+// project is exhausted, the handlers respond with 429 Too Many Requests
+type BudgetExceededError struct {
+	Budget   *models.ProjectBudget
+	Spending float64
+	// the end of the budget period, when new executions are accepted again
+	ResetsAt time.Time
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("the %s %s budget of the project is exhausted: %s of %s spent, new executions are refused until %s",
+		e.Budget.Period, e.Budget.Metric, formatBudgetAmount(e.Budget.Metric, e.Spending), formatBudgetAmount(e.Budget.Metric, e.Budget.Limit), e.ResetsAt.Format(time.RFC3339))
+}
+
+func formatBudgetAmount(metric string, amount float64) string {
+	if metric == models.BudgetMetric_COST {
+		return fmt.Sprintf("$%.2f", amount)
+	}
+	return fmt.Sprintf("%.0f tokens", amount)
+}
+
+type budgetThresholdWebhookPayload struct {
+	Event       string    `json:"event"`
+	ProjectID   string    `json:"project_id"`
+	BudgetID    string    `json:"budget_id"`
+	APIKeyID    string    `json:"api_key_id,omitempty"`
+	Period      string    `json:"period"`
+	Metric      string    `json:"metric"`
+	Limit       float64   `json:"limit"`
+	Spending    float64   `json:"spending"`
+	Threshold   int       `json:"threshold"`
+	PeriodStart time.Time `json:"period_start"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+func getBudgetPeriodEnd(budget *models.ProjectBudget, periodStart time.Time) time.Time {
+	if budget.Period == models.BudgetPeriod_DAILY {
+		return periodStart.AddDate(0, 0, 1)
+	}
+	return periodStart.AddDate(0, 1, 0)
+}
+
+// budgetApplies reports whether the budget limits the executions of the api
+// key, the budgets of the other api keys do not
+func budgetApplies(budget *models.ProjectBudget, apiKeyID string) bool {
+	return budget.APIKeyID == "" || budget.APIKeyID == apiKeyID
+}
+
+// checkProjectBudgets returns a BudgetExceededError when the spending of the
+// current period reached the limit of a budget of the project that applies to
+// the api key
+func checkProjectBudgets(db *gorm.DB, projectID, apiKeyID string) error {
+	budgets, err := models.GetProjectBudgets(db, projectID)
+	if err != nil {
+		return fmt.Errorf("error getting project budgets: %w", err)
+	}
+
+	now := time.Now()
+	for i := range budgets {
+		budget := &budgets[i]
+		if !budgetApplies(budget, apiKeyID) {
+			continue
+		}
+		periodStart := budget.GetPeriodStart(now)
+		spending, err := models.GetProjectSpending(db, projectID, budget.APIKeyID, budget.Metric, periodStart)
+		if err != nil {
+			return fmt.Errorf("error getting project spending: %w", err)
+		}
+		if spending >= budget.Limit {
+			return &BudgetExceededError{
+				Budget:   budget,
+				Spending: spending,
+				ResetsAt: getBudgetPeriodEnd(budget, periodStart),
+			}
+		}
+	}
+	return nil
+}
+
+// notifyProjectBudgetThresholds sends a webhook for the highest warning
+// threshold crossed by the spending of every budget of the project, once per
+// threshold and period. Only the budgets that apply to the api key of the
+// execution are checked. Failures are logged, they never affect the execution.
+func notifyProjectBudgetThresholds(db *gorm.DB, projectID, apiKeyID string) {
+	budgets, err := models.GetProjectBudgets(db, projectID)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting project budgets: %s: %v", projectID, err)
+		return
+	}
+
+	now := time.Now()
+	for i := range budgets {
+		budget := &budgets[i]
+		if !budgetApplies(budget, apiKeyID) {
+			continue
+		}
+		warningThresholds, err := budget.GetWarningThresholds()
+		if err != nil {
+			logger.GetLogger().Errorf("Error getting budget warning thresholds: %s: %v", budget.Identifier, err)
+			continue
+		}
+		if len(warningThresholds) == 0 || budget.Limit <= 0 {
+			continue
+		}
+
+		periodStart := budget.GetPeriodStart(now)
+		spending, err := models.GetProjectSpending(db, projectID, budget.APIKeyID, budget.Metric, periodStart)
+		if err != nil {
+			logger.GetLogger().Errorf("Error getting project spending: %s: %v", projectID, err)
+			return
+		}
+
+		// only the highest crossed threshold is notified, the lower ones are
+		// implied by it
+		crossedThreshold := 0
+		for _, threshold := range warningThresholds {
+			if spending*100 >= float64(threshold)*budget.Limit {
+				crossedThreshold = max(crossedThreshold, threshold)
+			}
+		}
+		if crossedThreshold == 0 {
+			continue
+		}
+
+		claimed, err := models.ClaimProjectBudgetThreshold(db, budget.Identifier, crossedThreshold, periodStart)
+		if err != nil {
+			logger.GetLogger().Errorf("Error claiming budget threshold: %s: %v", budget.Identifier, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		logger.GetLogger().Infof("Budget threshold reached: %s: %d%%", budget.Identifier, crossedThreshold)
+		queueWebhookDeliveries(db, projectID, "", "", BUDGET_THRESHOLD_REACHED_EVENT, &budgetThresholdWebhookPayload{
+			Event:       BUDGET_THRESHOLD_REACHED_EVENT,
+			ProjectID:   projectID,
+			BudgetID:    budget.Identifier,
+			APIKeyID:    budget.APIKeyID,
+			Period:      budget.Period,
+			Metric:      budget.Metric,
+			Limit:       budget.Limit,
+			Spending:    spending,
+			Threshold:   crossedThreshold,
+			PeriodStart: periodStart,
+			Timestamp:   now.UTC(),
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+var errExecutionCancelled = errors.New("thread execution was cancelled")
+
+// runningExecutions tracks the cancel functions of the executions being run
+// by the workers of this process, so that a cancel request can abort the
+// executor call right away.
+var runningExecutions = struct {
+	sync.Mutex
+	cancels map[string]context.CancelFunc
+}{
+	cancels: make(map[string]context.CancelFunc),
+}
+
+func trackRunningExecution(executionID string, cancel context.CancelFunc) {
+	runningExecutions.Lock()
+	defer runningExecutions.Unlock()
+	runningExecutions.cancels[executionID] = cancel
+}
+
+func untrackRunningExecution(executionID string) {
+	runningExecutions.Lock()
+	defer runningExecutions.Unlock()
+	delete(runningExecutions.cancels, executionID)
+}
+
+func cancelRunningExecution(executionID string) bool {
+	runningExecutions.Lock()
+	defer runningExecutions.Unlock()
+	cancel, ok := runningExecutions.cancels[executionID]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// watchExecutionCancellation polls the execution status and cancels the
+// context once the execution has been cancelled. This covers executions that
+// are cancelled through another replica of the server.
+func watchExecutionCancellation(ctx context.Context, db *gorm.DB, executionID string, cancel context.CancelFunc, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			status, err := models.GetThreadExecutionStatus(db, executionID)
+			if err != nil {
+				logger.GetLogger().Errorf("Error getting thread execution status: %s: %v", executionID, err)
+				continue
+			}
+			if status == models.ThreadExecutionStatus_CANCELLED {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func CancelThreadExecution(db *gorm.DB, req *CancelThreadExecutionRequest) (*models.ThreadExecution, error) {
+	threadExecution, err := models.GetThreadExecutionByID(db, req.ExecutionID)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting thread execution: %s: %v", req.ExecutionID, err)
+		return nil, err
+	}
+
+	if threadExecution.Status != models.ThreadExecutionStatus_IN_PROGRESS {
+		return nil, fmt.Errorf("thread execution is: %s", threadExecution.Status)
+	}
+
+	// the execution may have finished since it was read
+	if !finishThreadExecutionWithError(db, threadExecution, models.ThreadExecutionStatus_CANCELLED, errors.New("thread execution cancelled by user"), nil) {
+		status, err := models.GetThreadExecutionStatus(db, threadExecution.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("thread execution is: %s", status)
+	}
+
+	// a queued job must not be picked up anymore, a running job is stopped
+	// by aborting its context
+	job, err := models.GetExecutionJobByThreadExecutionID(db, threadExecution.Identifier)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.GetLogger().Errorf("Error getting execution job: %s: %v", threadExecution.Identifier, err)
+		return nil, err
+	}
+	if job != nil && job.Status == models.ExecutionJobStatus_QUEUED {
+		if err := models.FinishExecutionJob(db, job.Identifier, models.ExecutionJobStatus_CANCELLED, nil); err != nil {
+			logger.GetLogger().Errorf("Error cancelling execution job: %s: %v", job.Identifier, err)
+			return nil, err
+		}
+	}
+
+	if cancelRunningExecution(threadExecution.Identifier) {
+		logger.GetLogger().Infof("Aborted running thread execution: %s", threadExecution.Identifier)
+	}
+
+	notifyThreadExecutionFinished(db, threadExecution.Identifier)
+
+	return models.GetThreadExecutionByID(db, threadExecution.Identifier)
+}
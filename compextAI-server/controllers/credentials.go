@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/internal/secrets"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+// the characters at the end of a credential shown to tell the credentials apart
+const CREDENTIAL_VISIBLE_CHARACTERS = 4
+
+// the name of the credentials set through the api keys of the user and of the
+// credentials moved from the user
+const DEFAULT_CREDENTIAL_NAME = "default"
+
+func maskCredential(credential string) string {
+	if credential == "" {
+		return ""
+	}
+	// the short credentials are masked entirely
+	if len(credential) <= 3*CREDENTIAL_VISIBLE_CHARACTERS {
+		return strings.Repeat("*", len(credential))
+	}
+	return strings.Repeat("*", len(credential)-CREDENTIAL_VISIBLE_CHARACTERS) + credential[len(credential)-CREDENTIAL_VISIBLE_CHARACTERS:]
+}
+
+// getServiceAccountEmail returns the client email of the google service
+// account, malformed credentials have none
+func getServiceAccountEmail(serviceAccountCreds []byte) string {
+	var serviceAccount struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if len(serviceAccountCreds) > 0 {
+		_ = json.Unmarshal(serviceAccountCreds, &serviceAccount)
+	}
+	return serviceAccount.ClientEmail
+}
+
+// MaskProviderCredential decrypts the value of the credential and masks it,
+// the google credentials are shown as the email of their service account
+func MaskProviderCredential(credential *models.ProviderCredential) (string, error) {
+	value, err := secrets.Decrypt(credential.Value)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting credential %s: %w", credential.Name, err)
+	}
+	if credential.Provider == models.CredentialProvider_GOOGLE {
+		return getServiceAccountEmail([]byte(value)), nil
+	}
+	return maskCredential(value), nil
+}
+
+// CreateProviderCredential encrypts the value of the credential and stores it
+func CreateProviderCredential(db *gorm.DB, request *CreateProviderCredentialRequest) (*models.ProviderCredential, error) {
+	value, err := secrets.Encrypt(request.Value)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting credential: %w", err)
+	}
+	return models.CreateProviderCredential(db, &models.ProviderCredential{
+		UserID:    request.UserID,
+		ProjectID: request.ProjectID,
+		Provider:  request.Provider,
+		Name:      request.Name,
+		Value:     value,
+		Endpoint:  request.Endpoint,
+		IsDefault: request.IsDefault,
+	})
+}
+
+// UpdateProviderCredential encrypts the new value of the credential, the empty
+// fields are left unchanged
+func UpdateProviderCredential(db *gorm.DB, request *UpdateProviderCredentialRequest) error {
+	value, err := secrets.Encrypt(request.Value)
+	if err != nil {
+		return fmt.Errorf("error encrypting credential: %w", err)
+	}
+	return models.UpdateProviderCredential(db, &models.ProviderCredential{
+		Base: models.Base{
+			Identifier: request.Identifier,
+		},
+		Name:     request.Name,
+		Value:    value,
+		Endpoint: request.Endpoint,
+	})
+}
+
+// getCredentialAPIKeys decrypts the credential into the api keys the executor
+// expects for its provider
+func getCredentialAPIKeys(credential *models.ProviderCredential) (map[string]interface{}, error) {
+	value, err := secrets.Decrypt(credential.Value)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting credential %s: %w", credential.Name, err)
+	}
+
+	switch credential.Provider {
+	case models.CredentialProvider_AZURE:
+		return map[string]interface{}{
+			"azure":          value,
+			"azure_endpoint": credential.Endpoint,
+		}, nil
+	case models.CredentialProvider_GOOGLE:
+		return map[string]interface{}{
+			"google_service_account_creds": json.RawMessage(value),
+		}, nil
+	}
+	return map[string]interface{}{
+		credential.Provider: value,
+	}, nil
+}
+
+// TestProviderCredential validates the credential with a request to its
+// vendor through the executor, and records the outcome on the credential
+func TestProviderCredential(ctx context.Context, db *gorm.DB, credential *models.ProviderCredential) (*CredentialTestResult, error) {
+	apiKeys, err := getCredentialAPIKeys(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, testError, err := base.TestCredentials(ctx, credential.Provider, apiKeys)
+	if err != nil {
+		return nil, fmt.Errorf("error testing credential: %w", err)
+	}
+
+	status := models.CredentialTestStatus_SUCCESS
+	if !valid {
+		status = models.CredentialTestStatus_FAILED
+	}
+	if err := models.UpdateProviderCredentialTestResult(db, credential.Identifier, status, testError); err != nil {
+		return nil, err
+	}
+
+	return &CredentialTestResult{
+		Valid: valid,
+		Error: testError,
+	}, nil
+}
+
+// getExecutionCredential returns the default credential of the provider the
+// executions of the project use, the default credential of the project
+// overrides the one of the user. It returns nil when there is none.
+func getExecutionCredential(db *gorm.DB, userID uint, projectID, provider string) (*models.ProviderCredential, error) {
+	scopes := []string{""}
+	if projectID != "" {
+		scopes = []string{projectID, ""}
+	}
+	for _, scope := range scopes {
+		credential, err := models.GetDefaultProviderCredential(db, userID, scope, provider)
+		if err == nil {
+			return credential, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// resolveExecutionCredentials sets the credentials of the user to the ones
+// the executions of the project use. The credentials stay encrypted, the
+// providers decrypt them when they execute.
+func resolveExecutionCredentials(db *gorm.DB, user *models.User, projectID string) error {
+	for _, provider := range models.CredentialProviders {
+		credential, err := getExecutionCredential(db, user.ID, projectID, provider)
+		if err != nil {
+			return fmt.Errorf("error getting %s credential: %w", provider, err)
+		}
+		if credential == nil {
+			continue
+		}
+
+		switch provider {
+		case models.CredentialProvider_OPENAI:
+			user.OpenAIKey = credential.Value
+		case models.CredentialProvider_ANTHROPIC:
+			user.AnthropicKey = credential.Value
+		case models.CredentialProvider_AZURE:
+			user.AzureKey = credential.Value
+			user.AzureEndpoint = credential.Endpoint
+		case models.CredentialProvider_GOOGLE:
+			if user.GoogleServiceAccountCreds, err = json.Marshal(credential.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setDefaultUserCredential replaces the value of the default credential of
+// the provider, or creates it when the user has none
+func setDefaultUserCredential(db *gorm.DB, userID uint, provider, value, endpoint string) error {
+	credential, err := models.GetDefaultProviderCredential(db, userID, "", provider)
+	if err == nil {
+		return UpdateProviderCredential(db, &UpdateProviderCredentialRequest{
+			Identifier: credential.Identifier,
+			Value:      value,
+			Endpoint:   endpoint,
+		})
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if value == "" {
+		return fmt.Errorf("the %s key is required", provider)
+	}
+	_, err = CreateProviderCredential(db, &CreateProviderCredentialRequest{
+		UserID:   userID,
+		Provider: provider,
+		Name:     DEFAULT_CREDENTIAL_NAME,
+		Value:    value,
+		Endpoint: endpoint,
+	})
+	return err
+}
+
+// UpdateUserCredentials sets the default credentials of the user, the empty
+// credentials are left unchanged
+func UpdateUserCredentials(db *gorm.DB, request *UpdateUserCredentialsRequest) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, credential := range []struct {
+			provider string
+			value    string
+			endpoint string
+		}{
+			{models.CredentialProvider_ANTHROPIC, request.AnthropicKey, ""},
+			{models.CredentialProvider_OPENAI, request.OpenAIKey, ""},
+			{models.CredentialProvider_AZURE, request.AzureKey, request.AzureEndpoint},
+			{models.CredentialProvider_GOOGLE, string(request.GoogleServiceAccountCreds), ""},
+		} {
+			if credential.value == "" && credential.endpoint == "" {
+				continue
+			}
+			if err := setDefaultUserCredential(tx, request.UserID, credential.provider, credential.value, credential.endpoint); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetMaskedUserCredentials masks the default credentials of the user
+func GetMaskedUserCredentials(db *gorm.DB, userID uint) (*MaskedUserCredentials, error) {
+	maskedCredentials := &MaskedUserCredentials{}
+	for _, provider := range models.CredentialProviders {
+		credential, err := models.GetDefaultProviderCredential(db, userID, "", provider)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		maskedValue, err := MaskProviderCredential(credential)
+		if err != nil {
+			return nil, err
+		}
+
+		switch provider {
+		case models.CredentialProvider_ANTHROPIC:
+			maskedCredentials.AnthropicKey = maskedValue
+		case models.CredentialProvider_OPENAI:
+			maskedCredentials.OpenAIKey = maskedValue
+		case models.CredentialProvider_AZURE:
+			maskedCredentials.AzureKey = maskedValue
+			maskedCredentials.AzureEndpoint = credential.Endpoint
+		case models.CredentialProvider_GOOGLE:
+			maskedCredentials.GoogleServiceAccountEmail = maskedValue
+		}
+	}
+	return maskedCredentials, nil
+}
+
+// MigrateUserCredentials moves the credentials stored on the users to their
+// default credentials, unless they already have a credential of the provider,
+// and clears them from the users. The credentials stored in plaintext are
+// encrypted on the way.
+func MigrateUserCredentials(db *gorm.DB) error {
+	keyring, err := secrets.GetKeyring()
+	if err != nil {
+		return err
+	}
+
+	users, err := models.GetUsersWithCredentials(db)
+	if err != nil {
+		return fmt.Errorf("error getting users with credentials: %w", err)
+	}
+
+	for _, user := range users {
+		googleServiceAccountCreds := user.GetEncryptedGoogleServiceAccountCreds()
+		if googleServiceAccountCreds == "null" {
+			googleServiceAccountCreds = ""
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, credential := range []struct {
+				provider string
+				value    string
+				endpoint string
+			}{
+				{models.CredentialProvider_OPENAI, user.OpenAIKey, ""},
+				{models.CredentialProvider_ANTHROPIC, user.AnthropicKey, ""},
+				{models.CredentialProvider_AZURE, user.AzureKey, user.AzureEndpoint},
+				{models.CredentialProvider_GOOGLE, googleServiceAccountCreds, ""},
+			} {
+				if credential.value == "" {
+					continue
+				}
+				if _, err := models.GetDefaultProviderCredential(tx, user.ID, "", credential.provider); err == nil {
+					continue
+				} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+
+				value, err := keyring.Reencrypt(credential.value)
+				if err != nil {
+					return err
+				}
+				if _, err := models.CreateProviderCredential(tx, &models.ProviderCredential{
+					UserID:   user.ID,
+					Provider: credential.provider,
+					Name:     DEFAULT_CREDENTIAL_NAME,
+					Value:    value,
+					Endpoint: credential.endpoint,
+				}); err != nil {
+					return err
+				}
+			}
+			return models.ClearUserCredentials(tx, user.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("error migrating credentials of user %s: %w", user.Username, err)
+		}
+		logger.GetLogger().Infof("Moved the credentials of user %s to provider credentials", user.Username)
+	}
+	return nil
+}
+
+// ReencryptProviderCredentials re-encrypts the data keys of the credentials
+// and of the api keys of the project endpoints encrypted with a key other
+// than the active one, and encrypts the ones stored in plaintext. It runs at
+// startup, so that a retired key can be removed from the environment once
+// every instance restarted with the new active key.
+func ReencryptProviderCredentials(db *gorm.DB) error {
+	keyring, err := secrets.GetKeyring()
+	if err != nil {
+		return err
+	}
+
+	credentials, err := models.GetAllProviderCredentials(db)
+	if err != nil {
+		return fmt.Errorf("error getting provider credentials: %w", err)
+	}
+
+	for _, credential := range credentials {
+		if !keyring.NeedsReencryption(credential.Value) {
+			continue
+		}
+		value, err := keyring.Reencrypt(credential.Value)
+		if err != nil {
+			return fmt.Errorf("error re-encrypting credential %s: %w", credential.Identifier, err)
+		}
+		if err := models.UpdateProviderCredentialValue(db, credential.Identifier, value); err != nil {
+			return fmt.Errorf("error updating credential %s: %w", credential.Identifier, err)
+		}
+		logger.GetLogger().Infof("Re-encrypted credential %s", credential.Identifier)
+	}
+
+	endpoints, err := models.GetAllProjectEndpoints(db)
+	if err != nil {
+		return fmt.Errorf("error getting project endpoints: %w", err)
+	}
+
+	for _, endpoint := range endpoints {
+		if !keyring.NeedsReencryption(endpoint.APIKey) {
+			continue
+		}
+		apiKey, err := keyring.Reencrypt(endpoint.APIKey)
+		if err != nil {
+			return fmt.Errorf("error re-encrypting api key of endpoint %s: %w", endpoint.Identifier, err)
+		}
+		if err := models.UpdateProjectEndpointAPIKey(db, endpoint.Identifier, apiKey); err != nil {
+			return fmt.Errorf("error updating endpoint %s: %w", endpoint.Identifier, err)
+		}
+		logger.GetLogger().Infof("Re-encrypted api key of endpoint %s", endpoint.Identifier)
+	}
+	return nil
+}
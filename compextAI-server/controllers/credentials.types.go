@@ -0,0 +1,49 @@
+package controllers
+
+import "encoding/json"
+
+type CreateProviderCredentialRequest struct {
+	UserID    uint
+	ProjectID string
+	Provider  string
+	Name      string
+	Value     string
+	Endpoint  string
+	IsDefault bool
+}
+
+type UpdateProviderCredentialRequest struct {
+	Identifier string
+	Name       string
+	Value      string
+	Endpoint   string
+}
+
+// UpdateUserCredentialsRequest sets the default credentials of the user, the
+// empty credentials are left unchanged
+type UpdateUserCredentialsRequest struct {
+	UserID                    uint
+	AnthropicKey              string
+	OpenAIKey                 string
+	AzureKey                  string
+	AzureEndpoint             string
+	GoogleServiceAccountCreds json.RawMessage
+}
+
+// MaskedUserCredentials shows which credentials are stored without revealing
+// them
+type MaskedUserCredentials struct {
+	AnthropicKey  string
+	OpenAIKey     string
+	AzureKey      string
+	AzureEndpoint string
+	// the client email of the service account, it is not a secret
+	GoogleServiceAccountEmail string
+}
+
+// CredentialTestResult is the outcome of a connection test, the error is the
+// reason the vendor rejected the credential
+type CredentialTestResult struct {
+	Valid bool
+	Error string
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/burnerlee/compextAI/internal/secrets"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+// CreateProjectEndpoint encrypts the api key of the endpoint and stores it
+func CreateProjectEndpoint(db *gorm.DB, endpoint *models.ProjectEndpoint) (*models.ProjectEndpoint, error) {
+	apiKey, err := secrets.Encrypt(endpoint.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting endpoint api key: %w", err)
+	}
+	endpoint.APIKey = apiKey
+	return models.CreateProjectEndpoint(db, endpoint)
+}
+
+// UpdateProjectEndpoint encrypts the new api key of the endpoint, the empty
+// fields are left unchanged
+func UpdateProjectEndpoint(db *gorm.DB, endpoint *models.ProjectEndpoint) error {
+	apiKey, err := secrets.Encrypt(endpoint.APIKey)
+	if err != nil {
+		return fmt.Errorf("error encrypting endpoint api key: %w", err)
+	}
+	endpoint.APIKey = apiKey
+	return models.UpdateProjectEndpoint(db, endpoint)
+}
@@ -1,15 +1,18 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/burnerlee/compextAI/constants"
 	"github.com/burnerlee/compextAI/internal/logger"
 	"github.com/burnerlee/compextAI/internal/providers/chat"
 	"github.com/burnerlee/compextAI/internal/providers/chat/litellm"
+	"github.com/burnerlee/compextAI/internal/providers/chat/local"
 	"github.com/burnerlee/compextAI/models"
 	"gorm.io/gorm"
 )
@@ -21,24 +24,14 @@ func ExecuteThread(db *gorm.DB, req *ExecuteThreadRequest) (interface{}, error)
 		return nil, err
 	}
 
-	if req.ThreadExecutionSystemPrompt != "" {
-		logger.GetLogger().Infof("Setting thread execution system prompt: %s", req.ThreadExecutionSystemPrompt)
-		threadExecutionParamsTemplate.SystemPrompt = req.ThreadExecutionSystemPrompt
+	// resolve the provider up front so that unknown models fail the request
+	// instead of failing later inside the worker
+	if _, err := getChatProvider(threadExecutionParamsTemplate); err != nil {
+		return nil, err
 	}
 
-	var chatProvider chat.ChatCompletionsProvider
-	if threadExecutionParamsTemplate.UseLiteLLM {
-		chatProvider, err = chat.GetChatCompletionsProvider(litellm.LITELLM_IDENTIFIER)
-		if err != nil {
-			logger.GetLogger().Errorf("Error getting litellm chat provider: %v", err)
-			return nil, err
-		}
-	} else {
-		chatProvider, err = chat.GetChatCompletionsProvider(threadExecutionParamsTemplate.Model)
-		if err != nil {
-			logger.GetLogger().Errorf("Error getting chat provider: %s: %v", threadExecutionParamsTemplate.Model, err)
-			return nil, err
-		}
+	if err := checkProjectBudgets(db, req.ProjectID, req.APIKeyID); err != nil {
+		return nil, err
 	}
 
 	var messages []*models.Message
@@ -70,6 +63,21 @@ func ExecuteThread(db *gorm.DB, req *ExecuteThreadRequest) (interface{}, error)
 		req.Tools = make([]*models.ExecutionTool, 0)
 	}
 
+	if req.RunTools {
+		req.Tools, err = addProjectTools(db, req.ProjectID, req.Tools)
+		if err != nil {
+			logger.GetLogger().Errorf("Error adding project tools: %v", err)
+			return nil, err
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		// templates of models outside the catalog are left to the vendor
+		if modelConfig, err := getTemplateModelConfig(db, threadExecutionParamsTemplate); err == nil && !modelConfig.SupportsTools {
+			return nil, fmt.Errorf("model %s does not support tools", threadExecutionParamsTemplate.Model)
+		}
+	}
+
 	toolsJson, err := json.Marshal(req.Tools)
 	if err != nil {
 		logger.GetLogger().Errorf("Error marshalling tools: %v", err)
@@ -82,8 +90,10 @@ func ExecuteThread(db *gorm.DB, req *ExecuteThreadRequest) (interface{}, error)
 		ThreadExecutionParamsTemplateID: req.ThreadExecutionParamTemplateID,
 		Status:                          models.ThreadExecutionStatus_IN_PROGRESS,
 		ProjectID:                       req.ProjectID,
+		APIKeyID:                        req.APIKeyID,
 		Metadata:                        req.Metadata,
 		Tools:                           toolsJson,
+		WebhookURL:                      req.WebhookURL,
 	}
 
 	threadExecution, err = models.CreateThreadExecution(db, threadExecution)
@@ -114,40 +124,215 @@ func ExecuteThread(db *gorm.DB, req *ExecuteThreadRequest) (interface{}, error)
 		}
 	}
 
-	go func(p chat.ChatCompletionsProvider, messages []*models.Message, threadExecution models.ThreadExecution, threadExecutionParamsTemplate models.ThreadExecutionParamsTemplate, appendAssistantResponse bool) {
-		// get the user
-		user, err := models.GetUserByID(db, threadExecution.UserID)
+	if err := enqueueExecutionJob(db, threadExecution.Identifier, &executionJobPayload{
+		Messages:                    messages,
+		ThreadExecutionSystemPrompt: req.ThreadExecutionSystemPrompt,
+		AppendAssistantResponse:     req.AppendAssistantResponse,
+		Tools:                       req.Tools,
+		Stream:                      req.Stream,
+		FallbackTemplateIDs:         req.FallbackTemplateIDs,
+		RunTools:                    req.RunTools,
+		MaxToolIterations:           req.MaxToolIterations,
+	}); err != nil {
+		logger.GetLogger().Errorf("Error enqueueing thread execution: %v", err)
+		handleThreadExecutionError(db, threadExecution, fmt.Errorf("error enqueueing thread execution: %v", err), nil)
+		return nil, err
+	}
+
+	return threadExecution, nil
+}
+
+// the owners of the models routed by the litellm provider of the executor
+var litellmProviderOwners = []string{"openai", "anthropic"}
+
+func getChatProvider(threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) (chat.ChatCompletionsProvider, error) {
+	if local.IsLocalModel(threadExecutionParamsTemplate.Model) {
+		return chat.GetChatCompletionsProvider(local.LOCAL_IDENTIFIER)
+	}
+
+	chatProvider, err := chat.GetChatCompletionsProvider(threadExecutionParamsTemplate.Model)
+
+	// the native clients are implemented by the providers of the models,
+	// litellm only runs in the executor
+	useLiteLLM := threadExecutionParamsTemplate.UseLiteLLM && threadExecutionParamsTemplate.Client != models.TemplateClient_NATIVE
+	if useLiteLLM && (err != nil || slices.Contains(litellmProviderOwners, chatProvider.GetProviderOwner())) {
+		chatProvider, err := chat.GetChatCompletionsProvider(litellm.LITELLM_IDENTIFIER)
+		if err != nil {
+			logger.GetLogger().Errorf("Error getting litellm chat provider: %v", err)
+			return nil, err
+		}
+		return chatProvider, nil
+	}
+
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting chat provider: %s: %v", threadExecutionParamsTemplate.Model, err)
+		return nil, err
+	}
+	return chatProvider, nil
+}
+
+// getExecutionUser returns the owner of the project of the execution, so
+// that every member of a shared project executes with the provider keys of
+// the project. The executions without a project use the keys of the user who
+// requested them.
+func getExecutionUser(db *gorm.DB, threadExecution *models.ThreadExecution) (*models.User, error) {
+	userID := threadExecution.UserID
+	if threadExecution.ProjectID != "" {
+		project, err := models.GetProject(db, threadExecution.ProjectID)
 		if err != nil {
-			logger.GetLogger().Errorf("Error getting user: %d: %v", threadExecution.UserID, err)
-			return
+			return nil, fmt.Errorf("error getting project: %w", err)
 		}
+		userID = project.UserID
+	}
+	user, err := models.GetUserByID(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveExecutionCredentials(db, user, threadExecution.ProjectID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
 
-		if threadExecutionParamsTemplate.ResponseFormat == nil {
-			threadExecutionParamsTemplate.ResponseFormat = json.RawMessage("{}")
+// runThreadExecution executes the thread against the chat provider and
+// records the outcome on the thread execution. It is called by the
+// execution workers for every claimed job. When the template fails with a
+// retryable error, the fallback templates of the payload are tried in turn.
+func runThreadExecution(ctx context.Context, db *gorm.DB, threadExecution *models.ThreadExecution, payload *executionJobPayload) error {
+	// get the user whose provider keys run the execution
+	user, err := getExecutionUser(db, threadExecution)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting user: %d: %v", threadExecution.UserID, err)
+		return handleThreadExecutionError(db, threadExecution, fmt.Errorf("error getting user: %v", err), nil)
+	}
+
+	ctx, run := newThreadExecutionRun(ctx, db, threadExecution, user, payload)
+
+	templateIDs := append([]string{threadExecution.ThreadExecutionParamsTemplateID}, payload.FallbackTemplateIDs...)
+
+	// the failure of the last template that was tried
+	var lastErr error
+	for i, templateID := range templateIDs {
+		threadExecutionParamsTemplate, chatSession, err := getExecutionTemplate(db, templateID, payload)
+		if err != nil {
+			if i == 0 {
+				return handleThreadExecutionError(db, threadExecution, err, &run.usage)
+			}
+			// a broken fallback template must not hide the original failure
+			logger.GetLogger().Errorf("Skipping fallback template: %s: %v", templateID, err)
+			continue
 		}
 
-		// execute the thread using the chat provider
-		statusCode, threadExecutionResponse, err := chatProvider.ExecuteThread(db, user, messages, &threadExecutionParamsTemplate, threadExecution.Identifier, req.Tools)
+		// execute the thread using the chat provider, retrying transient failures
+		statusCode, threadExecutionResponse, retryable, err := run.execute(ctx, chatSession, threadExecutionParamsTemplate)
+		if ctx.Err() != nil {
+			// the execution has been cancelled, its status is already final
+			// but the calls made so far are still billed
+			logger.GetLogger().Infof("Thread execution cancelled: %s", threadExecution.Identifier)
+			recordExecutionUsage(db, threadExecution, &run.usage)
+			return errExecutionCancelled
+		}
 		if err != nil {
-			logger.GetLogger().Errorf("Error executing thread: %s: %v: %v", req.ThreadID, err, threadExecutionResponse)
-			handleThreadExecutionError(db, &threadExecution, fmt.Errorf("error executing thread: %v: %v", err, threadExecutionResponse))
-			return
+			logger.GetLogger().Errorf("Error executing thread: %s: %v: %v", threadExecution.ThreadID, err, threadExecutionResponse)
+			lastErr = fmt.Errorf("error executing thread: %v: %v", err, threadExecutionResponse)
+			if retryable && i < len(templateIDs)-1 {
+				logger.GetLogger().Infof("Falling back to template: %s: %s", threadExecution.Identifier, templateIDs[i+1])
+				continue
+			}
+			return handleThreadExecutionError(db, threadExecution, lastErr, &run.usage)
 		}
 
 		if statusCode != http.StatusOK {
-			logger.GetLogger().Errorf("Error executing thread: %s: status code: %d: %v", req.ThreadID, statusCode, threadExecutionResponse)
-			handleThreadExecutionError(db, &threadExecution, fmt.Errorf("status code: %d: %v", statusCode, threadExecutionResponse))
-			return
+			logger.GetLogger().Errorf("Error executing thread: %s: status code: %d: %v", threadExecution.ThreadID, statusCode, threadExecutionResponse)
+			lastErr = fmt.Errorf("status code: %d: %v", statusCode, threadExecutionResponse)
+			if retryable && i < len(templateIDs)-1 {
+				logger.GetLogger().Infof("Falling back to template: %s: %s", threadExecution.Identifier, templateIDs[i+1])
+				continue
+			}
+			return handleThreadExecutionError(db, threadExecution, lastErr, &run.usage)
 		}
 
-		logger.GetLogger().Infof("Thread execution completed: %s", req.ThreadID)
-		handleThreadExecutionSuccess(db, p, &threadExecution, threadExecutionResponse, appendAssistantResponse)
-	}(chatProvider, messages, *threadExecution, *threadExecutionParamsTemplate, req.AppendAssistantResponse)
+		// let the model call the project tools until it is done with them
+		threadExecutionResponse, err = runExecutionTools(ctx, run, chatSession, threadExecutionParamsTemplate, threadExecutionResponse)
+		if ctx.Err() != nil {
+			logger.GetLogger().Infof("Thread execution cancelled: %s", threadExecution.Identifier)
+			recordExecutionUsage(db, threadExecution, &run.usage)
+			return errExecutionCancelled
+		}
+		if err != nil {
+			logger.GetLogger().Errorf("Error running tools: %s: %v", threadExecution.Identifier, err)
+			return handleThreadExecutionError(db, threadExecution, err, &run.usage)
+		}
 
-	return threadExecution, nil
+		// record which template served the response
+		if err := models.UpdateThreadExecution(db, &models.ThreadExecution{
+			Base: models.Base{
+				Identifier: threadExecution.Identifier,
+			},
+			ServedTemplateID: threadExecutionParamsTemplate.Identifier,
+			ServedModel:      threadExecutionParamsTemplate.Model,
+		}); err != nil {
+			logger.GetLogger().Errorf("Error recording served template: %s: %v", threadExecution.Identifier, err)
+		}
+
+		logger.GetLogger().Infof("Thread execution completed: %s: served by %s", threadExecution.ThreadID, threadExecutionParamsTemplate.Model)
+		if err := handleThreadExecutionSuccess(db, chatSession, threadExecution, threadExecutionResponse, payload.AppendAssistantResponse, &run.usage); err != nil {
+			return err
+		}
+		notifyProjectBudgetThresholds(db, threadExecution.ProjectID, threadExecution.APIKeyID)
+		return nil
+	}
+
+	// only reached when the remaining fallback templates were skipped after a
+	// retryable failure
+	return handleThreadExecutionError(db, threadExecution, lastErr, &run.usage)
+}
+
+// getExecutionTemplate loads a template of the fallback chain with the
+// overrides of the payload applied, along with the chat session that executes
+// it.
+func getExecutionTemplate(db *gorm.DB, templateID string, payload *executionJobPayload) (*models.ThreadExecutionParamsTemplate, chat.ChatCompletionsSession, error) {
+	threadExecutionParamsTemplate, err := models.GetThreadExecutionParamsTemplateByID(db, templateID)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting thread execution params template: %s: %v", templateID, err)
+		return nil, nil, fmt.Errorf("error getting thread execution params template: %v", err)
+	}
+
+	if payload.ThreadExecutionSystemPrompt != "" {
+		threadExecutionParamsTemplate.SystemPrompt = payload.ThreadExecutionSystemPrompt
+	}
+
+	if threadExecutionParamsTemplate.ResponseFormat == nil {
+		threadExecutionParamsTemplate.ResponseFormat = json.RawMessage("{}")
+	}
+
+	chatProvider, err := getChatProvider(threadExecutionParamsTemplate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting chat provider: %v", err)
+	}
+	return threadExecutionParamsTemplate, chatProvider.NewSession(threadExecutionParamsTemplate), nil
+}
+
+// handleThreadExecutionError marks the execution as failed along with the
+// usage of the calls made so far, nil before any call, and returns the error,
+// or errExecutionCancelled when the execution was finished first, e.g.
+// cancelled by the user.
+func handleThreadExecutionError(db *gorm.DB, threadExecution *models.ThreadExecution, execErr error, usage *executionUsage) error {
+	if !finishThreadExecutionWithError(db, threadExecution, models.ThreadExecutionStatus_FAILED, execErr, usage) {
+		recordExecutionUsage(db, threadExecution, usage)
+		return errExecutionCancelled
+	}
+	// the calls of a failed execution count towards the budgets too
+	if !usage.isEmpty() {
+		notifyProjectBudgetThresholds(db, threadExecution.ProjectID, threadExecution.APIKeyID)
+	}
+	return execErr
 }
 
-func handleThreadExecutionError(db *gorm.DB, threadExecution *models.ThreadExecution, execErr error) {
+// finishThreadExecutionWithError moves the thread execution to a terminal
+// status and stores the error in the execution output, along with the usage
+// when set. It reports whether the execution was still in progress.
+func finishThreadExecutionWithError(db *gorm.DB, threadExecution *models.ThreadExecution, status string, execErr error, usage *executionUsage) bool {
 	executionTime := time.Since(threadExecution.CreatedAt).Seconds()
 
 	updatedThreadExecution := models.ThreadExecution{
@@ -155,9 +340,12 @@ func handleThreadExecutionError(db *gorm.DB, threadExecution *models.ThreadExecu
 			ID:         threadExecution.ID,
 			Identifier: threadExecution.Identifier,
 		},
-		Status:        models.ThreadExecutionStatus_FAILED,
+		Status:        status,
 		ExecutionTime: uint(executionTime),
 	}
+	if usage != nil {
+		usage.apply(&updatedThreadExecution)
+	}
 	errJson, jsonErr := json.Marshal(struct {
 		Error string `json:"error"`
 	}{
@@ -165,15 +353,25 @@ func handleThreadExecutionError(db *gorm.DB, threadExecution *models.ThreadExecu
 	})
 	if jsonErr != nil {
 		logger.GetLogger().Errorf("Error marshalling error: %v", jsonErr)
-		models.UpdateThreadExecution(db, &updatedThreadExecution)
-		return
+	} else {
+		updatedThreadExecution.Output = errJson
 	}
 
-	updatedThreadExecution.Output = errJson
-	models.UpdateThreadExecution(db, &updatedThreadExecution)
+	finished, err := models.FinishThreadExecution(db, &updatedThreadExecution)
+	if err != nil {
+		logger.GetLogger().Errorf("Error finishing thread execution: %s: %v", threadExecution.Identifier, err)
+		return false
+	}
+	if !finished {
+		logger.GetLogger().Infof("Thread execution already finished, not moving it to %s: %s", status, threadExecution.Identifier)
+	}
+	return finished
 }
 
-func handleThreadExecutionSuccess(db *gorm.DB, p chat.ChatCompletionsProvider, threadExecution *models.ThreadExecution, threadExecutionResponse interface{}, appendAssistantResponse bool) {
+// handleThreadExecutionSuccess records the response and marks the execution
+// as completed, along with the assistant message when it is appended. It
+// returns errExecutionCancelled when the execution was finished first.
+func handleThreadExecutionSuccess(db *gorm.DB, chatSession chat.ChatCompletionsSession, threadExecution *models.ThreadExecution, threadExecutionResponse interface{}, appendAssistantResponse bool, usage *executionUsage) error {
 	updatedThreadExecution := models.ThreadExecution{
 		Base: models.Base{
 			ID:         threadExecution.ID,
@@ -181,68 +379,79 @@ func handleThreadExecutionSuccess(db *gorm.DB, p chat.ChatCompletionsProvider, t
 		},
 		Status: models.ThreadExecutionStatus_COMPLETED,
 	}
+	usage.apply(&updatedThreadExecution)
 	responseJson, err := json.Marshal(threadExecutionResponse)
 	if err != nil {
 		logger.GetLogger().Errorf("Error marshalling thread execution response: %v", err)
-		handleThreadExecutionError(db, threadExecution, fmt.Errorf("error marshalling thread execution response: %v", err))
-		models.UpdateThreadExecution(db, &updatedThreadExecution)
-		return
+		return handleThreadExecutionError(db, threadExecution, fmt.Errorf("error marshalling thread execution response: %v", err), usage)
 	}
 
 	updatedThreadExecution.Output = responseJson
 
-	message, err := p.ConvertExecutionResponseToMessage(threadExecutionResponse)
+	message, err := chatSession.ConvertExecutionResponseToMessage(threadExecutionResponse)
 	if err != nil {
 		logger.GetLogger().Errorf("Error converting thread execution response to message: %v", err)
-		handleThreadExecutionError(db, threadExecution, fmt.Errorf("error converting thread execution response to message: %v", err))
-		models.UpdateThreadExecution(db, &updatedThreadExecution)
-		return
+		return handleThreadExecutionError(db, threadExecution, fmt.Errorf("error converting thread execution response to message: %v", err), usage)
 	}
 
 	updatedThreadExecution.Role = message.Role
 	updatedThreadExecution.ExecutionResponseMetadata = message.Metadata
-
-	if appendAssistantResponse {
-		logger.GetLogger().Infof("Appending assistant response")
-
-		if err := models.CreateMessage(db, &models.Message{
-			ThreadID:   threadExecution.ThreadID,
-			Role:       message.Role,
-			ContentMap: message.ContentMap,
-			Metadata:   message.Metadata,
-		}); err != nil {
-			logger.GetLogger().Errorf("Error creating assistant message: %v", err)
-			handleThreadExecutionError(db, threadExecution, fmt.Errorf("error creating assistant message: %v", err))
-			return
-		} else {
-			logger.GetLogger().Infof("assistant message created")
-		}
+	if message.ToolCalls != nil {
+		updatedThreadExecution.ToolCalls = message.ToolCalls
 	}
 
 	var contentMap map[string]interface{}
 	if err := json.Unmarshal(message.ContentMap, &contentMap); err != nil {
 		logger.GetLogger().Errorf("Error unmarshalling content map: %v", err)
-		models.UpdateThreadExecution(db, &updatedThreadExecution)
-		return
-	}
-	outputContent, ok := contentMap["content"]
-	if !ok {
+	} else if outputContent, ok := contentMap["content"]; !ok {
 		logger.GetLogger().Errorf("Content map does not contain 'content' key")
-		models.UpdateThreadExecution(db, &updatedThreadExecution)
-		return
-	}
-
-	outputContentString, ok := outputContent.(string)
-	if !ok {
-		logger.GetLogger().Errorf("Content is not a string")
-		outputContentString = fmt.Sprintf("%v", outputContent)
+	} else {
+		outputContentString, ok := outputContent.(string)
+		if !ok {
+			logger.GetLogger().Errorf("Content is not a string")
+			outputContentString = fmt.Sprintf("%v", outputContent)
+		}
+		updatedThreadExecution.Content = outputContentString
 	}
 
 	executionTime := time.Since(threadExecution.CreatedAt).Seconds()
-
 	updatedThreadExecution.ExecutionTime = uint(executionTime)
-	updatedThreadExecution.Content = outputContentString
-	models.UpdateThreadExecution(db, &updatedThreadExecution)
+
+	// the assistant message is only appended when the execution completes, a
+	// cancelled execution must not add to the thread
+	finished := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		ok, err := models.FinishThreadExecution(tx, &updatedThreadExecution)
+		if err != nil || !ok {
+			return err
+		}
+
+		if appendAssistantResponse {
+			logger.GetLogger().Infof("Appending assistant response")
+			if err := models.CreateMessage(tx, &models.Message{
+				ThreadID:   threadExecution.ThreadID,
+				Role:       message.Role,
+				ContentMap: message.ContentMap,
+				Metadata:   message.Metadata,
+				ToolCalls:  message.ToolCalls,
+			}); err != nil {
+				return fmt.Errorf("error creating assistant message: %v", err)
+			}
+			logger.GetLogger().Infof("assistant message created")
+		}
+		finished = true
+		return nil
+	})
+	if err != nil {
+		logger.GetLogger().Errorf("Error completing thread execution: %s: %v", threadExecution.Identifier, err)
+		return handleThreadExecutionError(db, threadExecution, err, usage)
+	}
+	if !finished {
+		logger.GetLogger().Infof("Thread execution already finished, not completing it: %s", threadExecution.Identifier)
+		recordExecutionUsage(db, threadExecution, usage)
+		return errExecutionCancelled
+	}
+	return nil
 }
 
 func RerunThreadExecution(db *gorm.DB, req *RerunThreadExecutionRequest) (interface{}, error) {
@@ -275,6 +484,7 @@ func RerunThreadExecution(db *gorm.DB, req *RerunThreadExecutionRequest) (interf
 		Messages:                       messages,
 		FetchMessagesFromThread:        false,
 		ProjectID:                      threadExecution.ProjectID,
+		APIKeyID:                       req.APIKeyID,
 		Tools:                          req.Tools,
 	})
 }
@@ -15,8 +15,17 @@ type ExecuteThreadRequest struct {
 	Messages                       []*models.Message
 	FetchMessagesFromThread        bool
 	ProjectID                      string
-	Metadata                       json.RawMessage
-	Tools                          []*models.ExecutionTool
+	// the api key that requested the execution, empty for the dashboard
+	APIKeyID   string
+	Metadata   json.RawMessage
+	Tools      []*models.ExecutionTool
+	Stream     bool
+	WebhookURL string
+	// tried in order when the template fails with a retryable error
+	FallbackTemplateIDs []string
+	// runs the tool calls of the model with the project tools
+	RunTools          bool
+	MaxToolIterations int
 }
 
 type ExecuteThreadResponse struct {
@@ -26,8 +35,14 @@ type ExecuteThreadResponse struct {
 type RerunThreadExecutionRequest struct {
 	UserID                         uint
 	ExecutionID                    string
+	APIKeyID                       string
 	ThreadExecutionParamTemplateID string
 	SystemPrompt                   string
 	AppendAssistantResponse        bool
 	Tools                          []*models.ExecutionTool
 }
+
+type CancelThreadExecutionRequest struct {
+	UserID      uint
+	ExecutionID string
+}
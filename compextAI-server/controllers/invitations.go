@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"gorm.io/gorm"
+)
+
+// the invitations expire after this duration, the inviter sends a new one
+const INVITATION_DURATION = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidInvitation       = errors.New("the invitation is invalid, expired or already accepted")
+	ErrInvitationEmailMismatch = errors.New("the invitation was sent to another email")
+)
+
+func generateInvitationToken() (string, error) {
+	secret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s", constants.INVITATION_TOKEN_PREFIX, secret), nil
+}
+
+// CreateInvitation invites the email to the project or the organization of
+// the request. The token is only returned here, the database keeps its hash.
+func CreateInvitation(db *gorm.DB, request *CreateInvitationRequest) (*models.Invitation, string, error) {
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation, err := models.CreateInvitation(db, &models.Invitation{
+		OrganizationID: request.OrganizationID,
+		ProjectID:      request.ProjectID,
+		Email:          strings.ToLower(request.Email),
+		Role:           request.Role,
+		InvitedByID:    request.InvitedByID,
+		TokenHash:      utils.HashToken(token),
+		ExpiresAt:      time.Now().Add(INVITATION_DURATION),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return invitation, token, nil
+}
+
+// AcceptInvitation makes the user a member of the project or the organization
+// of the invitation. The invitation must have been sent to the email of the
+// user. An existing higher role of the user is kept.
+func AcceptInvitation(db *gorm.DB, userID uint, token string) (*models.Invitation, error) {
+	invitation, err := models.GetPendingInvitationByTokenHash(db, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+
+	user, err := models.GetUserByID(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		accepted, err := models.AcceptInvitation(tx, invitation.Identifier, userID)
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return ErrInvalidInvitation
+		}
+
+		if invitation.OrganizationID != "" {
+			return addOrganizationMember(tx, invitation.OrganizationID, userID, invitation.Role)
+		}
+		return addProjectMember(tx, invitation.ProjectID, userID, invitation.Role)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func addOrganizationMember(db *gorm.DB, organizationID string, userID uint, role string) error {
+	member, err := models.GetOrganizationMember(db, organizationID, userID)
+	if err == nil && models.RoleAllows(member.Role, role) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return models.AddOrganizationMember(db, &models.OrganizationMember{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Role:           role,
+	})
+}
+
+func addProjectMember(db *gorm.DB, projectID string, userID uint, role string) error {
+	project, err := models.GetProject(db, projectID)
+	if err != nil {
+		return err
+	}
+	// the owner of the project is never a member of it
+	if project.UserID == userID {
+		return nil
+	}
+
+	member, err := models.GetProjectMember(db, projectID, userID)
+	if err == nil && models.RoleAllows(member.Role, role) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return models.AddProjectMember(db, &models.ProjectMember{
+		ProjectID: projectID,
+		UserID:    userID,
+		Role:      role,
+	})
+}
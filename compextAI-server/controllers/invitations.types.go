@@ -0,0 +1,10 @@
+package controllers
+
+type CreateInvitationRequest struct {
+	InvitedByID uint
+	// exactly one of the organization and the project is set
+	OrganizationID string
+	ProjectID      string
+	Email          string
+	Role           string
+}
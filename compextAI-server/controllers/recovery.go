@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// used when the template does not define a timeout, matches the
+	// default timeout of the chat providers
+	DEFAULT_EXECUTION_TIMEOUT = 600 * time.Second
+	// an orphaned execution is re-enqueued until its job has been claimed
+	// this many times, after that it is marked as timed out
+	MAX_EXECUTION_JOB_ATTEMPTS = 3
+	// the workers renew the lease of their job well before it expires, a
+	// job is only orphaned once its worker missed a few renewals
+	EXECUTION_JOB_LEASE_DURATION        = time.Minute
+	EXECUTION_JOB_LEASE_RENEW_INTERVAL  = EXECUTION_JOB_LEASE_DURATION / 3
+	DEFAULT_EXECUTION_RECOVERY_INTERVAL = 30 * time.Second
+)
+
+// RecoverOrphanedExecutions reconciles the thread executions left in
+// progress by a previous run of the server. It runs at startup, before the
+// execution workers are started. The executions created before the job queue
+// have no job to retry and are marked as timed out once past their template
+// timeout, the jobs whose lease expired are reconciled.
+func RecoverOrphanedExecutions(db *gorm.DB) error {
+	threadExecutions, err := models.GetThreadExecutionsByStatus(db, models.ThreadExecutionStatus_IN_PROGRESS)
+	if err != nil {
+		return fmt.Errorf("error getting in progress thread executions: %w", err)
+	}
+
+	timedOut := 0
+	for i := range threadExecutions {
+		threadExecution := &threadExecutions[i]
+
+		timeout := DEFAULT_EXECUTION_TIMEOUT
+		if threadExecution.ThreadExecutionParamsTemplate.Timeout > 0 {
+			timeout = time.Duration(threadExecution.ThreadExecutionParamsTemplate.Timeout) * time.Second
+		}
+		if time.Since(threadExecution.CreatedAt) < timeout {
+			continue
+		}
+
+		_, err := models.GetExecutionJobByThreadExecutionID(db, threadExecution.Identifier)
+		if err == nil {
+			// the executions with a job are recovered through its lease
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("error getting execution job: %s: %w", threadExecution.Identifier, err)
+		}
+		if finishThreadExecutionWithError(db, threadExecution, models.ThreadExecutionStatus_TIMED_OUT,
+			fmt.Errorf("execution exceeded its timeout of %s and has no stored job to retry", timeout), nil) {
+			notifyThreadExecutionFinished(db, threadExecution.Identifier)
+			timedOut++
+		}
+	}
+	logger.GetLogger().Infof("Timed out %d thread executions without a job", timedOut)
+
+	return RecoverExpiredExecutionJobs(db)
+}
+
+// RecoverExpiredExecutionJobs reconciles the running jobs whose lease
+// expired, their worker stopped without finishing them. The job is requeued
+// until it reaches MAX_EXECUTION_JOB_ATTEMPTS, then its execution is marked
+// as timed out.
+func RecoverExpiredExecutionJobs(db *gorm.DB) error {
+	now := time.Now()
+	legacyCutoff := now.Add(-DEFAULT_EXECUTION_TIMEOUT)
+
+	jobs, err := models.GetExpiredExecutionJobs(db, now, legacyCutoff)
+	if err != nil {
+		return fmt.Errorf("error getting expired execution jobs: %w", err)
+	}
+
+	requeued, timedOut := 0, 0
+	for i := range jobs {
+		job := &jobs[i]
+
+		threadExecution, err := models.GetThreadExecutionByID(db, job.ThreadExecutionID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("error getting thread execution: %s: %w", job.ThreadExecutionID, err)
+		}
+
+		// the worker stopped after the execution was finished, only the job
+		// is left to finish
+		if threadExecution == nil || threadExecution.Status != models.ThreadExecutionStatus_IN_PROGRESS {
+			status := models.ExecutionJobStatus_FAILED
+			if threadExecution != nil && threadExecution.Status == models.ThreadExecutionStatus_COMPLETED {
+				status = models.ExecutionJobStatus_COMPLETED
+			} else if threadExecution != nil && threadExecution.Status == models.ThreadExecutionStatus_CANCELLED {
+				status = models.ExecutionJobStatus_CANCELLED
+			}
+			if _, err := models.FinishExpiredExecutionJob(db, job.Identifier, status, nil, now, legacyCutoff); err != nil {
+				return fmt.Errorf("error finishing execution job: %s: %w", job.Identifier, err)
+			}
+			continue
+		}
+
+		if job.Attempts < MAX_EXECUTION_JOB_ATTEMPTS {
+			ok, err := models.RequeueExpiredExecutionJob(db, job.Identifier, now, legacyCutoff)
+			if err != nil {
+				return fmt.Errorf("error requeueing execution job: %s: %w", job.Identifier, err)
+			}
+			if ok {
+				logger.GetLogger().Infof("Re-enqueued orphaned thread execution: %s (attempt %d)", threadExecution.Identifier, job.Attempts+1)
+				requeued++
+			}
+			continue
+		}
+
+		reason := fmt.Errorf("execution lost its worker after %d attempts", job.Attempts)
+		ok, err := models.FinishExpiredExecutionJob(db, job.Identifier, models.ExecutionJobStatus_FAILED, reason, now, legacyCutoff)
+		if err != nil {
+			return fmt.Errorf("error finishing execution job: %s: %w", job.Identifier, err)
+		}
+		if ok && finishThreadExecutionWithError(db, threadExecution, models.ThreadExecutionStatus_TIMED_OUT, reason, nil) {
+			notifyThreadExecutionFinished(db, threadExecution.Identifier)
+			timedOut++
+		}
+	}
+
+	if requeued > 0 && executionWorkerPool != nil {
+		executionWorkerPool.notify()
+	}
+	if requeued > 0 || timedOut > 0 {
+		logger.GetLogger().Infof("Recovered orphaned thread executions: %d re-enqueued, %d timed out", requeued, timedOut)
+	}
+	return nil
+}
+
+// RunExecutionRecovery reconciles the expired jobs every interval until ctx
+// is done, so that the jobs of a replica that stopped are picked up without
+// waiting for a restart.
+func RunExecutionRecovery(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := RecoverExpiredExecutionJobs(db); err != nil {
+			logger.GetLogger().Errorf("Error recovering expired execution jobs: %v", err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// used when the template does not define a retry policy
+	DEFAULT_EXECUTION_MAX_ATTEMPTS  = 3
+	DEFAULT_EXECUTION_RETRY_BACKOFF = 2 * time.Second
+	MAX_EXECUTION_RETRY_BACKOFF     = 60 * time.Second
+)
+
+// rate limits, executor timeouts and upstream outages. The executor responds
+// with a 500 to any error, including the invalid requests, so it is only
+// retried when the template opts in.
+var defaultRetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+type executionRetryPolicy struct {
+	maxAttempts          int
+	backoff              time.Duration
+	retryableStatusCodes []int
+}
+
+func getExecutionRetryPolicy(threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) (*executionRetryPolicy, error) {
+	policy := &executionRetryPolicy{
+		maxAttempts:          DEFAULT_EXECUTION_MAX_ATTEMPTS,
+		backoff:              DEFAULT_EXECUTION_RETRY_BACKOFF,
+		retryableStatusCodes: defaultRetryableStatusCodes,
+	}
+
+	if threadExecutionParamsTemplate.MaxAttempts > 0 {
+		policy.maxAttempts = threadExecutionParamsTemplate.MaxAttempts
+	}
+	if threadExecutionParamsTemplate.RetryBackoff > 0 {
+		policy.backoff = time.Duration(threadExecutionParamsTemplate.RetryBackoff) * time.Second
+	}
+	if len(threadExecutionParamsTemplate.RetryableStatusCodes) > 0 {
+		var retryableStatusCodes []int
+		if err := json.Unmarshal(threadExecutionParamsTemplate.RetryableStatusCodes, &retryableStatusCodes); err != nil {
+			return nil, fmt.Errorf("error unmarshalling retryable status codes: %w", err)
+		}
+		if len(retryableStatusCodes) > 0 {
+			policy.retryableStatusCodes = retryableStatusCodes
+		}
+	}
+	return policy, nil
+}
+
+// isRetryable reports whether the outcome of an attempt is a transient
+// failure. Errors are only retried when the executor could not be reached.
+func (p *executionRetryPolicy) isRetryable(statusCode int, err error) bool {
+	if err != nil {
+		return base.IsTransportError(err)
+	}
+	return slices.Contains(p.retryableStatusCodes, statusCode)
+}
+
+// backoffBefore returns how long to wait before the given attempt
+func (p *executionRetryPolicy) backoffBefore(attempt int) time.Duration {
+	backoff := p.backoff
+	for i := 2; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= MAX_EXECUTION_RETRY_BACKOFF {
+			return MAX_EXECUTION_RETRY_BACKOFF
+		}
+	}
+	return backoff
+}
+
+// threadExecutionRun holds the state shared by all the attempts of an
+// execution, across the templates of its fallback chain.
+type threadExecutionRun struct {
+	db              *gorm.DB
+	threadExecution *models.ThreadExecution
+	user            *models.User
+	payload         *executionJobPayload
+	attempts        []models.ExecutionAttempt
+	// a streamed attempt cannot be retried once its deltas reached the clients
+	streamed bool
+	usage    executionUsage
+}
+
+func newThreadExecutionRun(ctx context.Context, db *gorm.DB, threadExecution *models.ThreadExecution, user *models.User, payload *executionJobPayload) (context.Context, *threadExecutionRun) {
+	run := &threadExecutionRun{
+		db:              db,
+		threadExecution: threadExecution,
+		user:            user,
+		payload:         payload,
+		attempts:        make([]models.ExecutionAttempt, 0),
+	}
+	if onDelta := base.DeltaHandlerFromContext(ctx); onDelta != nil {
+		ctx = base.WithDeltaHandler(ctx, func(delta string) {
+			run.streamed = true
+			onDelta(delta)
+		})
+	}
+	return ctx, run
+}
+
+// execute calls the chat provider until it succeeds, fails with an error
+// that is not transient or runs out of attempts. Every attempt is recorded on
+// the thread execution. The outcome of the last attempt is returned along
+// with whether it is a transient failure that another template may recover.
+func (r *threadExecutionRun) execute(ctx context.Context, chatSession chat.ChatCompletionsSession, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) (int, interface{}, bool, error) {
+	policy, err := getExecutionRetryPolicy(threadExecutionParamsTemplate)
+	if err != nil {
+		return -1, nil, false, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		startedAt := time.Now()
+		statusCode, response, err := chatSession.ExecuteThread(ctx, r.db, r.user, r.payload.Messages, r.threadExecution.Identifier, r.payload.Tools)
+
+		executionAttempt := models.ExecutionAttempt{
+			Attempt:    len(r.attempts) + 1,
+			TemplateID: threadExecutionParamsTemplate.Identifier,
+			Model:      threadExecutionParamsTemplate.Model,
+			StatusCode: statusCode,
+			StartedAt:  startedAt,
+			Duration:   time.Since(startedAt).Milliseconds(),
+		}
+		if err != nil {
+			executionAttempt.Error = err.Error()
+		} else if statusCode != http.StatusOK {
+			executionAttempt.Error = fmt.Sprintf("status code: %d: %v", statusCode, response)
+		}
+		r.attempts = append(r.attempts, executionAttempt)
+		recordExecutionAttempts(r.db, r.threadExecution, r.attempts)
+
+		if err == nil && statusCode == http.StatusOK {
+			r.addUsage(chatSession, threadExecutionParamsTemplate, response)
+		}
+		if ctx.Err() != nil || (err == nil && statusCode == http.StatusOK) {
+			return statusCode, response, false, err
+		}
+		if r.streamed || !policy.isRetryable(statusCode, err) {
+			return statusCode, response, false, err
+		}
+		if attempt >= policy.maxAttempts {
+			return statusCode, response, true, err
+		}
+
+		backoff := policy.backoffBefore(attempt + 1)
+		logger.GetLogger().Infof("Retrying thread execution: %s: attempt %d failed: %s, retrying in %s", r.threadExecution.Identifier, executionAttempt.Attempt, executionAttempt.Error, backoff)
+
+		select {
+		case <-ctx.Done():
+			return statusCode, response, false, err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func recordExecutionAttempts(db *gorm.DB, threadExecution *models.ThreadExecution, attempts []models.ExecutionAttempt) {
+	attemptsJson, err := json.Marshal(attempts)
+	if err != nil {
+		logger.GetLogger().Errorf("Error marshalling execution attempts: %v", err)
+		return
+	}
+
+	if err := models.UpdateThreadExecution(db, &models.ThreadExecution{
+		Base: models.Base{
+			Identifier: threadExecution.Identifier,
+		},
+		Attempts: attemptsJson,
+	}); err != nil {
+		logger.GetLogger().Errorf("Error recording execution attempts: %s: %v", threadExecution.Identifier, err)
+	}
+}
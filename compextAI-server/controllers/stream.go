@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"strings"
+	"sync"
+)
+
+// a subscriber that falls this far behind is dropped, it still receives the
+// final result from the database
+const EXECUTION_STREAM_SUBSCRIBER_BUFFER = 256
+
+// executionStream relays the content deltas of an execution run by a worker
+// of this process to the clients streaming it.
+type executionStream struct {
+	mu          sync.Mutex
+	content     strings.Builder
+	subscribers map[chan string]struct{}
+}
+
+var executionStreams = struct {
+	sync.Mutex
+	streams map[string]*executionStream
+}{
+	streams: make(map[string]*executionStream),
+}
+
+func openExecutionStream(executionID string) *executionStream {
+	stream := &executionStream{
+		subscribers: make(map[chan string]struct{}),
+	}
+
+	executionStreams.Lock()
+	defer executionStreams.Unlock()
+	executionStreams.streams[executionID] = stream
+	return stream
+}
+
+// closeExecutionStream ends the stream for all of its subscribers. It is
+// called once the outcome of the execution has been persisted.
+func closeExecutionStream(executionID string) {
+	executionStreams.Lock()
+	stream, ok := executionStreams.streams[executionID]
+	delete(executionStreams.streams, executionID)
+	executionStreams.Unlock()
+	if !ok {
+		return
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	for subscriber := range stream.subscribers {
+		close(subscriber)
+	}
+	stream.subscribers = nil
+}
+
+func (s *executionStream) publish(delta string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.content.WriteString(delta)
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- delta:
+		default:
+			delete(s.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// SubscribeThreadExecutionStream subscribes to the deltas of an execution
+// being run in this process. It returns the content streamed so far, a
+// channel of the following deltas that is closed when the execution is
+// finished, and a function to unsubscribe. ok is false when the execution is
+// not being streamed by this process.
+func SubscribeThreadExecutionStream(executionID string) (content string, deltas <-chan string, unsubscribe func(), ok bool) {
+	executionStreams.Lock()
+	stream, ok := executionStreams.streams[executionID]
+	executionStreams.Unlock()
+	if !ok {
+		return "", nil, nil, false
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.subscribers == nil {
+		// the stream was closed in the meantime
+		return "", nil, nil, false
+	}
+
+	subscriber := make(chan string, EXECUTION_STREAM_SUBSCRIBER_BUFFER)
+	stream.subscribers[subscriber] = struct{}{}
+
+	unsubscribe = func() {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		if _, ok := stream.subscribers[subscriber]; ok {
+			delete(stream.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	return stream.content.String(), subscriber, unsubscribe, true
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/internal/providers/chat/local"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+// ValidateThreadExecutionParamsTemplate checks the template against the model
+// catalog, so that the parameters the model does not support are rejected
+// when the template is saved instead of failing every execution.
+func ValidateThreadExecutionParamsTemplate(db *gorm.DB, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) error {
+	modelConfig, err := getTemplateModelConfig(db, threadExecutionParamsTemplate)
+	if err != nil {
+		return err
+	}
+
+	if threadExecutionParamsTemplate.Client == models.TemplateClient_NATIVE && !modelConfig.SupportsNativeClient() {
+		return fmt.Errorf("model %s does not support the %s client", threadExecutionParamsTemplate.Model, models.TemplateClient_NATIVE)
+	}
+
+	parameters := []struct {
+		name  string
+		isSet bool
+	}{
+		{base.PARAMETER_TEMPERATURE, threadExecutionParamsTemplate.Temperature != 0},
+		{base.PARAMETER_TOP_P, threadExecutionParamsTemplate.TopP != 0},
+		{base.PARAMETER_MAX_TOKENS, threadExecutionParamsTemplate.MaxTokens != 0},
+		{base.PARAMETER_MAX_COMPLETION_TOKENS, threadExecutionParamsTemplate.MaxCompletionTokens != 0},
+		{base.PARAMETER_MAX_OUTPUT_TOKENS, threadExecutionParamsTemplate.MaxOutputTokens != 0},
+	}
+	for _, parameter := range parameters {
+		if parameter.isSet && !modelConfig.SupportsParameter(parameter.name) {
+			return fmt.Errorf("%s is not supported by model %s, the supported parameters are %v", parameter.name, threadExecutionParamsTemplate.Model, modelConfig.GetSupportedParameters())
+		}
+	}
+	if threadExecutionParamsTemplate.Temperature < 0 || threadExecutionParamsTemplate.TopP < 0 || threadExecutionParamsTemplate.TopP > 1 {
+		return errors.New("temperature must not be negative and top_p must be between 0 and 1")
+	}
+	if threadExecutionParamsTemplate.MaxTokens < 0 || threadExecutionParamsTemplate.MaxCompletionTokens < 0 || threadExecutionParamsTemplate.MaxOutputTokens < 0 || threadExecutionParamsTemplate.Timeout < 0 {
+		return errors.New("the token limits and the timeout must not be negative")
+	}
+
+	if err := modelConfig.ValidateResponseFormat(threadExecutionParamsTemplate.ResponseFormat); err != nil {
+		return err
+	}
+	// the native anthropic client does not implement structured outputs
+	if threadExecutionParamsTemplate.Client == models.TemplateClient_NATIVE && modelConfig.Protocol == base.PROTOCOL_ANTHROPIC && base.HasResponseFormat(threadExecutionParamsTemplate.ResponseFormat) {
+		return fmt.Errorf("response_format is not supported by the %s client of model %s", models.TemplateClient_NATIVE, threadExecutionParamsTemplate.Model)
+	}
+	return nil
+}
+
+// getTemplateModelConfig returns the config of the model of the template. The
+// litellm provider is not considered, the executor only routes the models of
+// the catalog through it.
+func getTemplateModelConfig(db *gorm.DB, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) (*base.ModelConfig, error) {
+	if local.IsLocalModel(threadExecutionParamsTemplate.Model) {
+		endpointName, _, err := local.ParseLocalModel(threadExecutionParamsTemplate.Model)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := models.GetProjectEndpointByName(db, threadExecutionParamsTemplate.ProjectID, endpointName); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("project endpoint %s not found", endpointName)
+			}
+			return nil, err
+		}
+		chatProvider, err := chat.GetChatCompletionsProvider(local.LOCAL_IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+		return chatProvider.GetModelConfig(), nil
+	}
+
+	chatProvider, err := chat.GetChatCompletionsProvider(threadExecutionParamsTemplate.Model)
+	isReserved := threadExecutionParamsTemplate.Model == string(chat.LITELLM) || threadExecutionParamsTemplate.Model == string(chat.LOCAL)
+	if err != nil || isReserved {
+		return nil, fmt.Errorf("unsupported model: %s, see /api/v1/models for the available models", threadExecutionParamsTemplate.Model)
+	}
+	return chatProvider.GetModelConfig(), nil
+}
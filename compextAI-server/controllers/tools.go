@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat"
+	"github.com/burnerlee/compextAI/internal/safehttp"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_MAX_TOOL_ITERATIONS = 5
+	MAX_TOOL_ITERATIONS         = 20
+	DEFAULT_TOOL_TIMEOUT        = 30 * time.Second
+	// the tool response is sent back to the model, keep it within reason
+	MAX_TOOL_RESPONSE_BODY_BYTES = 64 * 1024
+)
+
+// the tools are set by the users, they cannot target the network of the
+// server. The timeout of each tool is set on the request context.
+var toolClient = safehttp.NewClient(0)
+
+// addProjectTools offers the tools registered on the project to the model, in
+// addition to the tools of the request.
+func addProjectTools(db *gorm.DB, projectID string, tools []*models.ExecutionTool) ([]*models.ExecutionTool, error) {
+	projectTools, err := models.GetProjectTools(db, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting project tools: %w", err)
+	}
+
+	toolNames := make(map[string]bool)
+	for _, tool := range tools {
+		toolNames[tool.Name] = true
+	}
+	for _, projectTool := range projectTools {
+		if toolNames[projectTool.Name] {
+			return nil, fmt.Errorf("tool %s is already registered on the project", projectTool.Name)
+		}
+		tools = append(tools, projectTool.ExecutionTool())
+	}
+	return tools, nil
+}
+
+type toolCallRequest struct {
+	ToolCallID        string          `json:"tool_call_id"`
+	Name              string          `json:"name"`
+	Arguments         json.RawMessage `json:"arguments"`
+	ThreadExecutionID string          `json:"thread_execution_id"`
+}
+
+// runExecutionTools runs the tool calls of the response with the project
+// tools and executes the thread again with their results, until the model
+// stops calling tools. The response is returned as is when the model calls
+// a tool that is not registered on the project, the caller has to run it.
+func runExecutionTools(ctx context.Context, run *threadExecutionRun, chatSession chat.ChatCompletionsSession, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionResponse interface{}) (interface{}, error) {
+	if !run.payload.RunTools {
+		return threadExecutionResponse, nil
+	}
+
+	maxIterations := run.payload.MaxToolIterations
+	if maxIterations <= 0 {
+		maxIterations = DEFAULT_MAX_TOOL_ITERATIONS
+	}
+
+	projectTools, err := models.GetProjectTools(run.db, run.threadExecution.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting project tools: %w", err)
+	}
+	projectToolsByName := make(map[string]*models.ProjectTool)
+	for i := range projectTools {
+		projectToolsByName[projectTools[i].Name] = &projectTools[i]
+	}
+
+	for iteration := 1; ; iteration++ {
+		message, err := chatSession.ConvertExecutionResponseToMessage(threadExecutionResponse)
+		if err != nil {
+			return nil, fmt.Errorf("error converting thread execution response to message: %w", err)
+		}
+		toolCalls, err := models.ParseToolCalls(message.ToolCalls)
+		if err != nil {
+			return nil, err
+		}
+		if len(toolCalls) == 0 {
+			return threadExecutionResponse, nil
+		}
+		for _, toolCall := range toolCalls {
+			if _, ok := projectToolsByName[toolCall.Function.Name]; !ok {
+				logger.GetLogger().Infof("Returning tool calls to the caller: %s: %s is not a project tool", run.threadExecution.Identifier, toolCall.Function.Name)
+				return threadExecutionResponse, nil
+			}
+		}
+		if iteration > maxIterations {
+			return nil, fmt.Errorf("model kept calling tools after %d iterations", maxIterations)
+		}
+
+		toolMessages := []*models.Message{{
+			Role:       message.Role,
+			ContentMap: message.ContentMap,
+			Metadata:   message.Metadata,
+			ToolCalls:  message.ToolCalls,
+		}}
+		for _, toolCall := range toolCalls {
+			result := invokeProjectTool(ctx, run.db, projectToolsByName[toolCall.Function.Name], &toolCall, run.threadExecution.Identifier)
+			contentMapJson, err := json.Marshal(map[string]interface{}{
+				"content": result,
+			})
+			if err != nil {
+				return nil, err
+			}
+			toolMessages = append(toolMessages, &models.Message{
+				Role:       "tool",
+				ToolCallID: toolCall.ID,
+				ContentMap: contentMapJson,
+			})
+		}
+
+		if run.payload.AppendAssistantResponse {
+			for _, toolMessage := range toolMessages {
+				toolMessage.ThreadID = run.threadExecution.ThreadID
+				if err := models.CreateMessage(run.db, toolMessage); err != nil {
+					return nil, fmt.Errorf("error creating tool message: %w", err)
+				}
+			}
+		}
+		run.payload.Messages = append(run.payload.Messages, toolMessages...)
+
+		statusCode, response, _, err := run.execute(ctx, chatSession, threadExecutionParamsTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("error executing thread: %v: %v", err, response)
+		}
+		if statusCode != http.StatusOK {
+			return nil, fmt.Errorf("status code: %d: %v", statusCode, response)
+		}
+		threadExecutionResponse = response
+	}
+}
+
+// invokeProjectTool posts the tool call to the tool and returns the content
+// of the tool message. Failures are returned to the model as the result of
+// the call, so that it can recover from them.
+func invokeProjectTool(ctx context.Context, db *gorm.DB, projectTool *models.ProjectTool, toolCall *models.ToolCall, threadExecutionID string) string {
+	arguments := json.RawMessage(toolCall.Function.Arguments)
+	if !json.Valid(arguments) {
+		return fmt.Sprintf("error: the arguments are not valid json: %s", toolCall.Function.Arguments)
+	}
+
+	body, err := json.Marshal(toolCallRequest{
+		ToolCallID:        toolCall.ID,
+		Name:              toolCall.Function.Name,
+		Arguments:         arguments,
+		ThreadExecutionID: threadExecutionID,
+	})
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+
+	// tool calls are signed like the webhooks, so the tool can check their origin
+	secret, err := GetProjectWebhookSecret(db, projectTool.ProjectID)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting project webhook secret: %s: %v", projectTool.ProjectID, err)
+		return "error: the tool could not be called"
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	timeout := DEFAULT_TOOL_TIMEOUT
+	if projectTool.Timeout > 0 {
+		timeout = time.Duration(projectTool.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, projectTool.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WEBHOOK_TIMESTAMP_HEADER, timestamp)
+	request.Header.Set(WEBHOOK_SIGNATURE_HEADER, signWebhookPayload(secret, timestamp, body))
+
+	response, err := toolClient.Do(request)
+	if err != nil {
+		logger.GetLogger().Errorf("Error calling tool: %s: %v", projectTool.Name, err)
+		return fmt.Sprintf("error: the tool could not be reached: %v", err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(response.Body, MAX_TOOL_RESPONSE_BODY_BYTES))
+	if err != nil {
+		return fmt.Sprintf("error: reading the tool response: %v", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		logger.GetLogger().Errorf("Tool responded with status code: %s: %d", projectTool.Name, response.StatusCode)
+		return fmt.Sprintf("error: the tool responded with status code %d: %s", response.StatusCode, responseBody)
+	}
+	return string(responseBody)
+}
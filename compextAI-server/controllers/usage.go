@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+// executionUsage is the usage of the calls that served an execution, the tool
+// iterations make several calls for a single execution
+type executionUsage struct {
+	base.Usage
+	// in USD, every call is priced with the model of its template
+	cost float64
+}
+
+// addUsage adds the usage of a successful call to the usage of the run. Usage
+// that can't be read is logged and left out, it never fails the execution.
+func (r *threadExecutionRun) addUsage(chatSession chat.ChatCompletionsSession, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, response interface{}) {
+	usage, err := chatSession.ParseUsage(response)
+	if err != nil {
+		logger.GetLogger().Errorf("Error parsing usage: %s: %v", r.threadExecution.Identifier, err)
+		return
+	}
+	r.usage.Add(usage)
+
+	modelConfig, err := getTemplateModelConfig(r.db, threadExecutionParamsTemplate)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting model config for the cost: %s: %v", threadExecutionParamsTemplate.Model, err)
+		return
+	}
+	r.usage.cost += modelConfig.Cost(usage)
+}
+
+func (u *executionUsage) apply(threadExecution *models.ThreadExecution) {
+	threadExecution.PromptTokens = u.PromptTokens
+	threadExecution.CompletionTokens = u.CompletionTokens
+	threadExecution.CachedTokens = u.CachedTokens
+	threadExecution.ReasoningTokens = u.ReasoningTokens
+	threadExecution.TotalTokens = u.TotalTokens
+	threadExecution.Cost = u.cost
+}
+
+func (u *executionUsage) isEmpty() bool {
+	return u == nil || (u.TotalTokens == 0 && u.cost == 0)
+}
+
+// recordExecutionUsage stores the usage on an execution that was finished
+// without it, e.g. cancelled by the user while its calls were running, and
+// checks the budgets of the project against it
+func recordExecutionUsage(db *gorm.DB, threadExecution *models.ThreadExecution, usage *executionUsage) {
+	if usage.isEmpty() {
+		return
+	}
+
+	updatedThreadExecution := models.ThreadExecution{
+		Base: models.Base{
+			Identifier: threadExecution.Identifier,
+		},
+	}
+	usage.apply(&updatedThreadExecution)
+	if err := models.UpdateThreadExecution(db, &updatedThreadExecution); err != nil {
+		logger.GetLogger().Errorf("Error recording execution usage: %s: %v", threadExecution.Identifier, err)
+		return
+	}
+	notifyProjectBudgetThresholds(db, threadExecution.ProjectID, threadExecution.APIKeyID)
+}
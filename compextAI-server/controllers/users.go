@@ -2,51 +2,141 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/burnerlee/compextAI/internal/logger"
 	"github.com/burnerlee/compextAI/models"
 	"github.com/burnerlee/compextAI/utils"
 	"gorm.io/gorm"
 )
 
-func CreateUser(db *gorm.DB, request *CreateUserRequest) (*models.User, error) {
+// the sessions of the dashboard expire after this duration, the user logs in
+// again afterwards
+const SESSION_DURATION = 24 * time.Hour
+
+// ErrInvalidCredentials is returned for an unknown username as well as for a
+// wrong password, so that the usernames cannot be probed
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// CreateUser creates the user along with a default api key, which is
+// returned with the user
+func CreateUser(db *gorm.DB, request *CreateUserRequest) (*models.User, string, error) {
+	passwordHash, err := utils.HashPassword(request.Password)
+	if err != nil {
+		return nil, "", err
+	}
 
 	user := &models.User{
 		Base: models.Base{
 			Identifier: request.Username,
 		},
 		Username: request.Username,
-		Password: request.Password,
+		Password: passwordHash,
 		Email:    request.Email,
 	}
 
-	apiToken, err := utils.GenerateRandomString(32)
+	var key string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := models.CreateUser(tx, user); err != nil {
+			return err
+		}
+
+		var err error
+		_, key, err = CreateAPIKey(tx, &CreateAPIKeyRequest{
+			UserID: user.ID,
+			Name:   DEFAULT_API_KEY_NAME,
+			Scope:  models.APIKeyScope_EXECUTE,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, key, nil
+}
+
+// Login checks the credentials of the user and starts a session. The token of
+// the session is only returned here, the database keeps its hash.
+func Login(db *gorm.DB, request *LoginRequest) (*models.Session, string, error) {
+	user, err := models.GetUserByUsername(db, request.Username)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.CheckMissingUserPassword(request.Password)
+			return nil, "", ErrInvalidCredentials
+		}
+		return nil, "", err
 	}
-	user.APIToken = apiToken
 
-	tx := db.Begin()
-	if err := models.CreateUser(tx, user); err != nil {
-		tx.Rollback()
-		return nil, err
+	if !utils.CheckPassword(user.Password, request.Password) {
+		return nil, "", ErrInvalidCredentials
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
+	// a plaintext password left by an instance running an older version is
+	// hashed as soon as it is used
+	if !utils.IsPasswordHash(user.Password) {
+		if err := hashUserPassword(db, user.ID, request.Password); err != nil {
+			logger.GetLogger().Errorf("Error hashing password: %s: %v", user.Username, err)
+		}
 	}
 
-	return user, nil
+	sessionToken, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+	sessionToken = fmt.Sprintf("%s%s", constants.SESSION_TOKEN_PREFIX, sessionToken)
+
+	session, err := models.CreateSession(db, &models.Session{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(sessionToken),
+		UserAgent: request.UserAgent,
+		ExpiresAt: time.Now().Add(SESSION_DURATION),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return session, sessionToken, nil
 }
 
-func Login(db *gorm.DB, request *LoginRequest) (*models.User, error) {
-	user, err := models.GetUserByUsername(db, request.Username)
+func hashUserPassword(db *gorm.DB, userID uint, password string) error {
+	passwordHash, err := utils.HashPassword(password)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return models.UpdateUserPassword(db, userID, passwordHash)
+}
 
-	if user.Password != request.Password {
-		return nil, errors.New("invalid password")
+// HashPlaintextPasswords hashes the passwords of the users created before the
+// passwords were hashed, it runs with the migrations. The passwords that
+// cannot be hashed, e.g. longer than 72 bytes, are left in plaintext and
+// logged, they must not keep the server from starting.
+func HashPlaintextPasswords(db *gorm.DB) error {
+	users, err := models.GetUsersWithPlaintextPasswords(db)
+	if err != nil {
+		return fmt.Errorf("error getting users with plaintext passwords: %w", err)
 	}
 
-	return user, nil
+	hashed := 0
+	for _, user := range users {
+		if utils.IsPasswordHash(user.Password) {
+			continue
+		}
+		passwordHash, err := utils.HashPassword(user.Password)
+		if err != nil {
+			logger.GetLogger().Warnf("Skipping the plaintext password of user %s: %v", user.Username, err)
+			continue
+		}
+		if err := models.UpdateUserPassword(db, user.ID, passwordHash); err != nil {
+			return fmt.Errorf("error hashing password of user %s: %w", user.Username, err)
+		}
+		hashed++
+	}
+
+	if hashed > 0 {
+		logger.GetLogger().Infof("Hashed the plaintext passwords of %d users", hashed)
+	}
+	return nil
 }
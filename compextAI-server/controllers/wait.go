@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const DEFAULT_EXECUTION_WAIT_POLL_INTERVAL = 500 * time.Millisecond
+
+// WaitForThreadExecution blocks until the execution leaves the in progress
+// status and returns it. It returns the error of ctx when ctx is done first.
+func WaitForThreadExecution(ctx context.Context, db *gorm.DB, executionID string, pollInterval time.Duration) (*models.ThreadExecution, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := models.GetThreadExecutionStatus(db, executionID)
+		if err != nil {
+			return nil, err
+		}
+		if status != models.ThreadExecutionStatus_IN_PROGRESS {
+			return models.GetThreadExecutionByID(db, executionID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
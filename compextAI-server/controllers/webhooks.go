@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/safehttp"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_WEBHOOK_WORKERS         = 2
+	MAX_WEBHOOK_DELIVERY_ATTEMPTS   = 6
+	WEBHOOK_INITIAL_BACKOFF         = 10 * time.Second
+	WEBHOOK_MAX_BACKOFF             = 30 * time.Minute
+	WEBHOOK_REQUEST_TIMEOUT         = 10 * time.Second
+	MAX_WEBHOOK_RESPONSE_BODY_BYTES = 4096
+
+	WEBHOOK_EVENT_HEADER     = "X-Compext-Event"
+	WEBHOOK_DELIVERY_HEADER  = "X-Compext-Delivery"
+	WEBHOOK_TIMESTAMP_HEADER = "X-Compext-Timestamp"
+	// hex encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the project
+	// webhook secret, prefixed with "sha256="
+	WEBHOOK_SIGNATURE_HEADER = "X-Compext-Signature"
+)
+
+var webhookDispatcher *WebhookDispatcher
+
+type threadExecutionWebhookPayload struct {
+	Event             string            `json:"event"`
+	ThreadExecutionID string            `json:"thread_execution_id"`
+	ThreadID          string            `json:"thread_id"`
+	ProjectID         string            `json:"project_id"`
+	Status            string            `json:"status"`
+	Content           string            `json:"content"`
+	Role              string            `json:"role"`
+	Usage             interface{}       `json:"usage"`
+	ToolCalls         []models.ToolCall `json:"tool_calls"`
+	Error             interface{}       `json:"error,omitempty"`
+	Metadata          json.RawMessage   `json:"metadata"`
+	Timestamp         time.Time         `json:"timestamp"`
+}
+
+// notifyThreadExecutionFinished queues a webhook delivery to the webhook url
+// of the execution and to every webhook of its project. Failures are logged,
+// they never affect the execution itself.
+func notifyThreadExecutionFinished(db *gorm.DB, executionID string) {
+	threadExecution, err := models.GetThreadExecutionByID(db, executionID)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting thread execution for webhooks: %s: %v", executionID, err)
+		return
+	}
+
+	payload, err := buildThreadExecutionWebhookPayload(threadExecution)
+	if err != nil {
+		logger.GetLogger().Errorf("Error building webhook payload: %s: %v", executionID, err)
+		return
+	}
+	queueWebhookDeliveries(db, threadExecution.ProjectID, threadExecution.Identifier, threadExecution.WebhookURL, payload.Event, payload)
+}
+
+// queueWebhookDeliveries queues a delivery of the payload to every webhook of
+// the project, and to the webhook url when it is set.
+func queueWebhookDeliveries(db *gorm.DB, projectID, threadExecutionID, webhookURL, event string, payload interface{}) {
+	projectWebhooks, err := models.GetProjectWebhooks(db, projectID)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting project webhooks: %s: %v", projectID, err)
+		return
+	}
+
+	targets := make([]models.ProjectWebhook, 0)
+	if webhookURL != "" {
+		targets = append(targets, models.ProjectWebhook{URL: webhookURL})
+	}
+	for _, projectWebhook := range projectWebhooks {
+		if projectWebhook.URL == webhookURL {
+			continue
+		}
+		targets = append(targets, projectWebhook)
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		logger.GetLogger().Errorf("Error marshalling webhook payload: %s: %v", event, err)
+		return
+	}
+
+	for _, target := range targets {
+		if _, err := models.CreateWebhookDelivery(db, &models.WebhookDelivery{
+			ProjectID:         projectID,
+			ThreadExecutionID: threadExecutionID,
+			WebhookID:         target.Identifier,
+			URL:               target.URL,
+			Event:             event,
+			Payload:           payloadJson,
+		}); err != nil {
+			logger.GetLogger().Errorf("Error creating webhook delivery: %s: %v", target.URL, err)
+		}
+	}
+
+	if webhookDispatcher != nil {
+		webhookDispatcher.notify()
+	}
+}
+
+func buildThreadExecutionWebhookPayload(threadExecution *models.ThreadExecution) (*threadExecutionWebhookPayload, error) {
+	payload := &threadExecutionWebhookPayload{
+		Event:             fmt.Sprintf("thread_execution.%s", threadExecution.Status),
+		ThreadExecutionID: threadExecution.Identifier,
+		ThreadID:          threadExecution.ThreadID,
+		ProjectID:         threadExecution.ProjectID,
+		Status:            threadExecution.Status,
+		Content:           threadExecution.Content,
+		Role:              threadExecution.Role,
+		Metadata:          threadExecution.Metadata,
+		Timestamp:         time.Now().UTC(),
+	}
+
+	toolCalls, err := models.ParseToolCalls(threadExecution.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	payload.ToolCalls = toolCalls
+
+	if len(threadExecution.ExecutionResponseMetadata) > 0 {
+		var responseMetadata map[string]interface{}
+		if err := json.Unmarshal(threadExecution.ExecutionResponseMetadata, &responseMetadata); err != nil {
+			return nil, fmt.Errorf("error unmarshalling execution response metadata: %w", err)
+		}
+		payload.Usage = responseMetadata["usage"]
+	}
+
+	if threadExecution.Status != models.ThreadExecutionStatus_COMPLETED && len(threadExecution.Output) > 0 {
+		var output map[string]interface{}
+		if err := json.Unmarshal(threadExecution.Output, &output); err != nil {
+			return nil, fmt.Errorf("error unmarshalling execution output: %w", err)
+		}
+		payload.Error = output["error"]
+	}
+
+	return payload, nil
+}
+
+// GetProjectWebhookSecret returns the secret used to sign the webhook
+// deliveries of the project, generating it on first use.
+func GetProjectWebhookSecret(db *gorm.DB, projectID string) (string, error) {
+	project, err := models.GetProject(db, projectID)
+	if err != nil {
+		return "", err
+	}
+	if project.WebhookSecret != "" {
+		return project.WebhookSecret, nil
+	}
+
+	webhookSecret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	if err := models.SetProjectWebhookSecretIfEmpty(db, projectID, webhookSecret); err != nil {
+		return "", err
+	}
+
+	// read it back, another request may have set the secret first
+	project, err = models.GetProject(db, projectID)
+	if err != nil {
+		return "", err
+	}
+	return project.WebhookSecret, nil
+}
+
+func signWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := WEBHOOK_INITIAL_BACKOFF
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= WEBHOOK_MAX_BACKOFF {
+			return WEBHOOK_MAX_BACKOFF
+		}
+	}
+	return backoff
+}
+
+// WebhookDispatcher sends the pending webhook deliveries, retrying failed
+// ones with an exponential backoff.
+type WebhookDispatcher struct {
+	db           *gorm.DB
+	pollInterval time.Duration
+	wake         chan struct{}
+	client       *http.Client
+}
+
+func StartWebhookDispatcher(ctx context.Context, db *gorm.DB, workers int, pollInterval time.Duration) *WebhookDispatcher {
+	if workers <= 0 {
+		workers = DEFAULT_WEBHOOK_WORKERS
+	}
+	if pollInterval <= 0 {
+		pollInterval = DEFAULT_EXECUTION_POLL_INTERVAL
+	}
+
+	dispatcher := &WebhookDispatcher{
+		db:           db,
+		pollInterval: pollInterval,
+		wake:         make(chan struct{}, workers),
+		// the webhooks are set by the users, they cannot target the network
+		// of the server
+		client: safehttp.NewClient(WEBHOOK_REQUEST_TIMEOUT),
+	}
+
+	logger.GetLogger().Infof("Starting %d webhook workers", workers)
+	for i := 0; i < workers; i++ {
+		go pollQueue(ctx, fmt.Sprintf("Webhook worker %d", i), dispatcher.wake, pollInterval, dispatcher.processNext)
+	}
+
+	webhookDispatcher = dispatcher
+	return dispatcher
+}
+
+func (d *WebhookDispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *WebhookDispatcher) processNext() (bool, error) {
+	// the lease keeps other workers away while the request is in flight
+	delivery, err := models.ClaimNextWebhookDelivery(d.db, 2*WEBHOOK_REQUEST_TIMEOUT)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming webhook delivery: %w", err)
+	}
+
+	statusCode, responseBody, sendErr := d.send(delivery)
+	delivery.ResponseStatusCode = statusCode
+	delivery.ResponseBody = responseBody
+
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		delivery.Status = models.WebhookDeliveryStatus_DELIVERED
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= MAX_WEBHOOK_DELIVERY_ATTEMPTS:
+		delivery.Status = models.WebhookDeliveryStatus_FAILED
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.Status = models.WebhookDeliveryStatus_PENDING
+		delivery.NextAttemptAt = time.Now().Add(webhookBackoff(delivery.Attempts))
+		delivery.LastError = sendErr.Error()
+	}
+
+	if err := models.UpdateWebhookDeliveryAttempt(d.db, delivery); err != nil {
+		return true, fmt.Errorf("error updating webhook delivery: %s: %w", delivery.Identifier, err)
+	}
+	return true, nil
+}
+
+func (d *WebhookDispatcher) send(delivery *models.WebhookDelivery) (int, string, error) {
+	secret, err := GetProjectWebhookSecret(d.db, delivery.ProjectID)
+	if err != nil {
+		return 0, "", fmt.Errorf("error getting project webhook secret: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", fmt.Errorf("error creating request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WEBHOOK_EVENT_HEADER, delivery.Event)
+	request.Header.Set(WEBHOOK_DELIVERY_HEADER, delivery.Identifier)
+	request.Header.Set(WEBHOOK_TIMESTAMP_HEADER, timestamp)
+	request.Header.Set(WEBHOOK_SIGNATURE_HEADER, signWebhookPayload(secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, "", fmt.Errorf("error sending webhook: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, MAX_WEBHOOK_RESPONSE_BODY_BYTES))
+	if err != nil {
+		return response.StatusCode, "", fmt.Errorf("error reading webhook response: %w", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, string(body), fmt.Errorf("webhook responded with status code: %d", response.StatusCode)
+	}
+	return response.StatusCode, string(body), nil
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_EXECUTION_WORKERS       = 10
+	DEFAULT_EXECUTION_POLL_INTERVAL = 2 * time.Second
+)
+
+var executionWorkerPool *ExecutionWorkerPool
+
+// ExecutionWorkerPool runs a fixed number of workers that claim execution
+// jobs from the database queue. Jobs queue up in the database when all the
+// workers are busy instead of spawning a goroutine per execution.
+type ExecutionWorkerPool struct {
+	// cancels the executions in flight when the pool stops
+	ctx          context.Context
+	db           *gorm.DB
+	size         int
+	pollInterval time.Duration
+	wake         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// StartExecutionWorkerPool starts the workers, they run until ctx is done.
+func StartExecutionWorkerPool(ctx context.Context, db *gorm.DB, size int, pollInterval time.Duration) *ExecutionWorkerPool {
+	if size <= 0 {
+		size = DEFAULT_EXECUTION_WORKERS
+	}
+	if pollInterval <= 0 {
+		pollInterval = DEFAULT_EXECUTION_POLL_INTERVAL
+	}
+
+	pool := &ExecutionWorkerPool{
+		ctx:          ctx,
+		db:           db,
+		size:         size,
+		pollInterval: pollInterval,
+		wake:         make(chan struct{}, size),
+	}
+
+	logger.GetLogger().Infof("Starting %d execution workers", size)
+	for i := 0; i < size; i++ {
+		pool.wg.Add(1)
+		go pool.work(ctx, i)
+	}
+
+	executionWorkerPool = pool
+	return pool
+}
+
+// Wait blocks until all the workers have stopped.
+func (p *ExecutionWorkerPool) Wait() {
+	p.wg.Wait()
+}
+
+// notify wakes up an idle worker, if there is one, so that a freshly
+// enqueued job does not have to wait for the next poll.
+func (p *ExecutionWorkerPool) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *ExecutionWorkerPool) work(ctx context.Context, workerID int) {
+	defer p.wg.Done()
+	pollQueue(ctx, fmt.Sprintf("Execution worker %d", workerID), p.wake, p.pollInterval, p.processNext)
+}
+
+// pollQueue calls processNext until the queue is drained, then sleeps until
+// it is woken up or the poll interval elapses. It returns once ctx is done.
+func pollQueue(ctx context.Context, name string, wake <-chan struct{}, pollInterval time.Duration, processNext func() (bool, error)) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// drain the queue before going back to sleep
+		for ctx.Err() == nil {
+			processed, err := processNext()
+			if err != nil {
+				logger.GetLogger().Errorf("%s: %v", name, err)
+			}
+			if !processed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.GetLogger().Infof("%s stopped", name)
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNext claims and runs a single job. It reports whether a job was
+// claimed, so the worker knows whether the queue is drained.
+func (p *ExecutionWorkerPool) processNext() (bool, error) {
+	job, err := models.ClaimNextExecutionJob(p.db, EXECUTION_JOB_LEASE_DURATION)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming execution job: %w", err)
+	}
+
+	leaseCtx, stopLease := context.WithCancel(context.Background())
+	go p.renewLease(leaseCtx, job.Identifier)
+	jobErr := p.processExecutionJob(job)
+	stopLease()
+
+	// the pool stopped in the middle of the execution, which is left in
+	// progress for the next worker
+	if p.ctx.Err() != nil && errors.Is(jobErr, errExecutionCancelled) {
+		status, err := models.GetThreadExecutionStatus(p.db, job.ThreadExecutionID)
+		if err != nil {
+			return true, fmt.Errorf("error getting thread execution status: %s: %w", job.ThreadExecutionID, err)
+		}
+		if status == models.ThreadExecutionStatus_IN_PROGRESS {
+			if err := models.ReleaseExecutionJob(p.db, job.Identifier); err != nil {
+				return true, fmt.Errorf("error releasing execution job: %s: %w", job.Identifier, err)
+			}
+			logger.GetLogger().Infof("Released interrupted execution job: %s", job.Identifier)
+			return true, nil
+		}
+	}
+
+	status := models.ExecutionJobStatus_COMPLETED
+	if errors.Is(jobErr, errExecutionCancelled) {
+		status = models.ExecutionJobStatus_CANCELLED
+	} else if jobErr != nil {
+		status = models.ExecutionJobStatus_FAILED
+	}
+	if err := models.FinishExecutionJob(p.db, job.Identifier, status, jobErr); err != nil {
+		return true, fmt.Errorf("error finishing execution job: %s: %w", job.Identifier, err)
+	}
+	return true, nil
+}
+
+// renewLease keeps the lease of the job alive until ctx is done, so that the
+// recovery does not requeue a job that is still being run
+func (p *ExecutionWorkerPool) renewLease(ctx context.Context, jobID string) {
+	ticker := time.NewTicker(EXECUTION_JOB_LEASE_RENEW_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		running, err := models.RenewExecutionJobLease(p.db, jobID, EXECUTION_JOB_LEASE_DURATION)
+		if err != nil {
+			logger.GetLogger().Errorf("Error renewing execution job lease: %s: %v", jobID, err)
+			continue
+		}
+		if !running {
+			logger.GetLogger().Warnf("Execution job is no longer running, its lease expired: %s", jobID)
+			return
+		}
+	}
+}
+
+type executionJobPayload struct {
+	Messages                    []*models.Message       `json:"messages"`
+	ThreadExecutionSystemPrompt string                  `json:"thread_execution_system_prompt"`
+	AppendAssistantResponse     bool                    `json:"append_assistant_response"`
+	Tools                       []*models.ExecutionTool `json:"tools"`
+	Stream                      bool                    `json:"stream"`
+	FallbackTemplateIDs         []string                `json:"fallback_template_ids"`
+	RunTools                    bool                    `json:"run_tools"`
+	MaxToolIterations           int                     `json:"max_tool_iterations"`
+}
+
+func enqueueExecutionJob(db *gorm.DB, threadExecutionID string, payload *executionJobPayload) error {
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling execution job payload: %w", err)
+	}
+
+	if _, err := models.CreateExecutionJob(db, &models.ExecutionJob{
+		ThreadExecutionID: threadExecutionID,
+		Payload:           payloadJson,
+	}); err != nil {
+		return fmt.Errorf("error creating execution job: %w", err)
+	}
+
+	if executionWorkerPool != nil {
+		executionWorkerPool.notify()
+	}
+	return nil
+}
+
+func (p *ExecutionWorkerPool) processExecutionJob(job *models.ExecutionJob) error {
+	var payload executionJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("error unmarshalling execution job payload: %w", err)
+	}
+
+	threadExecution, err := models.GetThreadExecutionByID(p.db, job.ThreadExecutionID)
+	if err != nil {
+		return fmt.Errorf("error getting thread execution: %s: %w", job.ThreadExecutionID, err)
+	}
+
+	// the execution may have been cancelled after the job was queued
+	if threadExecution.Status == models.ThreadExecutionStatus_CANCELLED {
+		return errExecutionCancelled
+	}
+	if threadExecution.Status != models.ThreadExecutionStatus_IN_PROGRESS {
+		return fmt.Errorf("thread execution is: %s", threadExecution.Status)
+	}
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+
+	trackRunningExecution(threadExecution.Identifier, cancel)
+	defer untrackRunningExecution(threadExecution.Identifier)
+	go watchExecutionCancellation(ctx, p.db, threadExecution.Identifier, cancel, p.pollInterval)
+
+	if payload.Stream {
+		stream := openExecutionStream(threadExecution.Identifier)
+		defer closeExecutionStream(threadExecution.Identifier)
+		ctx = base.WithDeltaHandler(ctx, stream.publish)
+	}
+
+	err = runThreadExecution(ctx, p.db, threadExecution, &payload)
+	// cancelled executions are notified by the cancel request
+	if !errors.Is(err, errExecutionCancelled) {
+		notifyThreadExecutionFinished(p.db, threadExecution.Identifier)
+	}
+	return err
+}
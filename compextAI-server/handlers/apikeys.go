@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/burnerlee/compextAI/controllers"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// checkUnscopedRequest writes the error response when the request is made
+// with an api key scoped to a project, which cannot access the resources of
+// the user outside the project.
+func checkUnscopedRequest(w http.ResponseWriter, r *http.Request) bool {
+	if utils.GetProjectScopeFromRequest(r) != "" {
+		responses.Error(w, http.StatusForbidden, "the api key is scoped to a project")
+		return false
+	}
+	return true
+}
+
+func (s *Server) ListUserAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if _, ok := getSessionIDFromRequest(w, r); !ok {
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	apiKeys, err := models.GetUserAPIKeys(s.DB, uint(userID))
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, apiKeys)
+}
+
+func (s *Server) CreateUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	if _, ok := getSessionIDFromRequest(w, r); !ok {
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if request.ProjectID != "" {
+		hasAccess, err := utils.CheckProjectAccess(s.DB, request.ProjectID, uint(userID), "", models.Role_VIEWER)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				responses.Error(w, http.StatusNotFound, "project not found")
+				return
+			}
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !hasAccess {
+			responses.Error(w, http.StatusForbidden, "you do not have access to this project")
+			return
+		}
+	}
+
+	apiKey, key, err := controllers.CreateAPIKey(s.DB, &controllers.CreateAPIKeyRequest{
+		UserID:    uint(userID),
+		Name:      request.Name,
+		ProjectID: request.ProjectID,
+		Scope:     request.Scope,
+		ExpiresAt: request.ExpiresAt,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, CreateAPIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// getUserAPIKeyFromRequest loads the api key of the request and writes the
+// error response when it does not belong to the user.
+func (s *Server) getUserAPIKeyFromRequest(w http.ResponseWriter, r *http.Request) (*models.APIKey, bool) {
+	if _, ok := getSessionIDFromRequest(w, r); !ok {
+		return nil, false
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+
+	apiKey, err := models.GetAPIKey(s.DB, mux.Vars(r)["api_key_id"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "api key not found")
+			return nil, false
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if apiKey.UserID != uint(userID) {
+		responses.Error(w, http.StatusNotFound, "api key not found")
+		return nil, false
+	}
+	return apiKey, true
+}
+
+func (s *Server) RotateUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	apiKey, ok := s.getUserAPIKeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if apiKey.RevokedAt != nil {
+		responses.Error(w, http.StatusBadRequest, "the api key is revoked")
+		return
+	}
+
+	var request RotateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	newAPIKey, key, err := controllers.RotateAPIKey(s.DB, apiKey, time.Duration(request.GracePeriodSeconds)*time.Second)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, CreateAPIKeyResponse{APIKey: newAPIKey, Key: key})
+}
+
+func (s *Server) RevokeUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	apiKey, ok := s.getUserAPIKeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.RevokeAPIKey(s.DB, apiKey.Identifier); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "API key revoked successfully")
+}
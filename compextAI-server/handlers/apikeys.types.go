@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/burnerlee/compextAI/models"
+)
+
+// the old key of a rotation keeps working for at most this long
+const MAX_API_KEY_ROTATION_GRACE_PERIOD = 7 * 24 * time.Hour
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name"`
+	// restricts the key to the project, the key can access all the projects
+	// of the user when empty
+	ProjectID string `json:"project_id"`
+	// defaults to execute
+	Scope     string     `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (r *CreateAPIKeyRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.Scope == "" {
+		r.Scope = models.APIKeyScope_EXECUTE
+	}
+	if r.Scope != models.APIKeyScope_READ_ONLY && r.Scope != models.APIKeyScope_EXECUTE {
+		return errors.New("scope should be one of read_only, execute")
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at should be in the future")
+	}
+	return nil
+}
+
+type RotateAPIKeyRequest struct {
+	// the old key is revoked right away when 0
+	GracePeriodSeconds int `json:"grace_period_seconds"`
+}
+
+func (r *RotateAPIKeyRequest) Validate() error {
+	if r.GracePeriodSeconds < 0 {
+		return errors.New("grace_period_seconds should not be negative")
+	}
+	if time.Duration(r.GracePeriodSeconds)*time.Second > MAX_API_KEY_ROTATION_GRACE_PERIOD {
+		return errors.New("grace_period_seconds should be at most 7 days")
+	}
+	return nil
+}
+
+type CreateAPIKeyResponse struct {
+	*models.APIKey
+	// the key is only returned when it is created
+	Key string `json:"key"`
+}
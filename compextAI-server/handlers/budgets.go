@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (s *Server) ListProjectBudgets(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	budgets, err := models.GetProjectBudgets(s.DB, projectID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	now := time.Now()
+	budgetsResponse := make([]*ProjectBudgetResponse, 0, len(budgets))
+	for i := range budgets {
+		budget := &budgets[i]
+		periodStart := budget.GetPeriodStart(now)
+		spending, err := models.GetProjectSpending(s.DB, projectID, budget.APIKeyID, budget.Metric, periodStart)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		budgetsResponse = append(budgetsResponse, &ProjectBudgetResponse{
+			ProjectBudget: budget,
+			Spending:      spending,
+			PeriodStart:   periodStart,
+		})
+	}
+
+	responses.JSON(w, http.StatusOK, budgetsResponse)
+}
+
+func (s *Server) CreateProjectBudget(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	var request CreateProjectBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if request.APIKeyID != "" {
+		apiKey, err := models.GetAPIKey(s.DB, request.APIKeyID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err != nil || apiKey.UserID != userID || (apiKey.ProjectID != "" && apiKey.ProjectID != projectID) {
+			responses.Error(w, http.StatusBadRequest, "api key not found")
+			return
+		}
+	}
+
+	warningThresholdsJson, err := json.Marshal(sortBudgetWarningThresholds(*request.WarningThresholds))
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	budget, err := models.CreateProjectBudget(s.DB, &models.ProjectBudget{
+		UserID:            userID,
+		ProjectID:         projectID,
+		APIKeyID:          request.APIKeyID,
+		Period:            request.Period,
+		Metric:            request.Metric,
+		Limit:             request.Limit,
+		WarningThresholds: warningThresholdsJson,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, budget)
+}
+
+// getProjectBudgetFromRequest loads the budget of the request and writes the
+// error response when it does not belong to the project.
+func (s *Server) getProjectBudgetFromRequest(w http.ResponseWriter, r *http.Request, projectID string) (*models.ProjectBudget, bool) {
+	budget, err := models.GetProjectBudget(s.DB, mux.Vars(r)["budget_id"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "budget not found")
+			return nil, false
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if budget.ProjectID != projectID {
+		responses.Error(w, http.StatusNotFound, "budget not found")
+		return nil, false
+	}
+	return budget, true
+}
+
+func (s *Server) UpdateProjectBudget(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	budget, ok := s.getProjectBudgetFromRequest(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	var request UpdateProjectBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var warningThresholdsJson json.RawMessage
+	if request.WarningThresholds != nil {
+		var err error
+		warningThresholdsJson, err = json.Marshal(sortBudgetWarningThresholds(*request.WarningThresholds))
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err := models.UpdateProjectBudget(s.DB, &models.ProjectBudget{
+		Base: models.Base{
+			Identifier: budget.Identifier,
+		},
+		Limit:             request.Limit,
+		WarningThresholds: warningThresholdsJson,
+	}); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	updatedBudget, err := models.GetProjectBudget(s.DB, budget.Identifier)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, updatedBudget)
+}
+
+func (s *Server) DeleteProjectBudget(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	budget, ok := s.getProjectBudgetFromRequest(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteProjectBudget(s.DB, budget.Identifier); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Budget deleted successfully")
+}
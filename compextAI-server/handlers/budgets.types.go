@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/burnerlee/compextAI/models"
+)
+
+// the warning thresholds of the budgets created without them, in percent of
+// the limit
+var defaultBudgetWarningThresholds = []int{80, 100}
+
+type CreateProjectBudgetRequest struct {
+	Period string  `json:"period"`
+	Metric string  `json:"metric"`
+	Limit  float64 `json:"limit"`
+	// limits only the executions requested with the api key when set
+	APIKeyID string `json:"api_key_id"`
+	// nil uses the default thresholds, an empty list disables the warnings
+	WarningThresholds *[]int `json:"warning_thresholds"`
+}
+
+func (r *CreateProjectBudgetRequest) Validate() error {
+	if r.Period != models.BudgetPeriod_DAILY && r.Period != models.BudgetPeriod_MONTHLY {
+		return errors.New("period should be one of daily, monthly")
+	}
+	if r.Metric != models.BudgetMetric_TOKENS && r.Metric != models.BudgetMetric_COST {
+		return errors.New("metric should be one of tokens, cost")
+	}
+	if r.Limit <= 0 {
+		return errors.New("limit should be greater than 0")
+	}
+	if r.WarningThresholds == nil {
+		r.WarningThresholds = &defaultBudgetWarningThresholds
+	}
+	return validateBudgetWarningThresholds(*r.WarningThresholds)
+}
+
+type UpdateProjectBudgetRequest struct {
+	Limit             float64 `json:"limit"`
+	WarningThresholds *[]int  `json:"warning_thresholds"`
+}
+
+func (r *UpdateProjectBudgetRequest) Validate() error {
+	if r.Limit < 0 {
+		return errors.New("limit should be greater than 0")
+	}
+	if r.WarningThresholds != nil {
+		return validateBudgetWarningThresholds(*r.WarningThresholds)
+	}
+	return nil
+}
+
+func validateBudgetWarningThresholds(warningThresholds []int) error {
+	for _, threshold := range warningThresholds {
+		if threshold < 1 || threshold > 100 {
+			return errors.New("warning_thresholds should be percentages between 1 and 100")
+		}
+	}
+	return nil
+}
+
+// sortBudgetWarningThresholds returns the thresholds in increasing order
+// without duplicates
+func sortBudgetWarningThresholds(warningThresholds []int) []int {
+	sorted := slices.Clone(warningThresholds)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+type ProjectBudgetResponse struct {
+	*models.ProjectBudget
+	// the spending of the current period
+	Spending    float64   `json:"spending"`
+	PeriodStart time.Time `json:"period_start"`
+}
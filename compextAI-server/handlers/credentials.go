@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/burnerlee/compextAI/controllers"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// the credentials of the user are managed through /credentials, the
+// credentials of a project, overriding the ones of its owner, through
+// /project/{id}/credentials. The handlers below take the user and the
+// project, empty for the credentials of the user.
+
+// getUserCredentialScope returns the user of the request managing their own
+// credentials, which the api keys scoped to a project cannot do
+func getUserCredentialScope(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	if !checkUnscopedRequest(w, r) {
+		return 0, false
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+func newProviderCredentialResponse(credential *models.ProviderCredential) (*ProviderCredentialResponse, error) {
+	maskedValue, err := controllers.MaskProviderCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+	return &ProviderCredentialResponse{
+		ProviderCredential: credential,
+		MaskedValue:        maskedValue,
+	}, nil
+}
+
+// getProviderCredentialFromRequest loads the credential of the request and
+// writes the error response when it does not belong to the user or the
+// project.
+func (s *Server) getProviderCredentialFromRequest(w http.ResponseWriter, r *http.Request, userID uint, projectID string) (*models.ProviderCredential, bool) {
+	credential, err := models.GetProviderCredential(s.DB, mux.Vars(r)["credential_id"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "credential not found")
+			return nil, false
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if credential.ProjectID != projectID || (projectID == "" && credential.UserID != userID) {
+		responses.Error(w, http.StatusNotFound, "credential not found")
+		return nil, false
+	}
+	return credential, true
+}
+
+func (s *Server) listProviderCredentials(w http.ResponseWriter, userID uint, projectID string) {
+	var credentials []models.ProviderCredential
+	var err error
+	if projectID != "" {
+		credentials, err = models.GetProjectProviderCredentials(s.DB, projectID)
+	} else {
+		credentials, err = models.GetUserProviderCredentials(s.DB, userID)
+	}
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]*ProviderCredentialResponse, 0, len(credentials))
+	for i := range credentials {
+		credentialResponse, err := newProviderCredentialResponse(&credentials[i])
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		response = append(response, credentialResponse)
+	}
+
+	responses.JSON(w, http.StatusOK, response)
+}
+
+func (s *Server) createProviderCredential(w http.ResponseWriter, r *http.Request, userID uint, projectID string) {
+	var request CreateProviderCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// check if the credential name is already taken
+	if _, err := models.GetProviderCredentialByName(s.DB, userID, projectID, request.Provider, request.Name); err == nil {
+		responses.Error(w, http.StatusBadRequest, "credential name already taken")
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	credential, err := controllers.CreateProviderCredential(s.DB, &controllers.CreateProviderCredentialRequest{
+		UserID:    userID,
+		ProjectID: projectID,
+		Provider:  request.Provider,
+		Name:      request.Name,
+		Value:     request.GetValue(),
+		Endpoint:  request.Endpoint,
+		IsDefault: request.IsDefault,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response, err := newProviderCredentialResponse(credential)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, response)
+}
+
+func (s *Server) updateProviderCredential(w http.ResponseWriter, r *http.Request, userID uint, projectID string) {
+	credential, ok := s.getProviderCredentialFromRequest(w, r, userID, projectID)
+	if !ok {
+		return
+	}
+
+	var request UpdateProviderCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(credential.Provider); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if request.Name != "" && request.Name != credential.Name {
+		if _, err := models.GetProviderCredentialByName(s.DB, userID, projectID, credential.Provider, request.Name); err == nil {
+			responses.Error(w, http.StatusBadRequest, "credential name already taken")
+			return
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err := controllers.UpdateProviderCredential(s.DB, &controllers.UpdateProviderCredentialRequest{
+		Identifier: credential.Identifier,
+		Name:       request.Name,
+		Value:      request.GetValue(credential.Provider),
+		Endpoint:   request.Endpoint,
+	}); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeProviderCredential(w, credential.Identifier)
+}
+
+// writeProviderCredential reloads the credential and writes it masked
+func (s *Server) writeProviderCredential(w http.ResponseWriter, credentialID string) {
+	credential, err := models.GetProviderCredential(s.DB, credentialID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response, err := newProviderCredentialResponse(credential)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, response)
+}
+
+func (s *Server) setDefaultProviderCredential(w http.ResponseWriter, r *http.Request, userID uint, projectID string) {
+	credential, ok := s.getProviderCredentialFromRequest(w, r, userID, projectID)
+	if !ok {
+		return
+	}
+
+	if err := models.SetDefaultProviderCredential(s.DB, credential); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.writeProviderCredential(w, credential.Identifier)
+}
+
+func (s *Server) testProviderCredential(w http.ResponseWriter, r *http.Request, userID uint, projectID string) {
+	credential, ok := s.getProviderCredentialFromRequest(w, r, userID, projectID)
+	if !ok {
+		return
+	}
+
+	result, err := controllers.TestProviderCredential(r.Context(), s.DB, credential)
+	if err != nil {
+		responses.Error(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, TestProviderCredentialResponse{
+		Valid: result.Valid,
+		Error: result.Error,
+	})
+}
+
+func (s *Server) deleteProviderCredential(w http.ResponseWriter, r *http.Request, userID uint, projectID string) {
+	credential, ok := s.getProviderCredentialFromRequest(w, r, userID, projectID)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteProviderCredential(s.DB, credential); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Credential deleted successfully")
+}
+
+func (s *Server) ListUserCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserCredentialScope(w, r)
+	if !ok {
+		return
+	}
+	s.listProviderCredentials(w, userID, "")
+}
+
+func (s *Server) CreateUserCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserCredentialScope(w, r)
+	if !ok {
+		return
+	}
+	s.createProviderCredential(w, r, userID, "")
+}
+
+func (s *Server) UpdateUserCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserCredentialScope(w, r)
+	if !ok {
+		return
+	}
+	s.updateProviderCredential(w, r, userID, "")
+}
+
+func (s *Server) SetDefaultUserCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserCredentialScope(w, r)
+	if !ok {
+		return
+	}
+	s.setDefaultProviderCredential(w, r, userID, "")
+}
+
+func (s *Server) TestUserCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserCredentialScope(w, r)
+	if !ok {
+		return
+	}
+	s.testProviderCredential(w, r, userID, "")
+}
+
+func (s *Server) DeleteUserCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserCredentialScope(w, r)
+	if !ok {
+		return
+	}
+	s.deleteProviderCredential(w, r, userID, "")
+}
+
+func (s *Server) ListProjectCredentials(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+	s.listProviderCredentials(w, userID, projectID)
+}
+
+func (s *Server) CreateProjectCredential(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+	s.createProviderCredential(w, r, userID, projectID)
+}
+
+func (s *Server) UpdateProjectCredential(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+	s.updateProviderCredential(w, r, userID, projectID)
+}
+
+func (s *Server) SetDefaultProjectCredential(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+	s.setDefaultProviderCredential(w, r, userID, projectID)
+}
+
+func (s *Server) TestProjectCredential(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+	s.testProviderCredential(w, r, userID, projectID)
+}
+
+func (s *Server) DeleteProjectCredential(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+	s.deleteProviderCredential(w, r, userID, projectID)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/burnerlee/compextAI/internal/safehttp"
+	"github.com/burnerlee/compextAI/models"
+)
+
+// the hosts, ips and cidrs of the private azure deployments, comma separated,
+// the azure endpoints are otherwise limited to the azure openai resources
+const AZURE_ENDPOINT_ALLOWED_HOSTS_ENV = "AZURE_ENDPOINT_ALLOWED_HOSTS"
+
+var credentialNameRegex = regexp.MustCompile("^[a-zA-Z0-9_-]{1,64}$")
+
+// the domains of the azure openai resources
+var azureEndpointSuffixes = []string{".openai.azure.com", ".cognitiveservices.azure.com"}
+
+var azureEndpointAllowlist *safehttp.Allowlist
+
+// loadAzureEndpointAllowlistFromEnv loads the allowlist of
+// AZURE_ENDPOINT_ALLOWED_HOSTS, it runs at startup
+func loadAzureEndpointAllowlistFromEnv() error {
+	allowlist, err := safehttp.ParseAllowlist(os.Getenv(AZURE_ENDPOINT_ALLOWED_HOSTS_ENV))
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %w", AZURE_ENDPOINT_ALLOWED_HOSTS_ENV, err)
+	}
+	azureEndpointAllowlist = allowlist
+	return nil
+}
+
+// validateAzureEndpoint checks that the endpoint is an azure openai resource
+// or an allowed private deployment, the executor calls it from its own
+// network
+func validateAzureEndpoint(endpoint string) error {
+	if err := validateHTTPURL(endpoint); err != nil {
+		return err
+	}
+	parsedURL, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid url: %s", endpoint)
+	}
+	if parsedURL.Scheme != "https" {
+		return fmt.Errorf("azure endpoint must be an https url: %s", endpoint)
+	}
+
+	host := strings.ToLower(parsedURL.Hostname())
+	if azureEndpointAllowlist.AllowsHost(host) {
+		return nil
+	}
+	isAzureHost := false
+	for _, suffix := range azureEndpointSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			isAzureHost = true
+			break
+		}
+	}
+	if !isAzureHost {
+		return fmt.Errorf("azure endpoint must be an azure openai resource, e.g. https://<resource>%s", azureEndpointSuffixes[0])
+	}
+	return safehttp.ValidateURL(endpoint)
+}
+
+// validateServiceAccountCreds checks that the credentials are the json key of
+// a google service account
+func validateServiceAccountCreds(creds string) error {
+	var serviceAccount struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal([]byte(creds), &serviceAccount); err != nil {
+		return fmt.Errorf("invalid service account credentials: %w", err)
+	}
+	if serviceAccount.Type != "service_account" || serviceAccount.ClientEmail == "" || serviceAccount.PrivateKey == "" {
+		return errors.New("service account credentials should be the json key of a service account")
+	}
+	return nil
+}
+
+// getCredentialValue returns the value of the credential of the request, the
+// google credentials are a service account and the others a key
+func getCredentialValue(provider, value string, serviceAccountCreds json.RawMessage) string {
+	if provider == models.CredentialProvider_GOOGLE {
+		// a null service account is not set
+		if string(serviceAccountCreds) == "null" {
+			return ""
+		}
+		return string(serviceAccountCreds)
+	}
+	return value
+}
+
+// validateCredential checks the value and the endpoint of a credential of the
+// provider, the empty fields are not checked
+func validateCredential(provider, value, endpoint string) error {
+	if provider == models.CredentialProvider_GOOGLE && value != "" {
+		if err := validateServiceAccountCreds(value); err != nil {
+			return err
+		}
+	}
+	if endpoint != "" {
+		if provider != models.CredentialProvider_AZURE {
+			return errors.New("only the azure credentials have an endpoint")
+		}
+		return validateAzureEndpoint(endpoint)
+	}
+	return nil
+}
+
+type CreateProviderCredentialRequest struct {
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
+	// the key of the provider
+	Value string `json:"value"`
+	// the json key of the service account of the google credentials
+	ServiceAccountCreds json.RawMessage `json:"service_account_creds"`
+	// the endpoint of the azure openai resource
+	Endpoint string `json:"endpoint"`
+	// the first credential of a provider is its default anyway
+	IsDefault bool `json:"is_default"`
+}
+
+func (r *CreateProviderCredentialRequest) Validate() error {
+	if !models.IsValidCredentialProvider(r.Provider) {
+		return fmt.Errorf("provider should be one of %s", strings.Join(models.CredentialProviders, ", "))
+	}
+	if !credentialNameRegex.MatchString(r.Name) {
+		return errors.New("name is required and should only contain letters, digits, underscores and hyphens")
+	}
+	value := r.GetValue()
+	if value == "" {
+		if r.Provider == models.CredentialProvider_GOOGLE {
+			return errors.New("service_account_creds is required")
+		}
+		return errors.New("value is required")
+	}
+	if r.Provider == models.CredentialProvider_AZURE && r.Endpoint == "" {
+		return errors.New("endpoint is required")
+	}
+	return validateCredential(r.Provider, value, r.Endpoint)
+}
+
+func (r *CreateProviderCredentialRequest) GetValue() string {
+	return getCredentialValue(r.Provider, r.Value, r.ServiceAccountCreds)
+}
+
+type UpdateProviderCredentialRequest struct {
+	Name                string          `json:"name"`
+	Value               string          `json:"value"`
+	ServiceAccountCreds json.RawMessage `json:"service_account_creds"`
+	Endpoint            string          `json:"endpoint"`
+}
+
+// Validate checks the request against the provider of the credential, the
+// provider cannot be changed
+func (r *UpdateProviderCredentialRequest) Validate(provider string) error {
+	if r.Name != "" && !credentialNameRegex.MatchString(r.Name) {
+		return errors.New("name should only contain letters, digits, underscores and hyphens")
+	}
+	return validateCredential(provider, r.GetValue(provider), r.Endpoint)
+}
+
+func (r *UpdateProviderCredentialRequest) GetValue(provider string) string {
+	return getCredentialValue(provider, r.Value, r.ServiceAccountCreds)
+}
+
+// ProviderCredentialResponse shows the end of the key, or the email of the
+// service account of the google credentials
+type ProviderCredentialResponse struct {
+	*models.ProviderCredential
+	MaskedValue string `json:"masked_value"`
+}
+
+type TestProviderCredentialResponse struct {
+	Valid bool `json:"valid"`
+	// the reason the vendor rejected the credential
+	Error string `json:"error,omitempty"`
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/burnerlee/compextAI/constants"
+	"github.com/burnerlee/compextAI/controllers"
 	"github.com/burnerlee/compextAI/internal/logger"
 	"github.com/burnerlee/compextAI/models"
 	"github.com/joho/godotenv"
@@ -43,18 +44,34 @@ func InitDB() (*gorm.DB, error) {
 }
 
 func MigrateDB(db *gorm.DB) error {
-	if err := db.AutoMigrate(&models.Project{}, &models.Message{}, &models.Thread{}, &models.User{}, &models.ThreadExecution{}, &models.ThreadExecutionParams{}); err != nil {
+	if err := db.AutoMigrate(&models.Project{}, &models.Message{}, &models.Thread{}, &models.User{}, &models.ThreadExecution{}, &models.ThreadExecutionParams{}, &models.ExecutionJob{}, &models.ProjectWebhook{}, &models.WebhookDelivery{}, &models.IdempotencyKey{}, &models.ProjectTool{}, &models.ProjectEndpoint{}, &models.ProjectBudget{}, &models.RateLimit{}, &models.Session{}, &models.APIKey{}, &models.Organization{}, &models.OrganizationMember{}, &models.ProjectMember{}, &models.Invitation{}, &models.ProviderCredential{}); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	adminUser, err := models.GetUserByUsername(db, "admin")
+	if err := controllers.HashPlaintextPasswords(db); err != nil {
+		return fmt.Errorf("failed to hash plaintext passwords: %w", err)
+	}
+
+	if err := controllers.MigrateLegacyAPITokens(db); err != nil {
+		return fmt.Errorf("failed to migrate api tokens: %w", err)
+	}
+
+	if err := controllers.MigrateUserCredentials(db); err != nil {
+		return fmt.Errorf("failed to migrate credentials: %w", err)
+	}
+
+	if err := controllers.ReencryptProviderCredentials(db); err != nil {
+		return fmt.Errorf("failed to re-encrypt credentials: %w", err)
+	}
+
+	adminUser, err := models.GetUserByUsername(db, constants.ADMIN_USERNAME)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			adminUser = &models.User{
 				Base: models.Base{
-					Identifier: "admin",
+					Identifier: constants.ADMIN_USERNAME,
 				},
-				Username: "admin",
+				Username: constants.ADMIN_USERNAME,
 			}
 			if err := db.Create(adminUser).Error; err != nil {
 				return fmt.Errorf("failed to create admin user: %w", err)
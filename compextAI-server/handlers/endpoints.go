@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/burnerlee/compextAI/controllers"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (s *Server) ListProjectEndpoints(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	endpoints, err := models.GetProjectEndpoints(s.DB, projectID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, endpoints)
+}
+
+func (s *Server) CreateProjectEndpoint(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	var request CreateProjectEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// check if the endpoint name is already taken
+	if _, err := models.GetProjectEndpointByName(s.DB, projectID, request.Name); err == nil {
+		responses.Error(w, http.StatusBadRequest, "endpoint name already taken")
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	endpoint, err := controllers.CreateProjectEndpoint(s.DB, &models.ProjectEndpoint{
+		UserID:    userID,
+		ProjectID: projectID,
+		Name:      request.Name,
+		BaseURL:   request.BaseURL,
+		APIKey:    request.APIKey,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, endpoint)
+}
+
+// getProjectEndpointFromRequest loads the endpoint of the request and writes
+// the error response when it does not belong to the project.
+func (s *Server) getProjectEndpointFromRequest(w http.ResponseWriter, r *http.Request, projectID string) (*models.ProjectEndpoint, bool) {
+	endpoint, err := models.GetProjectEndpoint(s.DB, mux.Vars(r)["endpoint_id"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "endpoint not found")
+			return nil, false
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if endpoint.ProjectID != projectID {
+		responses.Error(w, http.StatusNotFound, "endpoint not found")
+		return nil, false
+	}
+	return endpoint, true
+}
+
+func (s *Server) UpdateProjectEndpoint(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	endpoint, ok := s.getProjectEndpointFromRequest(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	var request UpdateProjectEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := controllers.UpdateProjectEndpoint(s.DB, &models.ProjectEndpoint{
+		Base: models.Base{
+			Identifier: endpoint.Identifier,
+		},
+		BaseURL: request.BaseURL,
+		APIKey:  request.APIKey,
+	}); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	updatedEndpoint, err := models.GetProjectEndpoint(s.DB, endpoint.Identifier)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, updatedEndpoint)
+}
+
+func (s *Server) DeleteProjectEndpoint(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	endpoint, ok := s.getProjectEndpointFromRequest(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteProjectEndpoint(s.DB, endpoint.Identifier); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Endpoint deleted successfully")
+}
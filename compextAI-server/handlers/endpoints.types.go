@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat/local"
+)
+
+// the endpoint name is part of the model names of the templates, so it
+// cannot contain slashes
+var endpointNameRegex = regexp.MustCompile("^[a-zA-Z0-9_-]{1,64}$")
+
+type CreateProjectEndpointRequest struct {
+	Name    string `json:"name"`
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+}
+
+func (r *CreateProjectEndpointRequest) Validate() error {
+	if !endpointNameRegex.MatchString(r.Name) {
+		return errors.New("name is required and should only contain letters, digits, underscores and hyphens")
+	}
+	if r.BaseURL == "" {
+		return errors.New("base_url is required")
+	}
+	return validateEndpointURL(r.BaseURL)
+}
+
+type UpdateProjectEndpointRequest struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+}
+
+func (r *UpdateProjectEndpointRequest) Validate() error {
+	if r.BaseURL != "" {
+		return validateEndpointURL(r.BaseURL)
+	}
+	return nil
+}
+
+// validateEndpointURL only lets the endpoints target the private hosts
+// allowed by the admin, see LOCAL_ENDPOINT_ALLOWED_HOSTS
+func validateEndpointURL(baseURL string) error {
+	if err := validateHTTPURL(baseURL); err != nil {
+		return err
+	}
+	return local.ValidateEndpointURL(baseURL)
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"gorm.io/gorm"
 
+	"github.com/burnerlee/compextAI/controllers"
 	"github.com/burnerlee/compextAI/models"
 	"github.com/burnerlee/compextAI/utils"
 	"github.com/burnerlee/compextAI/utils/responses"
@@ -25,13 +27,13 @@ func (s *Server) ListThreadExecutionParams(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, projectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, projectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	executionParams, err := models.GetAllThreadExecutionParams(s.DB, uint(userID), projectID)
+	executionParams, err := models.GetAllThreadExecutionParams(s.DB, projectID)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -40,20 +42,25 @@ func (s *Server) ListThreadExecutionParams(w http.ResponseWriter, r *http.Reques
 	response := make(ExecuteParamsResponse, 0)
 	for _, executionParam := range executionParams {
 		response = append(response, &squashedThreadExecutionParams{
-			ProjectID:           executionParam.ProjectID,
-			Identifier:          executionParam.Identifier,
-			Name:                executionParam.Name,
-			Environment:         executionParam.Environment,
-			TemplateID:          executionParam.TemplateID,
-			Model:               executionParam.Template.Model,
-			Temperature:         executionParam.Template.Temperature,
-			Timeout:             executionParam.Template.Timeout,
-			MaxTokens:           executionParam.Template.MaxTokens,
-			MaxCompletionTokens: executionParam.Template.MaxCompletionTokens,
-			MaxOutputTokens:     executionParam.Template.MaxOutputTokens,
-			TopP:                executionParam.Template.TopP,
-			ResponseFormat:      executionParam.Template.ResponseFormat,
-			SystemPrompt:        executionParam.Template.SystemPrompt,
+			ProjectID:            executionParam.ProjectID,
+			Identifier:           executionParam.Identifier,
+			Name:                 executionParam.Name,
+			Environment:          executionParam.Environment,
+			TemplateID:           executionParam.TemplateID,
+			Model:                executionParam.Template.Model,
+			Temperature:          executionParam.Template.Temperature,
+			Timeout:              executionParam.Template.Timeout,
+			MaxTokens:            executionParam.Template.MaxTokens,
+			MaxCompletionTokens:  executionParam.Template.MaxCompletionTokens,
+			MaxOutputTokens:      executionParam.Template.MaxOutputTokens,
+			TopP:                 executionParam.Template.TopP,
+			ResponseFormat:       executionParam.Template.ResponseFormat,
+			SystemPrompt:         executionParam.Template.SystemPrompt,
+			MaxAttempts:          executionParam.Template.MaxAttempts,
+			RetryBackoff:         executionParam.Template.RetryBackoff,
+			RetryableStatusCodes: executionParam.Template.RetryableStatusCodes,
+			FallbackTemplateIDs:  executionParam.FallbackTemplateIDs,
+			Client:               executionParam.Template.Client,
 		})
 	}
 
@@ -77,14 +84,14 @@ func (s *Server) CreateThreadExecutionParams(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EDITOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// checking for existing execution params with the same name
-	_, err = models.GetThreadExecutionParamsByUserIDAndNameAndEnvironment(s.DB, uint(userID), request.Name, request.Environment, projectID)
+	_, err = models.GetThreadExecutionParamsByNameAndEnvironment(s.DB, request.Name, request.Environment, projectID)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			// no existing execution params with the same name
@@ -97,12 +104,27 @@ func (s *Server) CreateThreadExecutionParams(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := s.checkFallbackTemplatesAccess(projectID, request.FallbackTemplateIDs); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fallbackTemplateIDs, err := json.Marshal(request.FallbackTemplateIDs)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if request.FallbackTemplateIDs == nil {
+		fallbackTemplateIDs = json.RawMessage("[]")
+	}
+
 	executionParams := models.ThreadExecutionParams{
-		UserID:      uint(userID),
-		ProjectID:   projectID,
-		Name:        request.Name,
-		Environment: request.Environment,
-		TemplateID:  request.TemplateID,
+		UserID:              uint(userID),
+		ProjectID:           projectID,
+		Name:                request.Name,
+		Environment:         request.Environment,
+		TemplateID:          request.TemplateID,
+		FallbackTemplateIDs: fallbackTemplateIDs,
 	}
 
 	executionParamsCreated, err := models.CreateThreadExecutionParams(s.DB, &executionParams)
@@ -131,7 +153,7 @@ func (s *Server) GetThreadExecutionParamsByNameAndEnv(w http.ResponseWriter, r *
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -139,26 +161,31 @@ func (s *Server) GetThreadExecutionParamsByNameAndEnv(w http.ResponseWriter, r *
 
 	// no need to check access, because the user can only get his own execution params
 
-	executionParams, err := models.GetThreadExecutionParamsByUserIDAndNameAndEnvironment(s.DB, uint(userID), request.Name, request.Environment, projectID)
+	executionParams, err := models.GetThreadExecutionParamsByNameAndEnvironment(s.DB, request.Name, request.Environment, projectID)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	response := &squashedThreadExecutionParams{
-		Identifier:          executionParams.Identifier,
-		Name:                executionParams.Name,
-		Environment:         executionParams.Environment,
-		TemplateID:          executionParams.TemplateID,
-		Model:               executionParams.Template.Model,
-		Temperature:         executionParams.Template.Temperature,
-		Timeout:             executionParams.Template.Timeout,
-		MaxTokens:           executionParams.Template.MaxTokens,
-		MaxCompletionTokens: executionParams.Template.MaxCompletionTokens,
-		MaxOutputTokens:     executionParams.Template.MaxOutputTokens,
-		TopP:                executionParams.Template.TopP,
-		ResponseFormat:      executionParams.Template.ResponseFormat,
-		SystemPrompt:        executionParams.Template.SystemPrompt,
+		Identifier:           executionParams.Identifier,
+		Name:                 executionParams.Name,
+		Environment:          executionParams.Environment,
+		TemplateID:           executionParams.TemplateID,
+		Model:                executionParams.Template.Model,
+		Temperature:          executionParams.Template.Temperature,
+		Timeout:              executionParams.Template.Timeout,
+		MaxTokens:            executionParams.Template.MaxTokens,
+		MaxCompletionTokens:  executionParams.Template.MaxCompletionTokens,
+		MaxOutputTokens:      executionParams.Template.MaxOutputTokens,
+		TopP:                 executionParams.Template.TopP,
+		ResponseFormat:       executionParams.Template.ResponseFormat,
+		SystemPrompt:         executionParams.Template.SystemPrompt,
+		MaxAttempts:          executionParams.Template.MaxAttempts,
+		RetryBackoff:         executionParams.Template.RetryBackoff,
+		RetryableStatusCodes: executionParams.Template.RetryableStatusCodes,
+		FallbackTemplateIDs:  executionParams.FallbackTemplateIDs,
+		Client:               executionParams.Template.Client,
 	}
 
 	responses.JSON(w, http.StatusOK, response)
@@ -181,13 +208,13 @@ func (s *Server) UpdateThreadExecutionParams(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EDITOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	existingExecutionParams, err := models.GetThreadExecutionParamsByUserIDAndNameAndEnvironment(s.DB, uint(userID), request.Name, request.Environment, projectID)
+	existingExecutionParams, err := models.GetThreadExecutionParamsByNameAndEnvironment(s.DB, request.Name, request.Environment, projectID)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -198,6 +225,24 @@ func (s *Server) UpdateThreadExecutionParams(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if request.FallbackTemplateIDs != nil {
+		if err := s.checkFallbackTemplatesAccess(projectID, request.FallbackTemplateIDs); err != nil {
+			responses.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		fallbackTemplateIDs, err := json.Marshal(request.FallbackTemplateIDs)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := models.UpdateThreadExecutionParamsFallbackTemplateIDs(s.DB, existingExecutionParams.Identifier, fallbackTemplateIDs); err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	responses.JSON(w, http.StatusOK, "Execution params updated")
 }
 
@@ -218,13 +263,13 @@ func (s *Server) DeleteThreadExecutionParams(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EDITOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	existingExecutionParams, err := models.GetThreadExecutionParamsByUserIDAndNameAndEnvironment(s.DB, uint(userID), request.Name, request.Environment, projectID)
+	existingExecutionParams, err := models.GetThreadExecutionParamsByNameAndEnvironment(s.DB, request.Name, request.Environment, projectID)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -251,13 +296,13 @@ func (s *Server) ListThreadExecutionParamsTemplates(w http.ResponseWriter, r *ht
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, projectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, projectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	threadExecutionParamsTemplates, err := models.GetAllThreadExecutionParamsTemplates(s.DB, uint(userID), projectID)
+	threadExecutionParamsTemplates, err := models.GetAllThreadExecutionParamsTemplates(s.DB, projectID)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -289,7 +334,7 @@ func (s *Server) CreateThreadExecutionParamsTemplate(w http.ResponseWriter, r *h
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EDITOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -305,8 +350,26 @@ func (s *Server) CreateThreadExecutionParamsTemplate(w http.ResponseWriter, r *h
 		MaxTokens:           request.MaxTokens,
 		MaxCompletionTokens: request.MaxCompletionTokens,
 		MaxOutputTokens:     request.MaxOutputTokens,
+		TopP:                request.TopP,
 		SystemPrompt:        request.SystemPrompt,
 		ResponseFormat:      responseFormat,
+		MaxAttempts:         request.MaxAttempts,
+		RetryBackoff:        request.RetryBackoff,
+		Client:              request.Client,
+	}
+
+	if request.RetryableStatusCodes != nil {
+		retryableStatusCodes, err := json.Marshal(request.RetryableStatusCodes)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		threadExecutionParamsTemplate.RetryableStatusCodes = retryableStatusCodes
+	}
+
+	if err := controllers.ValidateThreadExecutionParamsTemplate(s.DB, &threadExecutionParamsTemplate); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	threadExecutionParamsTemplateCreated, err := models.CreateThreadExecutionParamsTemplate(s.DB, &threadExecutionParamsTemplate)
@@ -330,7 +393,7 @@ func (s *Server) GetThreadExecutionParamsTemplateByID(w http.ResponseWriter, r *
 		responses.Error(w, http.StatusUnauthorized, err.Error())
 		return
 	}
-	hasAccess, err := utils.CheckThreadExecutionParamsTemplateAccess(s.DB, templateID, uint(userID))
+	hasAccess, err := utils.CheckThreadExecutionParamsTemplateAccess(s.DB, templateID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -362,7 +425,7 @@ func (s *Server) DeleteThreadExecutionParamsTemplate(w http.ResponseWriter, r *h
 		return
 	}
 
-	hasAccess, err := utils.CheckThreadExecutionParamsTemplateAccess(s.DB, templateID, uint(userID))
+	hasAccess, err := utils.CheckThreadExecutionParamsTemplateAccess(s.DB, templateID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EDITOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -406,7 +469,7 @@ func (s *Server) UpdateThreadExecutionParamsTemplate(w http.ResponseWriter, r *h
 		return
 	}
 
-	hasAccess, err := utils.CheckThreadExecutionParamsTemplateAccess(s.DB, templateID, uint(userID))
+	hasAccess, err := utils.CheckThreadExecutionParamsTemplateAccess(s.DB, templateID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EDITOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -438,15 +501,58 @@ func (s *Server) UpdateThreadExecutionParamsTemplate(w http.ResponseWriter, r *h
 		return
 	}
 
-	threadExecutionParamsTemplate.Name = request.Name
-	threadExecutionParamsTemplate.Model = request.Model
-	threadExecutionParamsTemplate.Temperature = request.Temperature
-	threadExecutionParamsTemplate.Timeout = request.Timeout
-	threadExecutionParamsTemplate.MaxTokens = request.MaxTokens
-	threadExecutionParamsTemplate.MaxCompletionTokens = request.MaxCompletionTokens
-	threadExecutionParamsTemplate.MaxOutputTokens = request.MaxOutputTokens
-	threadExecutionParamsTemplate.SystemPrompt = request.SystemPrompt
+	// only the fields set in the request are updated, apply them to the
+	// stored template so that the result is validated as a whole
+	if request.Name != "" {
+		threadExecutionParamsTemplate.Name = request.Name
+	}
+	if request.Model != "" {
+		threadExecutionParamsTemplate.Model = request.Model
+	}
+	if request.Temperature != 0 {
+		threadExecutionParamsTemplate.Temperature = request.Temperature
+	}
+	if request.Timeout != 0 {
+		threadExecutionParamsTemplate.Timeout = request.Timeout
+	}
+	if request.MaxTokens != 0 {
+		threadExecutionParamsTemplate.MaxTokens = request.MaxTokens
+	}
+	if request.MaxCompletionTokens != 0 {
+		threadExecutionParamsTemplate.MaxCompletionTokens = request.MaxCompletionTokens
+	}
+	if request.MaxOutputTokens != 0 {
+		threadExecutionParamsTemplate.MaxOutputTokens = request.MaxOutputTokens
+	}
+	if request.TopP != 0 {
+		threadExecutionParamsTemplate.TopP = request.TopP
+	}
+	if request.SystemPrompt != "" {
+		threadExecutionParamsTemplate.SystemPrompt = request.SystemPrompt
+	}
 	threadExecutionParamsTemplate.ResponseFormat = responseFormat
+	if request.MaxAttempts != 0 {
+		threadExecutionParamsTemplate.MaxAttempts = request.MaxAttempts
+	}
+	if request.RetryBackoff != 0 {
+		threadExecutionParamsTemplate.RetryBackoff = request.RetryBackoff
+	}
+	if request.Client != "" {
+		threadExecutionParamsTemplate.Client = request.Client
+	}
+	if request.RetryableStatusCodes != nil {
+		retryableStatusCodes, err := json.Marshal(request.RetryableStatusCodes)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		threadExecutionParamsTemplate.RetryableStatusCodes = retryableStatusCodes
+	}
+
+	if err := controllers.ValidateThreadExecutionParamsTemplate(s.DB, threadExecutionParamsTemplate); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	if err := models.UpdateThreadExecutionParamsTemplate(s.DB, threadExecutionParamsTemplate); err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
@@ -455,3 +561,18 @@ func (s *Server) UpdateThreadExecutionParamsTemplate(w http.ResponseWriter, r *h
 
 	responses.JSON(w, http.StatusOK, "Template updated")
 }
+
+// checkFallbackTemplatesAccess makes sure every fallback template exists and
+// belongs to the project of the execution params.
+func (s *Server) checkFallbackTemplatesAccess(projectID string, fallbackTemplateIDs []string) error {
+	for _, fallbackTemplateID := range fallbackTemplateIDs {
+		fallbackTemplate, err := models.GetThreadExecutionParamsTemplateByID(s.DB, fallbackTemplateID)
+		if err != nil {
+			return fmt.Errorf("fallback template %s not found", fallbackTemplateID)
+		}
+		if fallbackTemplate.ProjectID != projectID {
+			return fmt.Errorf("fallback template %s does not belong to this project", fallbackTemplateID)
+		}
+	}
+	return nil
+}
@@ -1,12 +1,20 @@
 package handlers
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/burnerlee/compextAI/models"
+)
 
 type CreateThreadExecutionParamsRequest struct {
 	Name        string `json:"name"`
 	Environment string `json:"environment"`
 	TemplateID  string `json:"template_id"`
 	ProjectName string `json:"project_name"`
+	// templates tried in order when the template fails with a retryable error
+	FallbackTemplateIDs []string `json:"fallback_template_ids"`
 }
 
 func (r *CreateThreadExecutionParamsRequest) Validate() error {
@@ -22,7 +30,7 @@ func (r *CreateThreadExecutionParamsRequest) Validate() error {
 	if r.ProjectName == "" {
 		return errors.New("project_name is required")
 	}
-	return nil
+	return validateFallbackTemplateIDs(r.TemplateID, r.FallbackTemplateIDs)
 }
 
 type GetThreadExecutionParamsByNameRequest struct {
@@ -75,6 +83,12 @@ type CreateThreadExecutionParamsTemplateRequest struct {
 	TopP                float64     `json:"top_p"`
 	SystemPrompt        string      `json:"system_prompt"`
 	ResponseFormat      interface{} `json:"response_format"`
+	// retry policy for transient executor failures
+	MaxAttempts          int   `json:"max_attempts"`
+	RetryBackoff         int   `json:"retry_backoff"`
+	RetryableStatusCodes []int `json:"retryable_status_codes"`
+	// executor or native, defaults to executor
+	Client string `json:"client"`
 }
 
 func (r *CreateThreadExecutionParamsTemplateRequest) Validate() error {
@@ -87,6 +101,32 @@ func (r *CreateThreadExecutionParamsTemplateRequest) Validate() error {
 	if r.ProjectName == "" {
 		return errors.New("project_name is required")
 	}
+	if err := r.validateClient(); err != nil {
+		return err
+	}
+	return r.validateRetryPolicy()
+}
+
+func (r *CreateThreadExecutionParamsTemplateRequest) validateClient() error {
+	switch r.Client {
+	case "", models.TemplateClient_EXECUTOR, models.TemplateClient_NATIVE:
+		return nil
+	}
+	return fmt.Errorf("invalid client: %s, only %s and %s are allowed", r.Client, models.TemplateClient_EXECUTOR, models.TemplateClient_NATIVE)
+}
+
+func (r *CreateThreadExecutionParamsTemplateRequest) validateRetryPolicy() error {
+	if r.MaxAttempts < 0 {
+		return errors.New("max_attempts must not be negative")
+	}
+	if r.RetryBackoff < 0 {
+		return errors.New("retry_backoff must not be negative")
+	}
+	for _, statusCode := range r.RetryableStatusCodes {
+		if statusCode < 100 || statusCode > 599 {
+			return fmt.Errorf("invalid retryable status code: %d", statusCode)
+		}
+	}
 	return nil
 }
 
@@ -95,7 +135,10 @@ type UpdateThreadExecutionParamsTemplateRequest struct {
 }
 
 func (r *UpdateThreadExecutionParamsTemplateRequest) Validate() error {
-	return nil
+	if err := r.validateClient(); err != nil {
+		return err
+	}
+	return r.validateRetryPolicy()
 }
 
 type squashedThreadExecutionParams struct {
@@ -113,6 +156,12 @@ type squashedThreadExecutionParams struct {
 	TopP                float64     `json:"top_p"`
 	ResponseFormat      interface{} `json:"response_format"`
 	SystemPrompt        string      `json:"system_prompt"`
+	// retry policy for transient executor failures
+	MaxAttempts          int             `json:"max_attempts"`
+	RetryBackoff         int             `json:"retry_backoff"`
+	RetryableStatusCodes json.RawMessage `json:"retryable_status_codes"`
+	FallbackTemplateIDs  json.RawMessage `json:"fallback_template_ids"`
+	Client               string          `json:"client"`
 }
 
 type ExecuteParamsResponse []*squashedThreadExecutionParams
@@ -122,6 +171,8 @@ type UpdateThreadExecutionParamsRequest struct {
 	Name        string `json:"name"`
 	Environment string `json:"environment"`
 	TemplateID  string `json:"template_id"`
+	// replaces the fallback templates when set
+	FallbackTemplateIDs []string `json:"fallback_template_ids"`
 }
 
 func (r *UpdateThreadExecutionParamsRequest) Validate() error {
@@ -137,5 +188,19 @@ func (r *UpdateThreadExecutionParamsRequest) Validate() error {
 	if r.TemplateID == "" {
 		return errors.New("template_id is required")
 	}
+	return validateFallbackTemplateIDs(r.TemplateID, r.FallbackTemplateIDs)
+}
+
+func validateFallbackTemplateIDs(templateID string, fallbackTemplateIDs []string) error {
+	seen := map[string]bool{templateID: true}
+	for _, fallbackTemplateID := range fallbackTemplateIDs {
+		if fallbackTemplateID == "" {
+			return errors.New("fallback_template_ids must not contain empty ids")
+		}
+		if seen[fallbackTemplateID] {
+			return fmt.Errorf("template %s appears more than once in the fallback chain", fallbackTemplateID)
+		}
+		seen[fallbackTemplateID] = true
+	}
 	return nil
 }
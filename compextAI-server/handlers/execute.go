@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -11,6 +13,7 @@ import (
 	"github.com/burnerlee/compextAI/constants"
 	"github.com/burnerlee/compextAI/controllers"
 	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/middlewares"
 	"github.com/burnerlee/compextAI/models"
 	"github.com/burnerlee/compextAI/utils"
 	"github.com/burnerlee/compextAI/utils/responses"
@@ -31,7 +34,7 @@ func (s *Server) GetThreadExecution(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID))
+	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -108,7 +111,7 @@ func (s *Server) ListThreadExecutions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, projectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, projectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -149,6 +152,22 @@ func (s *Server) ListThreadExecutions(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// allowExecution applies the rate limits of the project and of the model of
+// the template to the execution request, the limit of the user is applied by
+// the middleware.
+func (s *Server) allowExecution(w http.ResponseWriter, r *http.Request, projectID, templateID string) bool {
+	if !middlewares.AllowRequest(w, r, s.RateLimiter, models.RateLimitScope_PROJECT, projectID) {
+		return false
+	}
+
+	threadExecutionParamsTemplate, err := models.GetThreadExecutionParamsTemplateByID(s.DB, templateID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return middlewares.AllowRequest(w, r, s.RateLimiter, models.RateLimitScope_MODEL, threadExecutionParamsTemplate.Model)
+}
+
 func (s *Server) ExecuteThread(w http.ResponseWriter, r *http.Request) {
 	threadID := mux.Vars(r)["id"]
 
@@ -168,6 +187,12 @@ func (s *Server) ExecuteThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	wait, waitTimeout, err := parseExecuteWaitParams(r)
+	if err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	userID, err := utils.GetUserIDFromRequest(r)
 	if err != nil {
 		responses.Error(w, http.StatusUnauthorized, err.Error())
@@ -175,7 +200,7 @@ func (s *Server) ExecuteThread(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if threadID != constants.THREAD_IDENTIFIER_FOR_NULL_THREAD {
-		hasAccess, err := utils.CheckThreadAccess(s.DB, threadID, uint(userID))
+		hasAccess, err := utils.CheckThreadAccess(s.DB, threadID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
 		if err != nil {
 			responses.Error(w, http.StatusInternalServerError, err.Error())
 			return
@@ -192,6 +217,27 @@ func (s *Server) ExecuteThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hasAccess, err := utils.CheckProjectRole(s.DB, threadExecutionParam.ProjectID, threadExecutionParam.UserID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !hasAccess {
+		responses.Error(w, http.StatusForbidden, "You are not authorized to use these thread execution params")
+		return
+	}
+
+	if !s.allowExecution(w, r, threadExecutionParam.ProjectID, threadExecutionParam.TemplateID) {
+		return
+	}
+
+	fallbackTemplateIDs, err := threadExecutionParam.GetFallbackTemplateIDs()
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	metadataJson, err := json.Marshal(request.Metadata)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
@@ -238,6 +284,7 @@ func (s *Server) ExecuteThread(w http.ResponseWriter, r *http.Request) {
 	}
 	threadExecution, err := controllers.ExecuteThread(s.DB, &controllers.ExecuteThreadRequest{
 		UserID:                         uint(userID),
+		APIKeyID:                       r.Header.Get(utils.API_KEY_ID_HEADER),
 		ThreadID:                       threadID,
 		ThreadExecutionParamTemplateID: threadExecutionParam.TemplateID,
 		AppendAssistantResponse:        request.AppendAssistantResponse,
@@ -247,13 +294,46 @@ func (s *Server) ExecuteThread(w http.ResponseWriter, r *http.Request) {
 		ProjectID:                      threadExecutionParam.ProjectID,
 		Metadata:                       metadataJson,
 		Tools:                          request.Tools,
+		Stream:                         request.Stream,
+		WebhookURL:                     request.WebhookURL,
+		FallbackTemplateIDs:            fallbackTemplateIDs,
+		RunTools:                       request.RunTools,
+		MaxToolIterations:              request.MaxToolIterations,
 	})
 	if err != nil {
+		var budgetExceededErr *controllers.BudgetExceededError
+		if errors.As(err, &budgetExceededErr) {
+			responses.Error(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	// the execution is enqueued, a retry with the same key gets this
+	// response instead of a second execution
+	middlewares.MarkIdempotentRequestCommitted(r)
 
-	responses.JSON(w, http.StatusOK, threadExecution)
+	if !wait {
+		responses.JSON(w, http.StatusOK, threadExecution)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), waitTimeout)
+	defer cancel()
+
+	finishedThreadExecution, err := controllers.WaitForThreadExecution(ctx, s.DB, threadExecution.(*models.ThreadExecution).Identifier, controllers.DEFAULT_EXECUTION_WAIT_POLL_INTERVAL)
+	if err != nil {
+		if ctx.Err() != nil {
+			// the wait timed out or the client went away, the execution goes
+			// on and the client falls back to polling
+			responses.JSON(w, http.StatusAccepted, threadExecution)
+			return
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeThreadExecutionResponse(w, finishedThreadExecution)
 }
 
 func (s *Server) GetThreadExecutionStatus(w http.ResponseWriter, r *http.Request) {
@@ -270,7 +350,7 @@ func (s *Server) GetThreadExecutionStatus(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID))
+	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -303,7 +383,7 @@ func (s *Server) GetThreadExecutionResponse(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID))
+	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -319,6 +399,12 @@ func (s *Server) GetThreadExecutionResponse(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	writeThreadExecutionResponse(w, threadExecution)
+}
+
+// writeThreadExecutionResponse writes the response of a finished execution,
+// or an error when the execution did not complete.
+func writeThreadExecutionResponse(w http.ResponseWriter, threadExecution *models.ThreadExecution) {
 	if threadExecution.Status != models.ThreadExecutionStatus_COMPLETED {
 		responses.Error(w, http.StatusBadRequest, fmt.Sprintf("Thread execution is: %s", threadExecution.Status))
 		return
@@ -330,10 +416,17 @@ func (s *Server) GetThreadExecutionResponse(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	toolCalls, err := models.ParseToolCalls(threadExecution.ToolCalls)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	responses.JSON(w, http.StatusOK, map[string]interface{}{
-		"response": responseContent,
-		"content":  threadExecution.Content,
-		"role":     threadExecution.Role,
+		"response":   responseContent,
+		"content":    threadExecution.Content,
+		"role":       threadExecution.Role,
+		"tool_calls": toolCalls,
 	})
 }
 
@@ -351,7 +444,7 @@ func (s *Server) RerunThreadExecution(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID))
+	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -372,8 +465,19 @@ func (s *Server) RerunThreadExecution(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	previousThreadExecution, err := models.GetThreadExecutionByID(s.DB, executionID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !s.allowExecution(w, r, previousThreadExecution.ProjectID, request.ThreadExecutionParamTemplateID) {
+		return
+	}
+
 	threadExecution, err := controllers.RerunThreadExecution(s.DB, &controllers.RerunThreadExecutionRequest{
 		UserID:                         uint(userID),
+		APIKeyID:                       r.Header.Get(utils.API_KEY_ID_HEADER),
 		ExecutionID:                    executionID,
 		ThreadExecutionParamTemplateID: request.ThreadExecutionParamTemplateID,
 		SystemPrompt:                   request.SystemPrompt,
@@ -381,9 +485,165 @@ func (s *Server) RerunThreadExecution(w http.ResponseWriter, r *http.Request) {
 		Tools:                          request.Tools,
 	})
 	if err != nil {
+		var budgetExceededErr *controllers.BudgetExceededError
+		if errors.As(err, &budgetExceededErr) {
+			responses.Error(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	responses.JSON(w, http.StatusOK, threadExecution)
 }
+
+func (s *Server) CancelThreadExecution(w http.ResponseWriter, r *http.Request) {
+	executionID := mux.Vars(r)["id"]
+
+	if executionID == "" {
+		responses.Error(w, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !hasAccess {
+		responses.Error(w, http.StatusForbidden, "You are not authorized to cancel this thread execution")
+		return
+	}
+
+	threadExecution, err := models.GetThreadExecutionByID(s.DB, executionID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if threadExecution.Status != models.ThreadExecutionStatus_IN_PROGRESS {
+		responses.Error(w, http.StatusBadRequest, fmt.Sprintf("Thread execution is: %s", threadExecution.Status))
+		return
+	}
+
+	cancelledThreadExecution, err := controllers.CancelThreadExecution(s.DB, &controllers.CancelThreadExecutionRequest{
+		UserID:      uint(userID),
+		ExecutionID: executionID,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, cancelledThreadExecution)
+}
+
+func (s *Server) StreamThreadExecution(w http.ResponseWriter, r *http.Request) {
+	executionID := mux.Vars(r)["id"]
+
+	if executionID == "" {
+		responses.Error(w, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	hasAccess, err := utils.CheckThreadExecutionAccess(s.DB, executionID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !hasAccess {
+		responses.Error(w, http.StatusForbidden, "You are not authorized to access this thread execution")
+		return
+	}
+
+	if _, ok := w.(http.Flusher); !ok {
+		responses.Error(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	responses.SSEHeaders(w)
+
+	// deltas are only available when the execution is run by this server,
+	// otherwise (or once the deltas are relayed) wait for the execution to
+	// finish and send its final result
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	streamed := false
+	for {
+		if !streamed {
+			content, deltas, unsubscribe, ok := controllers.SubscribeThreadExecutionStream(executionID)
+			if ok {
+				streamed = true
+				if err := relayThreadExecutionDeltas(w, r, content, deltas); err != nil {
+					unsubscribe()
+					logger.GetLogger().Errorf("Error streaming thread execution: %s: %v", executionID, err)
+					return
+				}
+				unsubscribe()
+			}
+		}
+
+		status, err := models.GetThreadExecutionStatus(s.DB, executionID)
+		if err != nil {
+			responses.SSE(w, "error", map[string]interface{}{"error": err.Error()})
+			return
+		}
+		if status != models.ThreadExecutionStatus_IN_PROGRESS {
+			break
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	threadExecution, err := models.GetThreadExecutionByID(s.DB, executionID)
+	if err != nil {
+		responses.SSE(w, "error", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	responses.SSE(w, "done", map[string]interface{}{
+		"status":   threadExecution.Status,
+		"response": threadExecution.Output,
+		"content":  threadExecution.Content,
+		"role":     threadExecution.Role,
+	})
+}
+
+func relayThreadExecutionDeltas(w http.ResponseWriter, r *http.Request, content string, deltas <-chan string) error {
+	if content != "" {
+		if err := responses.SSE(w, "delta", map[string]interface{}{"content": content}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return r.Context().Err()
+		case delta, ok := <-deltas:
+			if !ok {
+				return nil
+			}
+			if err := responses.SSE(w, "delta", map[string]interface{}{"content": delta}); err != nil {
+				return err
+			}
+		}
+	}
+}
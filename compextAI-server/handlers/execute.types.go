@@ -2,11 +2,52 @@ package handlers
 
 import (
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/burnerlee/compextAI/constants"
+	"github.com/burnerlee/compextAI/controllers"
 	"github.com/burnerlee/compextAI/models"
 )
 
+const (
+	DEFAULT_EXECUTE_WAIT_TIMEOUT = 30 * time.Second
+	// keeps synchronous requests below the usual proxy timeouts
+	MAX_EXECUTE_WAIT_TIMEOUT = 120 * time.Second
+)
+
+// parseExecuteWaitParams reads the wait and timeout query params of the
+// execute request. The timeout is a duration such as 30s, or a number of
+// seconds.
+func parseExecuteWaitParams(r *http.Request) (bool, time.Duration, error) {
+	waitParam := r.URL.Query().Get("wait")
+	if waitParam == "" {
+		return false, 0, nil
+	}
+	wait, err := strconv.ParseBool(waitParam)
+	if err != nil {
+		return false, 0, fmt.Errorf("wait must be a boolean")
+	}
+
+	timeout := DEFAULT_EXECUTE_WAIT_TIMEOUT
+	if timeoutParam := r.URL.Query().Get("timeout"); timeoutParam != "" {
+		timeout, err = time.ParseDuration(timeoutParam)
+		if err != nil {
+			seconds, secondsErr := strconv.Atoi(timeoutParam)
+			if secondsErr != nil {
+				return false, 0, fmt.Errorf("timeout must be a duration such as 30s")
+			}
+			timeout = time.Duration(seconds) * time.Second
+		}
+	}
+	if timeout <= 0 || timeout > MAX_EXECUTE_WAIT_TIMEOUT {
+		return false, 0, fmt.Errorf("timeout must be between 0s and %s", MAX_EXECUTE_WAIT_TIMEOUT)
+	}
+
+	return wait, timeout, nil
+}
+
 type ExecuteThreadRequest struct {
 	ThreadExecutionParamID string `json:"thread_execution_param_id"`
 	// messages to execute the thread with - overrides the thread messages
@@ -15,6 +56,12 @@ type ExecuteThreadRequest struct {
 	AppendAssistantResponse     bool                    `json:"append_assistant_response"`
 	Tools                       []*models.ExecutionTool `json:"tools"`
 	Metadata                    map[string]interface{}  `json:"metadata"`
+	Stream                      bool                    `json:"stream"`
+	// notified once the execution is finished, in addition to the project webhooks
+	WebhookURL string `json:"webhook_url"`
+	// runs the tool calls of the model with the tools registered on the project
+	RunTools          bool `json:"run_tools"`
+	MaxToolIterations int  `json:"max_tool_iterations"`
 }
 
 func (r *ExecuteThreadRequest) Validate(threadID string) error {
@@ -26,6 +73,16 @@ func (r *ExecuteThreadRequest) Validate(threadID string) error {
 		return fmt.Errorf("messages are required, when thread_id is not provided")
 	}
 
+	if r.MaxToolIterations < 0 || r.MaxToolIterations > controllers.MAX_TOOL_ITERATIONS {
+		return fmt.Errorf("max_tool_iterations must be between 0 and %d", controllers.MAX_TOOL_ITERATIONS)
+	}
+
+	if r.WebhookURL != "" {
+		if err := validatePublicHTTPURL(r.WebhookURL); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
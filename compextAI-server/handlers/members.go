@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/burnerlee/compextAI/controllers"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func getMemberUserIDFromRequest(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	memberUserID, err := strconv.ParseUint(mux.Vars(r)["user_id"], 10, 0)
+	if err != nil {
+		responses.Error(w, http.StatusBadRequest, "invalid user id")
+		return 0, false
+	}
+	return uint(memberUserID), true
+}
+
+// ListProjectMembers returns everyone with access to the project with their
+// highest role: the owner, the members of the project and the members of its
+// organization
+func (s *Server) ListProjectMembers(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	project, err := models.GetProject(s.DB, projectID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	owner, err := models.GetUserByID(s.DB, project.UserID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	members := []*MemberResponse{newMemberResponse(owner, models.Role_OWNER, MemberSource_OWNER)}
+	membersByUserID := map[uint]*MemberResponse{owner.ID: members[0]}
+
+	addMember := func(user *models.User, role, source string) {
+		if member, ok := membersByUserID[user.ID]; ok {
+			if !models.RoleAllows(member.Role, role) {
+				member.Role = role
+				member.Source = source
+			}
+			return
+		}
+		member := newMemberResponse(user, role, source)
+		members = append(members, member)
+		membersByUserID[user.ID] = member
+	}
+
+	projectMembers, err := models.GetProjectMembers(s.DB, projectID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i := range projectMembers {
+		addMember(&projectMembers[i].User, projectMembers[i].Role, MemberSource_PROJECT)
+	}
+
+	if project.OrganizationID != "" {
+		organizationMembers, err := models.GetOrganizationMembers(s.DB, project.OrganizationID)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for i := range organizationMembers {
+			addMember(&organizationMembers[i].User, organizationMembers[i].Role, MemberSource_ORGANIZATION)
+		}
+	}
+
+	responses.JSON(w, http.StatusOK, members)
+}
+
+// UpdateProjectMember changes the role of a member of the project, the roles
+// given by the organization are changed in the organization
+func (s *Server) UpdateProjectMember(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	memberUserID, ok := getMemberUserIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var request UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := models.GetProjectMember(s.DB, projectID, memberUserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "member not found")
+			return
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := models.UpdateProjectMemberRole(s.DB, projectID, memberUserID, request.Role); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Member updated successfully")
+}
+
+// RemoveProjectMember removes a member from the project, the owners remove
+// anyone and the members remove themselves
+func (s *Server) RemoveProjectMember(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	memberUserID, ok := getMemberUserIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if memberUserID != userID {
+		hasAccess, err := utils.CheckProjectAccess(s.DB, projectID, userID, utils.GetProjectScopeFromRequest(r), models.Role_OWNER)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !hasAccess {
+			responses.Error(w, http.StatusForbidden, "only the owners can remove the other members")
+			return
+		}
+	}
+
+	if _, err := models.GetProjectMember(s.DB, projectID, memberUserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "member not found")
+			return
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := models.RemoveProjectMember(s.DB, projectID, memberUserID); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Member removed successfully")
+}
+
+func (s *Server) ListProjectInvitations(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	invitations, err := models.GetProjectInvitations(s.DB, projectID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, invitations)
+}
+
+func (s *Server) CreateProjectInvitation(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	var request CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	invitation, token, err := controllers.CreateInvitation(s.DB, &controllers.CreateInvitationRequest{
+		InvitedByID: userID,
+		ProjectID:   projectID,
+		Email:       request.Email,
+		Role:        request.Role,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, CreateInvitationResponse{Invitation: invitation, Token: token})
+}
+
+func (s *Server) DeleteProjectInvitation(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	invitation, err := models.GetInvitation(s.DB, mux.Vars(r)["invitation_id"])
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err != nil || invitation.ProjectID != projectID {
+		responses.Error(w, http.StatusNotFound, "invitation not found")
+		return
+	}
+
+	if err := models.DeleteInvitation(s.DB, invitation.Identifier); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Invitation deleted successfully")
+}
+
+// AcceptInvitation makes the user a member of the project or the organization
+// of the invitation
+func (s *Server) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	invitation, err := controllers.AcceptInvitation(s.DB, uint(userID), request.Token)
+	if err != nil {
+		if errors.Is(err, controllers.ErrInvalidInvitation) {
+			responses.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, controllers.ErrInvitationEmailMismatch) {
+			responses.Error(w, http.StatusForbidden, err.Error())
+			return
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, invitation)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/mail"
+
+	"github.com/burnerlee/compextAI/models"
+)
+
+const (
+	// the sources of the role of a member of a project
+	MemberSource_OWNER        = "owner"
+	MemberSource_PROJECT      = "project"
+	MemberSource_ORGANIZATION = "organization"
+)
+
+func validateRole(role string) error {
+	if !models.IsValidRole(role) {
+		return errors.New("role should be one of owner, editor, executor, viewer")
+	}
+	return nil
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+func (r *UpdateMemberRequest) Validate() error {
+	return validateRole(r.Role)
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (r *CreateInvitationRequest) Validate() error {
+	if r.Email == "" {
+		return errors.New("email is required")
+	}
+	if _, err := mail.ParseAddress(r.Email); err != nil {
+		return errors.New("invalid email")
+	}
+	return validateRole(r.Role)
+}
+
+type CreateInvitationResponse struct {
+	*models.Invitation
+	// the token is only returned when the invitation is created, the invitee
+	// accepts the invitation with it
+	Token string `json:"token"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+func (r *AcceptInvitationRequest) Validate() error {
+	if r.Token == "" {
+		return errors.New("token is required")
+	}
+	return nil
+}
+
+// MemberResponse leaves out the provider keys of the user
+type MemberResponse struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	// where the role in the project comes from, one of owner, project,
+	// organization
+	Source string `json:"source,omitempty"`
+}
+
+func newMemberResponse(user *models.User, role, source string) *MemberResponse {
+	return &MemberResponse{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     role,
+		Source:   source,
+	}
+}
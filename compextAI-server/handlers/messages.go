@@ -33,7 +33,7 @@ func (s *Server) ListMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckThreadAccess(s.DB, threadID, uint(userID))
+	hasAccess, err := utils.CheckThreadAccess(s.DB, threadID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -80,7 +80,7 @@ func (s *Server) CreateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckThreadAccess(s.DB, threadID, uint(userID))
+	hasAccess, err := utils.CheckThreadAccess(s.DB, threadID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -138,7 +138,7 @@ func (s *Server) GetMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckMessageAccess(s.DB, messageID, uint(userID))
+	hasAccess, err := utils.CheckMessageAccess(s.DB, messageID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -188,7 +188,7 @@ func (s *Server) UpdateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckMessageAccess(s.DB, messageID, uint(userID))
+	hasAccess, err := utils.CheckMessageAccess(s.DB, messageID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -261,7 +261,7 @@ func (s *Server) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckMessageAccess(s.DB, messageID, uint(userID))
+	hasAccess, err := utils.CheckMessageAccess(s.DB, messageID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
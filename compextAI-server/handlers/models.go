@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat"
+	"github.com/burnerlee/compextAI/utils/responses"
+)
+
+func (s *Server) ListModels(w http.ResponseWriter, r *http.Request) {
+	response := make([]*ModelResponse, 0)
+	for _, provider := range chat.GetAvailableProviders() {
+		modelConfig := provider.GetModelConfig()
+		response = append(response, &ModelResponse{
+			Identifier:           provider.GetProviderIdentifier(),
+			Owner:                provider.GetProviderOwner(),
+			Model:                provider.GetProviderModel(),
+			Protocol:             modelConfig.Protocol,
+			AllowedRoles:         modelConfig.AllowedRoles,
+			SupportedParameters:  modelConfig.GetSupportedParameters(),
+			SupportsTools:        modelConfig.SupportsTools,
+			SupportsJSONMode:     modelConfig.SupportsJSONMode,
+			SupportsNativeClient: modelConfig.SupportsNativeClient(),
+			ContextWindow:        modelConfig.ContextWindow,
+			DefaultTemperature:   modelConfig.DefaultTemperature,
+			DefaultMaxTokens:     modelConfig.DefaultMaxTokens,
+			DefaultTimeout:       modelConfig.DefaultTimeout,
+			Pricing:              modelConfig.Pricing,
+		})
+	}
+
+	responses.JSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import "github.com/burnerlee/compextAI/internal/providers/chat/base"
+
+type ModelResponse struct {
+	Identifier string `json:"identifier"`
+	Owner      string `json:"owner"`
+	// empty for the providers that use the model of the template, i.e. litellm
+	// and the project endpoints
+	Model                string   `json:"model"`
+	Protocol             string   `json:"protocol"`
+	AllowedRoles         []string `json:"allowed_roles"`
+	SupportedParameters  []string `json:"supported_parameters"`
+	SupportsTools        bool     `json:"supports_tools"`
+	SupportsJSONMode     bool     `json:"supports_json_mode"`
+	SupportsNativeClient bool     `json:"supports_native_client"`
+	ContextWindow        int      `json:"context_window"`
+	DefaultTemperature   float64  `json:"default_temperature"`
+	DefaultMaxTokens     int      `json:"default_max_tokens"`
+	DefaultTimeout       int      `json:"default_timeout"`
+	// in USD per million tokens, null when unknown
+	Pricing *base.ModelPricing `json:"pricing"`
+}
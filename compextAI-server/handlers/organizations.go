@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/burnerlee/compextAI/controllers"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// checkOrganizationRequestAccess resolves the organization of the request and
+// writes the error response when the user does not have at least the role in
+// it. The api keys scoped to a project cannot access the organizations.
+func (s *Server) checkOrganizationRequestAccess(w http.ResponseWriter, r *http.Request, role string) (string, uint, bool) {
+	if !checkUnscopedRequest(w, r) {
+		return "", 0, false
+	}
+
+	organizationID := mux.Vars(r)["organization_id"]
+	if organizationID == "" {
+		responses.Error(w, http.StatusBadRequest, "organization id is required")
+		return "", 0, false
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return "", 0, false
+	}
+
+	if _, err := models.GetOrganization(s.DB, organizationID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "organization not found")
+			return "", 0, false
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return "", 0, false
+	}
+
+	hasAccess, err := utils.CheckOrganizationAccess(s.DB, organizationID, uint(userID), role)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return "", 0, false
+	}
+
+	if !hasAccess {
+		responses.Error(w, http.StatusForbidden, "you do not have access to this organization")
+		return "", 0, false
+	}
+
+	return organizationID, uint(userID), true
+}
+
+func (s *Server) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	organizations, err := models.GetUserOrganizations(s.DB, uint(userID))
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, organizations)
+}
+
+func (s *Server) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	organization := &models.Organization{
+		Name:        request.Name,
+		Description: request.Description,
+	}
+	if err := models.CreateOrganization(s.DB, organization, uint(userID)); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, organization)
+}
+
+func (s *Server) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	organizationID, _, ok := s.checkOrganizationRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	organization, err := models.GetOrganization(s.DB, organizationID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, organization)
+}
+
+func (s *Server) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	organizationID, _, ok := s.checkOrganizationRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	var request UpdateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := models.UpdateOrganization(s.DB, &models.Organization{
+		Base: models.Base{
+			Identifier: organizationID,
+		},
+		Name:        request.Name,
+		Description: request.Description,
+	}); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Organization updated successfully")
+}
+
+// DeleteOrganization deletes an organization without projects, the projects
+// are deleted first so that none of them is left without its members
+func (s *Server) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	organizationID, _, ok := s.checkOrganizationRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	projectCount, err := models.CountOrganizationProjects(s.DB, organizationID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if projectCount > 0 {
+		responses.Error(w, http.StatusBadRequest, "the organization still has projects")
+		return
+	}
+
+	if err := models.DeleteOrganization(s.DB, organizationID); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Organization deleted successfully")
+}
+
+func (s *Server) ListOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	organizationID, _, ok := s.checkOrganizationRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	organizationMembers, err := models.GetOrganizationMembers(s.DB, organizationID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	members := make([]*MemberResponse, 0, len(organizationMembers))
+	for i := range organizationMembers {
+		members = append(members, newMemberResponse(&organizationMembers[i].User, organizationMembers[i].Role, ""))
+	}
+
+	responses.JSON(w, http.StatusOK, members)
+}
+
+// checkLastOrganizationOwner writes the error response when the member is the
+// last owner of the organization, every organization keeps an owner
+func (s *Server) checkLastOrganizationOwner(w http.ResponseWriter, member *models.OrganizationMember) bool {
+	if member.Role != models.Role_OWNER {
+		return true
+	}
+
+	ownerCount, err := models.CountOrganizationOwners(s.DB, member.OrganizationID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if ownerCount <= 1 {
+		responses.Error(w, http.StatusBadRequest, "the organization needs at least one owner")
+		return false
+	}
+	return true
+}
+
+// getOrganizationMemberFromRequest loads the member of the request and writes
+// the error response when the user is not a member of the organization.
+func (s *Server) getOrganizationMemberFromRequest(w http.ResponseWriter, r *http.Request, organizationID string) (*models.OrganizationMember, bool) {
+	memberUserID, ok := getMemberUserIDFromRequest(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	member, err := models.GetOrganizationMember(s.DB, organizationID, memberUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "member not found")
+			return nil, false
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return member, true
+}
+
+func (s *Server) UpdateOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	organizationID, _, ok := s.checkOrganizationRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	member, ok := s.getOrganizationMemberFromRequest(w, r, organizationID)
+	if !ok {
+		return
+	}
+
+	var request UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if request.Role != models.Role_OWNER && !s.checkLastOrganizationOwner(w, member) {
+		return
+	}
+
+	if err := models.UpdateOrganizationMemberRole(s.DB, organizationID, member.UserID, request.Role); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Member updated successfully")
+}
+
+// RemoveOrganizationMember removes a member from the organization, the owners
+// remove anyone and the members remove themselves
+func (s *Server) RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	organizationID, userID, ok := s.checkOrganizationRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	member, ok := s.getOrganizationMemberFromRequest(w, r, organizationID)
+	if !ok {
+		return
+	}
+
+	if member.UserID != userID {
+		hasAccess, err := utils.CheckOrganizationAccess(s.DB, organizationID, userID, models.Role_OWNER)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !hasAccess {
+			responses.Error(w, http.StatusForbidden, "only the owners can remove the other members")
+			return
+		}
+	}
+
+	if !s.checkLastOrganizationOwner(w, member) {
+		return
+	}
+
+	if err := models.RemoveOrganizationMember(s.DB, organizationID, member.UserID); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Member removed successfully")
+}
+
+func (s *Server) ListOrganizationInvitations(w http.ResponseWriter, r *http.Request) {
+	organizationID, _, ok := s.checkOrganizationRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	invitations, err := models.GetOrganizationInvitations(s.DB, organizationID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, invitations)
+}
+
+func (s *Server) CreateOrganizationInvitation(w http.ResponseWriter, r *http.Request) {
+	organizationID, userID, ok := s.checkOrganizationRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	var request CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	invitation, token, err := controllers.CreateInvitation(s.DB, &controllers.CreateInvitationRequest{
+		InvitedByID:    userID,
+		OrganizationID: organizationID,
+		Email:          request.Email,
+		Role:           request.Role,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, CreateInvitationResponse{Invitation: invitation, Token: token})
+}
+
+func (s *Server) DeleteOrganizationInvitation(w http.ResponseWriter, r *http.Request) {
+	organizationID, _, ok := s.checkOrganizationRequestAccess(w, r, models.Role_OWNER)
+	if !ok {
+		return
+	}
+
+	invitation, err := models.GetInvitation(s.DB, mux.Vars(r)["invitation_id"])
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err != nil || invitation.OrganizationID != organizationID {
+		responses.Error(w, http.StatusNotFound, "invitation not found")
+		return
+	}
+
+	if err := models.DeleteInvitation(s.DB, invitation.Identifier); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Invitation deleted successfully")
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import "errors"
+
+type CreateOrganizationRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (r *CreateOrganizationRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type UpdateOrganizationRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (r *UpdateOrganizationRequest) Validate() error {
+	return nil
+}
@@ -11,6 +11,10 @@ import (
 )
 
 func (s *Server) CreateProject(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
 	userID, err := utils.GetUserIDFromRequest(r)
 	if err != nil {
 		responses.Error(w, http.StatusUnauthorized, err.Error())
@@ -28,16 +32,30 @@ func (s *Server) CreateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// check if the project name is already taken
+	if request.OrganizationID != "" {
+		hasAccess, err := utils.CheckOrganizationAccess(s.DB, request.OrganizationID, uint(userID), models.Role_EDITOR)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !hasAccess {
+			responses.Error(w, http.StatusForbidden, "you cannot create projects in this organization")
+			return
+		}
+	}
+
+	// check if the project name is already taken among the projects the user
+	// can access, so that the name resolves to a single project
 	if _, err := models.GetProjectByName(s.DB, request.Name, uint(userID)); err == nil {
 		responses.Error(w, http.StatusBadRequest, "project name already taken")
 		return
 	}
 
 	project := &models.Project{
-		UserID:      uint(userID),
-		Name:        request.Name,
-		Description: request.Description,
+		UserID:         uint(userID),
+		OrganizationID: request.OrganizationID,
+		Name:           request.Name,
+		Description:    request.Description,
 	}
 
 	if err := models.CreateProject(s.DB, project); err != nil {
@@ -62,7 +80,7 @@ func (s *Server) GetProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckProjectAccess(s.DB, projectID, uint(userID))
+	hasAccess, err := utils.CheckProjectAccess(s.DB, projectID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -95,7 +113,21 @@ func (s *Server) ListProjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, projects)
+	projectScope := utils.GetProjectScopeFromRequest(r)
+	scopedProjects := make([]ProjectResponse, 0, len(projects))
+	for i := range projects {
+		if !utils.InProjectScope(projectScope, projects[i].Identifier) {
+			continue
+		}
+		role, err := models.GetProjectRole(s.DB, &projects[i], uint(userID))
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		scopedProjects = append(scopedProjects, ProjectResponse{Project: projects[i], Role: role})
+	}
+
+	responses.JSON(w, http.StatusOK, scopedProjects)
 }
 
 func (s *Server) DeleteProject(w http.ResponseWriter, r *http.Request) {
@@ -112,7 +144,7 @@ func (s *Server) DeleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckProjectAccess(s.DB, projectID, uint(userID))
+	hasAccess, err := utils.CheckProjectAccess(s.DB, projectID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_OWNER)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -145,7 +177,7 @@ func (s *Server) UpdateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasAccess, err := utils.CheckProjectAccess(s.DB, projectID, uint(userID))
+	hasAccess, err := utils.CheckProjectAccess(s.DB, projectID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EDITOR)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -4,11 +4,16 @@ import (
 	"errors"
 	"regexp"
 	"strings"
+
+	"github.com/burnerlee/compextAI/models"
 )
 
 type CreateProjectRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// shares the project with the members of the organization, the user must
+	// be at least an editor of it
+	OrganizationID string `json:"organization_id"`
 }
 
 func (r *CreateProjectRequest) Validate() error {
@@ -35,3 +40,9 @@ type UpdateProjectRequest struct {
 func (r *UpdateProjectRequest) Validate() error {
 	return nil
 }
+
+type ProjectResponse struct {
+	models.Project
+	// the role of the user in the project
+	Role string `json:"role"`
+}
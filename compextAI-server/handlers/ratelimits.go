@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (s *Server) ListRateLimits(w http.ResponseWriter, r *http.Request) {
+	rateLimits, err := models.GetRateLimits(s.DB)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, rateLimits)
+}
+
+func (s *Server) SetRateLimit(w http.ResponseWriter, r *http.Request) {
+	var request SetRateLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rateLimit, err := models.SetRateLimit(s.DB, &models.RateLimit{
+		Scope:             request.Scope,
+		Subject:           request.Subject,
+		RequestsPerMinute: request.RequestsPerMinute,
+		Burst:             request.Burst,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.reloadRateLimits()
+	responses.JSON(w, http.StatusOK, rateLimit)
+}
+
+func (s *Server) DeleteRateLimit(w http.ResponseWriter, r *http.Request) {
+	rateLimitID := mux.Vars(r)["ratelimit_id"]
+
+	if _, err := models.GetRateLimitByID(s.DB, rateLimitID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "rate limit not found")
+			return
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := models.DeleteRateLimit(s.DB, rateLimitID); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.reloadRateLimits()
+	responses.JSON(w, http.StatusOK, "Rate limit deleted successfully")
+}
+
+// reloadRateLimits applies the change right away on this instance, the other
+// instances pick it up with their next reload
+func (s *Server) reloadRateLimits() {
+	if err := s.RateLimiter.Reload(); err != nil {
+		logger.GetLogger().Errorf("Error reloading rate limits: %v", err)
+	}
+}
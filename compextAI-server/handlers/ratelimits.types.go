@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/burnerlee/compextAI/models"
+)
+
+type SetRateLimitRequest struct {
+	Scope string `json:"scope"`
+	// empty to set the limit of the whole scope
+	Subject           string `json:"subject"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	Burst             int    `json:"burst"`
+}
+
+func (r *SetRateLimitRequest) Validate() error {
+	if r.Scope != models.RateLimitScope_USER && r.Scope != models.RateLimitScope_PROJECT && r.Scope != models.RateLimitScope_MODEL {
+		return errors.New("scope should be one of user, project, model")
+	}
+	if r.RequestsPerMinute < 0 {
+		return errors.New("requests_per_minute should not be negative")
+	}
+	if r.Burst < 0 {
+		return errors.New("burst should not be negative")
+	}
+	return nil
+}
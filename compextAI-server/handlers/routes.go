@@ -13,14 +13,16 @@ func (s *Server) InitRoutes() {
 	threadRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.GetThread, s.DB)).Methods("GET")
 	threadRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.UpdateThread, s.DB)).Methods("PUT")
 	threadRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.DeleteThread, s.DB)).Methods("DELETE")
-	threadRouter.HandleFunc("/{id}/execute", middlewares.AuthMiddleware(s.ExecuteThread, s.DB)).Methods("POST")
+	threadRouter.HandleFunc("/{id}/execute", middlewares.AuthMiddleware(middlewares.RateLimitMiddleware(middlewares.IdempotencyMiddleware(s.ExecuteThread, s.DB), s.RateLimiter), s.DB)).Methods("POST")
 
 	threadExecRouter := v1Router.PathPrefix("/threadexec").Subrouter()
 	threadExecRouter.HandleFunc("/all/{projectname}", middlewares.AuthMiddleware(s.ListThreadExecutions, s.DB)).Methods("GET")
 	threadExecRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.GetThreadExecution, s.DB)).Methods("GET")
 	threadExecRouter.HandleFunc("/{id}/status", middlewares.AuthMiddleware(s.GetThreadExecutionStatus, s.DB)).Methods("GET")
 	threadExecRouter.HandleFunc("/{id}/response", middlewares.AuthMiddleware(s.GetThreadExecutionResponse, s.DB)).Methods("GET")
-	threadExecRouter.HandleFunc("/{id}/rerun", middlewares.AuthMiddleware(s.RerunThreadExecution, s.DB)).Methods("POST")
+	threadExecRouter.HandleFunc("/{id}/stream", middlewares.AuthMiddleware(s.StreamThreadExecution, s.DB)).Methods("GET")
+	threadExecRouter.HandleFunc("/{id}/rerun", middlewares.AuthMiddleware(middlewares.RateLimitMiddleware(s.RerunThreadExecution, s.RateLimiter), s.DB)).Methods("POST")
+	threadExecRouter.HandleFunc("/{id}/cancel", middlewares.AuthMiddleware(s.CancelThreadExecution, s.DB)).Methods("POST")
 
 	messageRouter := v1Router.PathPrefix("/message").Subrouter()
 
@@ -30,15 +32,33 @@ func (s *Server) InitRoutes() {
 
 	messageThreadIDRouter := messageRouter.PathPrefix("/thread/{thread_id}").Subrouter()
 
-	messageThreadIDRouter.HandleFunc("", middlewares.AuthMiddleware(s.CreateMessage, s.DB)).Methods("POST")
+	messageThreadIDRouter.HandleFunc("", middlewares.AuthMiddleware(middlewares.IdempotencyMiddleware(s.CreateMessage, s.DB), s.DB)).Methods("POST")
 	messageThreadIDRouter.HandleFunc("", middlewares.AuthMiddleware(s.ListMessages, s.DB)).Methods("GET")
 
 	userRouter := v1Router.PathPrefix("/user").Subrouter()
 	userRouter.HandleFunc("/signup", s.CreateUser).Methods("POST")
 	userRouter.HandleFunc("/login", s.Login).Methods("POST")
+	userRouter.HandleFunc("/logout", middlewares.AuthMiddleware(s.Logout, s.DB)).Methods("POST")
+	userRouter.HandleFunc("/sessions", middlewares.AuthMiddleware(s.ListSessions, s.DB)).Methods("GET")
+	userRouter.HandleFunc("/sessions", middlewares.AuthMiddleware(s.RevokeAllSessions, s.DB)).Methods("DELETE")
+	userRouter.HandleFunc("/sessions/{session_id}", middlewares.AuthMiddleware(s.RevokeSession, s.DB)).Methods("DELETE")
 	userRouter.HandleFunc("/api_keys", middlewares.AuthMiddleware(s.ListAPIKeys, s.DB)).Methods("GET")
 	userRouter.HandleFunc("/api_keys", middlewares.AuthMiddleware(s.UpdateAPIKeys, s.DB)).Methods("PUT")
 
+	apiKeyRouter := v1Router.PathPrefix("/apikeys").Subrouter()
+	apiKeyRouter.HandleFunc("", middlewares.AuthMiddleware(s.ListUserAPIKeys, s.DB)).Methods("GET")
+	apiKeyRouter.HandleFunc("", middlewares.AuthMiddleware(s.CreateUserAPIKey, s.DB)).Methods("POST")
+	apiKeyRouter.HandleFunc("/{api_key_id}/rotate", middlewares.AuthMiddleware(s.RotateUserAPIKey, s.DB)).Methods("POST")
+	apiKeyRouter.HandleFunc("/{api_key_id}", middlewares.AuthMiddleware(s.RevokeUserAPIKey, s.DB)).Methods("DELETE")
+
+	credentialRouter := v1Router.PathPrefix("/credentials").Subrouter()
+	credentialRouter.HandleFunc("", middlewares.AuthMiddleware(s.ListUserCredentials, s.DB)).Methods("GET")
+	credentialRouter.HandleFunc("", middlewares.AuthMiddleware(s.CreateUserCredential, s.DB)).Methods("POST")
+	credentialRouter.HandleFunc("/{credential_id}", middlewares.AuthMiddleware(s.UpdateUserCredential, s.DB)).Methods("PUT")
+	credentialRouter.HandleFunc("/{credential_id}", middlewares.AuthMiddleware(s.DeleteUserCredential, s.DB)).Methods("DELETE")
+	credentialRouter.HandleFunc("/{credential_id}/default", middlewares.AuthMiddleware(s.SetDefaultUserCredential, s.DB)).Methods("POST")
+	credentialRouter.HandleFunc("/{credential_id}/test", middlewares.AuthMiddleware(s.TestUserCredential, s.DB)).Methods("POST")
+
 	threadExecutionParamsRouter := v1Router.PathPrefix("/execparams").Subrouter()
 	threadExecutionParamsRouter.HandleFunc("/fetchall/{projectname}", middlewares.AuthMiddleware(s.ListThreadExecutionParams, s.DB)).Methods("GET")
 	threadExecutionParamsRouter.HandleFunc("/create", middlewares.AuthMiddleware(s.CreateThreadExecutionParams, s.DB)).Methods("POST")
@@ -53,10 +73,61 @@ func (s *Server) InitRoutes() {
 	threadExecutionParamsTemplateRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.DeleteThreadExecutionParamsTemplate, s.DB)).Methods("DELETE")
 	threadExecutionParamsTemplateRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.UpdateThreadExecutionParamsTemplate, s.DB)).Methods("PUT")
 
+	v1Router.HandleFunc("/models", middlewares.AuthMiddleware(s.ListModels, s.DB)).Methods("GET")
+	v1Router.HandleFunc("/usage", middlewares.AuthMiddleware(s.GetUsage, s.DB)).Methods("GET")
+
+	adminRouter := v1Router.PathPrefix("/admin").Subrouter()
+	adminRouter.HandleFunc("/ratelimits", middlewares.AuthMiddleware(middlewares.AdminMiddleware(s.ListRateLimits, s.DB), s.DB)).Methods("GET")
+	adminRouter.HandleFunc("/ratelimits", middlewares.AuthMiddleware(middlewares.AdminMiddleware(s.SetRateLimit, s.DB), s.DB)).Methods("PUT")
+	adminRouter.HandleFunc("/ratelimits/{ratelimit_id}", middlewares.AuthMiddleware(middlewares.AdminMiddleware(s.DeleteRateLimit, s.DB), s.DB)).Methods("DELETE")
+
 	projectRouter := v1Router.PathPrefix("/project").Subrouter()
 	projectRouter.HandleFunc("", middlewares.AuthMiddleware(s.ListProjects, s.DB)).Methods("GET")
 	projectRouter.HandleFunc("", middlewares.AuthMiddleware(s.CreateProject, s.DB)).Methods("POST")
 	projectRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.DeleteProject, s.DB)).Methods("DELETE")
 	projectRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.GetProject, s.DB)).Methods("GET")
 	projectRouter.HandleFunc("/{id}", middlewares.AuthMiddleware(s.UpdateProject, s.DB)).Methods("PUT")
+	projectRouter.HandleFunc("/{id}/webhooks", middlewares.AuthMiddleware(s.ListProjectWebhooks, s.DB)).Methods("GET")
+	projectRouter.HandleFunc("/{id}/webhooks", middlewares.AuthMiddleware(s.CreateProjectWebhook, s.DB)).Methods("POST")
+	projectRouter.HandleFunc("/{id}/webhooks/deliveries", middlewares.AuthMiddleware(s.ListWebhookDeliveries, s.DB)).Methods("GET")
+	projectRouter.HandleFunc("/{id}/webhooks/{webhook_id}", middlewares.AuthMiddleware(s.DeleteProjectWebhook, s.DB)).Methods("DELETE")
+	projectRouter.HandleFunc("/{id}/tools", middlewares.AuthMiddleware(s.ListProjectTools, s.DB)).Methods("GET")
+	projectRouter.HandleFunc("/{id}/tools", middlewares.AuthMiddleware(s.CreateProjectTool, s.DB)).Methods("POST")
+	projectRouter.HandleFunc("/{id}/tools/{tool_id}", middlewares.AuthMiddleware(s.UpdateProjectTool, s.DB)).Methods("PUT")
+	projectRouter.HandleFunc("/{id}/tools/{tool_id}", middlewares.AuthMiddleware(s.DeleteProjectTool, s.DB)).Methods("DELETE")
+	projectRouter.HandleFunc("/{id}/endpoints", middlewares.AuthMiddleware(s.ListProjectEndpoints, s.DB)).Methods("GET")
+	projectRouter.HandleFunc("/{id}/endpoints", middlewares.AuthMiddleware(s.CreateProjectEndpoint, s.DB)).Methods("POST")
+	projectRouter.HandleFunc("/{id}/endpoints/{endpoint_id}", middlewares.AuthMiddleware(s.UpdateProjectEndpoint, s.DB)).Methods("PUT")
+	projectRouter.HandleFunc("/{id}/endpoints/{endpoint_id}", middlewares.AuthMiddleware(s.DeleteProjectEndpoint, s.DB)).Methods("DELETE")
+	projectRouter.HandleFunc("/{id}/credentials", middlewares.AuthMiddleware(s.ListProjectCredentials, s.DB)).Methods("GET")
+	projectRouter.HandleFunc("/{id}/credentials", middlewares.AuthMiddleware(s.CreateProjectCredential, s.DB)).Methods("POST")
+	projectRouter.HandleFunc("/{id}/credentials/{credential_id}", middlewares.AuthMiddleware(s.UpdateProjectCredential, s.DB)).Methods("PUT")
+	projectRouter.HandleFunc("/{id}/credentials/{credential_id}", middlewares.AuthMiddleware(s.DeleteProjectCredential, s.DB)).Methods("DELETE")
+	projectRouter.HandleFunc("/{id}/credentials/{credential_id}/default", middlewares.AuthMiddleware(s.SetDefaultProjectCredential, s.DB)).Methods("POST")
+	projectRouter.HandleFunc("/{id}/credentials/{credential_id}/test", middlewares.AuthMiddleware(s.TestProjectCredential, s.DB)).Methods("POST")
+	projectRouter.HandleFunc("/{id}/budgets", middlewares.AuthMiddleware(s.ListProjectBudgets, s.DB)).Methods("GET")
+	projectRouter.HandleFunc("/{id}/budgets", middlewares.AuthMiddleware(s.CreateProjectBudget, s.DB)).Methods("POST")
+	projectRouter.HandleFunc("/{id}/budgets/{budget_id}", middlewares.AuthMiddleware(s.UpdateProjectBudget, s.DB)).Methods("PUT")
+	projectRouter.HandleFunc("/{id}/budgets/{budget_id}", middlewares.AuthMiddleware(s.DeleteProjectBudget, s.DB)).Methods("DELETE")
+	projectRouter.HandleFunc("/{id}/members", middlewares.AuthMiddleware(s.ListProjectMembers, s.DB)).Methods("GET")
+	projectRouter.HandleFunc("/{id}/members/{user_id}", middlewares.AuthMiddleware(s.UpdateProjectMember, s.DB)).Methods("PUT")
+	projectRouter.HandleFunc("/{id}/members/{user_id}", middlewares.AuthMiddleware(s.RemoveProjectMember, s.DB)).Methods("DELETE")
+	projectRouter.HandleFunc("/{id}/invitations", middlewares.AuthMiddleware(s.ListProjectInvitations, s.DB)).Methods("GET")
+	projectRouter.HandleFunc("/{id}/invitations", middlewares.AuthMiddleware(s.CreateProjectInvitation, s.DB)).Methods("POST")
+	projectRouter.HandleFunc("/{id}/invitations/{invitation_id}", middlewares.AuthMiddleware(s.DeleteProjectInvitation, s.DB)).Methods("DELETE")
+
+	organizationRouter := v1Router.PathPrefix("/organizations").Subrouter()
+	organizationRouter.HandleFunc("", middlewares.AuthMiddleware(s.ListOrganizations, s.DB)).Methods("GET")
+	organizationRouter.HandleFunc("", middlewares.AuthMiddleware(s.CreateOrganization, s.DB)).Methods("POST")
+	organizationRouter.HandleFunc("/{organization_id}", middlewares.AuthMiddleware(s.GetOrganization, s.DB)).Methods("GET")
+	organizationRouter.HandleFunc("/{organization_id}", middlewares.AuthMiddleware(s.UpdateOrganization, s.DB)).Methods("PUT")
+	organizationRouter.HandleFunc("/{organization_id}", middlewares.AuthMiddleware(s.DeleteOrganization, s.DB)).Methods("DELETE")
+	organizationRouter.HandleFunc("/{organization_id}/members", middlewares.AuthMiddleware(s.ListOrganizationMembers, s.DB)).Methods("GET")
+	organizationRouter.HandleFunc("/{organization_id}/members/{user_id}", middlewares.AuthMiddleware(s.UpdateOrganizationMember, s.DB)).Methods("PUT")
+	organizationRouter.HandleFunc("/{organization_id}/members/{user_id}", middlewares.AuthMiddleware(s.RemoveOrganizationMember, s.DB)).Methods("DELETE")
+	organizationRouter.HandleFunc("/{organization_id}/invitations", middlewares.AuthMiddleware(s.ListOrganizationInvitations, s.DB)).Methods("GET")
+	organizationRouter.HandleFunc("/{organization_id}/invitations", middlewares.AuthMiddleware(s.CreateOrganizationInvitation, s.DB)).Methods("POST")
+	organizationRouter.HandleFunc("/{organization_id}/invitations/{invitation_id}", middlewares.AuthMiddleware(s.DeleteOrganizationInvitation, s.DB)).Methods("DELETE")
+
+	v1Router.HandleFunc("/invitations/accept", middlewares.AuthMiddleware(s.AcceptInvitation, s.DB)).Methods("POST")
 }
@@ -3,8 +3,17 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/burnerlee/compextAI/controllers"
 	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat"
+	"github.com/burnerlee/compextAI/internal/providers/chat/local"
+	"github.com/burnerlee/compextAI/internal/ratelimit"
+	"github.com/burnerlee/compextAI/internal/secrets"
+	"github.com/burnerlee/compextAI/middlewares"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"gorm.io/gorm"
@@ -13,9 +22,10 @@ import (
 var s *Server
 
 type Server struct {
-	DB     *gorm.DB
-	Ctx    context.Context
-	Router *mux.Router
+	DB          *gorm.DB
+	Ctx         context.Context
+	Router      *mux.Router
+	RateLimiter *ratelimit.Limiter
 }
 
 var err error
@@ -32,6 +42,14 @@ func InitServer(ctx context.Context) (*Server, error) {
 	// add logger middleware to the router
 	s.Router.Use(logger.LoggerMiddleware)
 
+	if modelCatalogPath := os.Getenv(chat.MODEL_CATALOG_PATH_ENV); modelCatalogPath != "" {
+		logger.GetLogger().Infof("Loading model catalog: %s", modelCatalogPath)
+		if err := chat.LoadModelCatalog(modelCatalogPath); err != nil {
+			logger.GetLogger().Errorf("Error loading model catalog: %v", err)
+			return nil, err
+		}
+	}
+
 	// initialize the database
 	logger.GetLogger().Info("Initializing database")
 	s.DB, err = InitDB()
@@ -40,6 +58,23 @@ func InitServer(ctx context.Context) (*Server, error) {
 		return nil, err
 	}
 
+	// the environment is loaded with the database, the keys are needed by the
+	// migrations
+	if err := secrets.LoadKeyringFromEnv(); err != nil {
+		logger.GetLogger().Errorf("Error loading credentials encryption keys: %v", err)
+		return nil, err
+	}
+
+	if err := local.LoadEndpointAllowlistFromEnv(); err != nil {
+		logger.GetLogger().Errorf("Error loading local endpoint allowlist: %v", err)
+		return nil, err
+	}
+
+	if err := loadAzureEndpointAllowlistFromEnv(); err != nil {
+		logger.GetLogger().Errorf("Error loading azure endpoint allowlist: %v", err)
+		return nil, err
+	}
+
 	logger.GetLogger().Info("Migrating database")
 	if err := MigrateDB(s.DB); err != nil {
 		logger.GetLogger().Errorf("Error migrating database: %v", err)
@@ -48,6 +83,32 @@ func InitServer(ctx context.Context) (*Server, error) {
 
 	logger.GetLogger().Info("Database initialized successfully")
 
+	logger.GetLogger().Info("Recovering orphaned executions")
+	if err := controllers.RecoverOrphanedExecutions(s.DB); err != nil {
+		logger.GetLogger().Errorf("Error recovering orphaned executions: %v", err)
+		return nil, err
+	}
+
+	s.RateLimiter, err = ratelimit.NewLimiterFromEnv(s.DB)
+	if err != nil {
+		logger.GetLogger().Errorf("Error initializing rate limiter: %v", err)
+		return nil, err
+	}
+
+	executionWorkers := controllers.DEFAULT_EXECUTION_WORKERS
+	if workers := os.Getenv("EXECUTION_WORKERS"); workers != "" {
+		executionWorkers, err = strconv.Atoi(workers)
+		if err != nil {
+			logger.GetLogger().Errorf("Error parsing EXECUTION_WORKERS: %v", err)
+			return nil, err
+		}
+	}
+	controllers.StartExecutionWorkerPool(ctx, s.DB, executionWorkers, controllers.DEFAULT_EXECUTION_POLL_INTERVAL)
+	controllers.StartWebhookDispatcher(ctx, s.DB, controllers.DEFAULT_WEBHOOK_WORKERS, controllers.DEFAULT_EXECUTION_POLL_INTERVAL)
+	go controllers.RunExecutionRecovery(ctx, s.DB, controllers.DEFAULT_EXECUTION_RECOVERY_INTERVAL)
+	go middlewares.PurgeExpiredIdempotencyKeys(ctx, s.DB, time.Hour)
+	go middlewares.PurgeInactiveSessions(ctx, s.DB, time.Hour)
+
 	s.InitRoutes()
 
 	return s, nil
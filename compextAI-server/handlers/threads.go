@@ -74,14 +74,14 @@ func (s *Server) ListThreads(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, projectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, projectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
 	if err != nil {
 		responses.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// find all the threads from the db
-	threads, total, err := models.GetAllThreads(s.DB, uint(userID), projectID, searchQuery, searchFiltersMap, pageInt, limitInt)
+	threads, total, err := models.GetAllThreads(s.DB, projectID, searchQuery, searchFiltersMap, pageInt, limitInt)
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -114,7 +114,7 @@ func (s *Server) CreateThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID))
+	projectID, err := utils.GetProjectIDFromName(s.DB, request.ProjectName, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
 	if err != nil {
 		responses.Error(w, http.StatusBadRequest, err.Error())
 		return
@@ -154,7 +154,13 @@ func (s *Server) GetThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if thread.UserID != uint(userID) {
+	hasAccess, err := utils.CheckProjectRole(s.DB, thread.ProjectID, thread.UserID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_VIEWER)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !hasAccess {
 		responses.Error(w, http.StatusForbidden, "You are not authorized to access this thread")
 		return
 	}
@@ -188,7 +194,13 @@ func (s *Server) UpdateThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if thread.UserID != uint(userID) {
+	hasAccess, err := utils.CheckProjectRole(s.DB, thread.ProjectID, thread.UserID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !hasAccess {
 		responses.Error(w, http.StatusForbidden, "You are not authorized to update this thread")
 		return
 	}
@@ -236,7 +248,13 @@ func (s *Server) DeleteThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if thread.UserID != uint(userID) {
+	hasAccess, err := utils.CheckProjectRole(s.DB, thread.ProjectID, thread.UserID, uint(userID), utils.GetProjectScopeFromRequest(r), models.Role_EXECUTOR)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !hasAccess {
 		responses.Error(w, http.StatusForbidden, "You are not authorized to delete this thread")
 		return
 	}
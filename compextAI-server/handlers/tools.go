@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (s *Server) ListProjectTools(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	tools, err := models.GetProjectTools(s.DB, projectID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, tools)
+}
+
+func (s *Server) CreateProjectTool(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	var request CreateProjectToolRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// check if the tool name is already taken
+	if _, err := models.GetProjectToolByName(s.DB, projectID, request.Name); err == nil {
+		responses.Error(w, http.StatusBadRequest, "tool name already taken")
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	inputSchema := request.InputSchema
+	if len(inputSchema) == 0 {
+		inputSchema = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+
+	tool, err := models.CreateProjectTool(s.DB, &models.ProjectTool{
+		UserID:      userID,
+		ProjectID:   projectID,
+		Name:        request.Name,
+		Description: request.Description,
+		InputSchema: inputSchema,
+		URL:         request.URL,
+		Timeout:     request.Timeout,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, tool)
+}
+
+// getProjectToolFromRequest loads the tool of the request and writes the
+// error response when it does not belong to the project.
+func (s *Server) getProjectToolFromRequest(w http.ResponseWriter, r *http.Request, projectID string) (*models.ProjectTool, bool) {
+	tool, err := models.GetProjectTool(s.DB, mux.Vars(r)["tool_id"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "tool not found")
+			return nil, false
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if tool.ProjectID != projectID {
+		responses.Error(w, http.StatusNotFound, "tool not found")
+		return nil, false
+	}
+	return tool, true
+}
+
+func (s *Server) UpdateProjectTool(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	tool, ok := s.getProjectToolFromRequest(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	var request UpdateProjectToolRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := models.UpdateProjectTool(s.DB, &models.ProjectTool{
+		Base: models.Base{
+			Identifier: tool.Identifier,
+		},
+		Description: request.Description,
+		InputSchema: request.InputSchema,
+		URL:         request.URL,
+		Timeout:     request.Timeout,
+	}); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	updatedTool, err := models.GetProjectTool(s.DB, tool.Identifier)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, updatedTool)
+}
+
+func (s *Server) DeleteProjectTool(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	tool, ok := s.getProjectToolFromRequest(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteProjectTool(s.DB, tool.Identifier); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Tool deleted successfully")
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+)
+
+// tool names follow the function name rules of the providers
+var toolNameRegex = regexp.MustCompile("^[a-zA-Z0-9_-]{1,64}$")
+
+type CreateProjectToolRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+	URL         string          `json:"url"`
+	Timeout     int             `json:"timeout"`
+}
+
+func (r *CreateProjectToolRequest) Validate() error {
+	if !toolNameRegex.MatchString(r.Name) {
+		return errors.New("name is required and should only contain letters, digits, underscores and hyphens")
+	}
+	if r.URL == "" {
+		return errors.New("url is required")
+	}
+	if err := validatePublicHTTPURL(r.URL); err != nil {
+		return err
+	}
+	if r.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if len(r.InputSchema) > 0 && !json.Valid(r.InputSchema) {
+		return errors.New("input_schema must be valid json")
+	}
+	return nil
+}
+
+type UpdateProjectToolRequest struct {
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+	URL         string          `json:"url"`
+	Timeout     int             `json:"timeout"`
+}
+
+func (r *UpdateProjectToolRequest) Validate() error {
+	if r.URL != "" {
+		if err := validatePublicHTTPURL(r.URL); err != nil {
+			return err
+		}
+	}
+	if r.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if len(r.InputSchema) > 0 && !json.Valid(r.InputSchema) {
+		return errors.New("input_schema must be valid json")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"github.com/burnerlee/compextAI/utils/responses"
+)
+
+// GetUsage returns the token usage and the cost of the completed executions by
+// project, model and day, for every project of the user unless project_name
+// is set
+func (s *Server) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	query, err := parseUsageQuery(r)
+	if err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	projects, err := models.GetAllProjects(s.DB, uint(userID))
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	projectNames := make(map[string]string)
+	projectIDs := make([]string, 0)
+	projectScope := utils.GetProjectScopeFromRequest(r)
+	for _, project := range projects {
+		if !utils.InProjectScope(projectScope, project.Identifier) {
+			continue
+		}
+		if query.ProjectName != "" && project.Name != query.ProjectName {
+			continue
+		}
+		projectNames[project.Identifier] = project.Name
+		projectIDs = append(projectIDs, project.Identifier)
+	}
+	if query.ProjectName != "" && len(projectIDs) == 0 {
+		responses.Error(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	response := &UsageResponse{
+		From:  query.From.Format(USAGE_DATE_FORMAT),
+		To:    query.To.Format(USAGE_DATE_FORMAT),
+		Usage: make([]*UsageRecord, 0),
+	}
+	if len(projectIDs) == 0 {
+		responses.JSON(w, http.StatusOK, response)
+		return
+	}
+
+	usageAggregates, err := models.GetUsageAggregates(s.DB, projectIDs, query.Model, query.From, query.To.AddDate(0, 0, 1))
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for i := range usageAggregates {
+		usageAggregate := &usageAggregates[i]
+		record := &UsageRecord{
+			ProjectID:   usageAggregate.ProjectID,
+			ProjectName: projectNames[usageAggregate.ProjectID],
+			Model:       usageAggregate.Model,
+			Day:         usageAggregate.Day.Format(USAGE_DATE_FORMAT),
+		}
+		record.add(usageAggregate)
+		response.Total.add(usageAggregate)
+		response.Usage = append(response.Usage, record)
+	}
+
+	responses.JSON(w, http.StatusOK, response)
+}
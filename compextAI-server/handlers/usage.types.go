@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/burnerlee/compextAI/models"
+)
+
+const (
+	USAGE_DATE_FORMAT = "2006-01-02"
+	// the usage of the last 30 days is returned by default
+	DEFAULT_USAGE_DAYS = 30
+	MAX_USAGE_DAYS     = 366
+)
+
+type usageQuery struct {
+	ProjectName string
+	Model       string
+	// from and to are days in UTC, both are included
+	From time.Time
+	To   time.Time
+}
+
+// parseUsageQuery reads the project_name, model, from and to query params of
+// the usage request, the dates are formatted as 2006-01-02
+func parseUsageQuery(r *http.Request) (*usageQuery, error) {
+	query := &usageQuery{
+		ProjectName: r.URL.Query().Get("project_name"),
+		Model:       r.URL.Query().Get("model"),
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	query.To = today
+	if to := r.URL.Query().Get("to"); to != "" {
+		date, err := time.Parse(USAGE_DATE_FORMAT, to)
+		if err != nil {
+			return nil, fmt.Errorf("to must be a date such as %s", USAGE_DATE_FORMAT)
+		}
+		query.To = date
+	}
+	query.From = query.To.AddDate(0, 0, -(DEFAULT_USAGE_DAYS - 1))
+	if from := r.URL.Query().Get("from"); from != "" {
+		date, err := time.Parse(USAGE_DATE_FORMAT, from)
+		if err != nil {
+			return nil, fmt.Errorf("from must be a date such as %s", USAGE_DATE_FORMAT)
+		}
+		query.From = date
+	}
+
+	if query.From.After(query.To) {
+		return nil, fmt.Errorf("from must not be after to")
+	}
+	if query.To.Sub(query.From) >= MAX_USAGE_DAYS*24*time.Hour {
+		return nil, fmt.Errorf("the usage can be queried for at most %d days", MAX_USAGE_DAYS)
+	}
+	return query, nil
+}
+
+type UsageResponse struct {
+	From  string         `json:"from"`
+	To    string         `json:"to"`
+	Total UsageTotal     `json:"total"`
+	Usage []*UsageRecord `json:"usage"`
+}
+
+type UsageTotal struct {
+	Executions       int64   `json:"executions"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	CachedTokens     int64   `json:"cached_tokens"`
+	ReasoningTokens  int64   `json:"reasoning_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (t *UsageTotal) add(usageAggregate *models.UsageAggregate) {
+	t.Executions += usageAggregate.Executions
+	t.PromptTokens += usageAggregate.PromptTokens
+	t.CompletionTokens += usageAggregate.CompletionTokens
+	t.CachedTokens += usageAggregate.CachedTokens
+	t.ReasoningTokens += usageAggregate.ReasoningTokens
+	t.TotalTokens += usageAggregate.TotalTokens
+	t.Cost += usageAggregate.Cost
+}
+
+type UsageRecord struct {
+	ProjectID   string `json:"project_id"`
+	ProjectName string `json:"project_name"`
+	Model       string `json:"model"`
+	Day         string `json:"day"`
+	UsageTotal
+}
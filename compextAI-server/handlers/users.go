@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/burnerlee/compextAI/controllers"
 	"github.com/burnerlee/compextAI/models"
 	"github.com/burnerlee/compextAI/utils"
 	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 func (s *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -22,7 +25,7 @@ func (s *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := controllers.CreateUser(s.DB, &controllers.CreateUserRequest{
+	_, apiKey, err := controllers.CreateUser(s.DB, &controllers.CreateUserRequest{
 		Username: request.Username,
 		Password: request.Password,
 		Email:    request.Email,
@@ -33,7 +36,7 @@ func (s *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, CreateUserResponse{APIToken: user.APIToken})
+	responses.JSON(w, http.StatusOK, CreateUserResponse{APIToken: apiKey})
 }
 
 func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
@@ -48,57 +51,179 @@ func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := controllers.Login(s.DB, &controllers.LoginRequest{
-		Username: request.Username,
-		Password: request.Password,
+	session, sessionToken, err := controllers.Login(s.DB, &controllers.LoginRequest{
+		Username:  request.Username,
+		Password:  request.Password,
+		UserAgent: r.UserAgent(),
+	})
+
+	if err != nil {
+		if errors.Is(err, controllers.ErrInvalidCredentials) {
+			responses.Error(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, LoginResponse{
+		SessionToken: sessionToken,
+		ExpiresAt:    session.ExpiresAt,
 	})
+}
+
+// getSessionIDFromRequest returns the session of the request and writes the
+// error response when the request was authenticated with an api token
+func getSessionIDFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	sessionID := r.Header.Get(utils.SESSION_ID_HEADER)
+	if sessionID == "" {
+		responses.Error(w, http.StatusForbidden, "this endpoint requires a session token, log in to get one")
+		return "", false
+	}
+	return sessionID, true
+}
+
+func (s *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := getSessionIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.RevokeSession(s.DB, sessionID); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
+	responses.JSON(w, http.StatusOK, "Logged out successfully")
+}
+
+func (s *Server) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	sessions, err := models.GetActiveUserSessions(s.DB, uint(userID))
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	responses.JSON(w, http.StatusOK, LoginResponse{APIToken: user.APIToken})
+	responses.JSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	session, err := models.GetSession(s.DB, mux.Vars(r)["session_id"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "session not found")
+			return
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if session.UserID != uint(userID) {
+		responses.Error(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	if err := models.RevokeSession(s.DB, session.Identifier); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Session revoked successfully")
+}
+
+func (s *Server) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := models.RevokeUserSessions(s.DB, uint(userID)); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Sessions revoked successfully")
 }
 
 func (s *Server) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
 	userID, err := utils.GetUserIDFromRequest(r)
 	if err != nil {
 		responses.Error(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	user, err := models.GetUserByID(s.DB, uint(userID))
+	credentials, err := controllers.GetMaskedUserCredentials(s.DB, uint(userID))
 	if err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	responses.JSON(w, http.StatusOK, ListAPIKeysResponse{
-		AnthropicKey: user.AnthropicKey,
-		OpenAIKey:    user.OpenAIKey,
+		AnthropicKey:              credentials.AnthropicKey,
+		OpenAIKey:                 credentials.OpenAIKey,
+		AzureKey:                  credentials.AzureKey,
+		AzureEndpoint:             credentials.AzureEndpoint,
+		GoogleServiceAccountEmail: credentials.GoogleServiceAccountEmail,
 	})
 }
 
 func (s *Server) UpdateAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !checkUnscopedRequest(w, r) {
+		return
+	}
+
 	var request UpdateAPIKeysRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		responses.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	userID, err := utils.GetUserIDFromRequest(r)
 	if err != nil {
 		responses.Error(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	if err := models.UpdateUser(s.DB, &models.User{
-		Base: models.Base{
-			ID: uint(userID),
-		},
-		AnthropicKey: request.AnthropicKey,
-		OpenAIKey:    request.OpenAIKey,
+	if err := controllers.UpdateUserCredentials(s.DB, &controllers.UpdateUserCredentialsRequest{
+		UserID:                    uint(userID),
+		AnthropicKey:              request.AnthropicKey,
+		OpenAIKey:                 request.OpenAIKey,
+		AzureKey:                  request.AzureKey,
+		AzureEndpoint:             request.AzureEndpoint,
+		GoogleServiceAccountCreds: request.GoogleServiceAccountCreds,
 	}); err != nil {
 		responses.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -1,6 +1,10 @@
 package handlers
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
 
 type CreateUserRequest struct {
 	Username string `json:"username"`
@@ -21,10 +25,14 @@ func (r *CreateUserRequest) Validate() error {
 	if len(r.Password) < 8 {
 		return errors.New("password must be at least 8 characters long")
 	}
+	if len(r.Password) > 72 {
+		return errors.New("password must be at most 72 bytes long")
+	}
 	return nil
 }
 
 type CreateUserResponse struct {
+	// the default api key of the user
 	APIToken string `json:"api_token"`
 }
 
@@ -41,15 +49,41 @@ func (r *LoginRequest) Validate() error {
 }
 
 type LoginResponse struct {
-	APIToken string `json:"api_token"`
+	SessionToken string    `json:"session_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
+// ListAPIKeysResponse only shows the end of the provider keys
 type ListAPIKeysResponse struct {
-	AnthropicKey string `json:"anthropic_key"`
-	OpenAIKey    string `json:"openai_key"`
+	AnthropicKey              string `json:"anthropic_key"`
+	OpenAIKey                 string `json:"openai_key"`
+	AzureKey                  string `json:"azure_key"`
+	AzureEndpoint             string `json:"azure_endpoint"`
+	GoogleServiceAccountEmail string `json:"google_service_account_email"`
 }
 
+// UpdateAPIKeysRequest sets the default credential of every provider that is
+// set
 type UpdateAPIKeysRequest struct {
-	AnthropicKey string `json:"anthropic_key"`
-	OpenAIKey    string `json:"openai_key"`
+	AnthropicKey              string          `json:"anthropic_key"`
+	OpenAIKey                 string          `json:"openai_key"`
+	AzureKey                  string          `json:"azure_key"`
+	AzureEndpoint             string          `json:"azure_endpoint"`
+	GoogleServiceAccountCreds json.RawMessage `json:"google_service_account_creds"`
+}
+
+func (r *UpdateAPIKeysRequest) Validate() error {
+	if r.AzureEndpoint != "" {
+		if err := validateAzureEndpoint(r.AzureEndpoint); err != nil {
+			return err
+		}
+	}
+	// a null service account leaves the credential unchanged
+	if string(r.GoogleServiceAccountCreds) == "null" {
+		r.GoogleServiceAccountCreds = nil
+	}
+	if len(r.GoogleServiceAccountCreds) > 0 {
+		return validateServiceAccountCreds(string(r.GoogleServiceAccountCreds))
+	}
+	return nil
 }
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/burnerlee/compextAI/controllers"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// checkProjectRequestAccess resolves the project of the request and writes
+// the error response when the user does not have at least the role in it.
+func (s *Server) checkProjectRequestAccess(w http.ResponseWriter, r *http.Request, role string) (string, uint, bool) {
+	projectID := mux.Vars(r)["id"]
+	if projectID == "" {
+		responses.Error(w, http.StatusBadRequest, "project id is required")
+		return "", 0, false
+	}
+
+	userID, err := utils.GetUserIDFromRequest(r)
+	if err != nil {
+		responses.Error(w, http.StatusUnauthorized, err.Error())
+		return "", 0, false
+	}
+
+	hasAccess, err := utils.CheckProjectAccess(s.DB, projectID, uint(userID), utils.GetProjectScopeFromRequest(r), role)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return "", 0, false
+	}
+
+	if !hasAccess {
+		responses.Error(w, http.StatusForbidden, "you do not have access to this project")
+		return "", 0, false
+	}
+
+	return projectID, uint(userID), true
+}
+
+func (s *Server) ListProjectWebhooks(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	webhooks, err := models.GetProjectWebhooks(s.DB, projectID)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := ListProjectWebhooksResponse{
+		Webhooks: webhooks,
+	}
+
+	// the secret lets anyone sign deliveries and tool calls, only the editors
+	// can read it and not with a read only api key
+	canEdit, err := utils.CheckProjectAccess(s.DB, projectID, userID, utils.GetProjectScopeFromRequest(r), models.Role_EDITOR)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if canEdit && !utils.IsReadOnlyRequest(r) {
+		response.SigningSecret, err = controllers.GetProjectWebhookSecret(s.DB, projectID)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	responses.JSON(w, http.StatusOK, response)
+}
+
+func (s *Server) CreateProjectWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	var request CreateProjectWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		responses.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := models.CreateProjectWebhook(s.DB, &models.ProjectWebhook{
+		UserID:    userID,
+		ProjectID: projectID,
+		URL:       request.URL,
+	})
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, webhook)
+}
+
+func (s *Server) DeleteProjectWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_EDITOR)
+	if !ok {
+		return
+	}
+
+	webhookID := mux.Vars(r)["webhook_id"]
+	webhook, err := models.GetProjectWebhook(s.DB, webhookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.Error(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if webhook.ProjectID != projectID {
+		responses.Error(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+
+	if err := models.DeleteProjectWebhook(s.DB, webhookID); err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, "Webhook deleted successfully")
+}
+
+func (s *Server) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	projectID, _, ok := s.checkProjectRequestAccess(w, r, models.Role_VIEWER)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	threadExecutionID := r.URL.Query().Get("thread_execution_id")
+
+	deliveries, total, err := models.GetWebhookDeliveriesByProjectID(s.DB, projectID, threadExecutionID, page, limit)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"total":      total,
+	})
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/burnerlee/compextAI/internal/safehttp"
+	"github.com/burnerlee/compextAI/models"
+)
+
+type CreateProjectWebhookRequest struct {
+	URL string `json:"url"`
+}
+
+func (r *CreateProjectWebhookRequest) Validate() error {
+	if r.URL == "" {
+		return errors.New("url is required")
+	}
+	return validatePublicHTTPURL(r.URL)
+}
+
+type ListProjectWebhooksResponse struct {
+	Webhooks []models.ProjectWebhook `json:"webhooks"`
+	// deliveries are signed with this secret, see the X-Compext-Signature
+	// header. Only returned to the editors of the project.
+	SigningSecret string `json:"signing_secret,omitempty"`
+}
+
+// validateHTTPURL validates the urls the server sends requests to, i.e. the
+// webhooks, the project tools and the project endpoints
+func validateHTTPURL(rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %s", rawURL)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("url must be an http or https url: %s", rawURL)
+	}
+	if parsedURL.Host == "" {
+		return fmt.Errorf("url must have a host: %s", rawURL)
+	}
+	return nil
+}
+
+// validatePublicHTTPURL validates the urls set by the users that the server
+// sends requests to from its own network, i.e. the webhooks and the project
+// tools. The hosts resolving to a private address are rejected when
+// connecting.
+func validatePublicHTTPURL(rawURL string) error {
+	if err := validateHTTPURL(rawURL); err != nil {
+		return err
+	}
+	return safehttp.ValidateURL(rawURL)
+}
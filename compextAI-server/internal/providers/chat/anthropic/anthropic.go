@@ -0,0 +1,332 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ANTHROPIC_OWNER = "anthropic"
+)
+
+// Model is a model of the catalog that follows the anthropic messages api
+type Model struct {
+	config *base.ModelConfig
+}
+
+func NewModel(config *base.ModelConfig) *Model {
+	return &Model{
+		config: config,
+	}
+}
+
+func (g *Model) GetProviderOwner() string {
+	return g.config.Owner
+}
+
+func (g *Model) GetProviderModel() string {
+	return g.config.Model
+}
+
+func (g *Model) GetProviderIdentifier() string {
+	return g.config.Identifier
+}
+
+func (g *Model) GetModelConfig() *base.ModelConfig {
+	return g.config
+}
+
+func (g *Model) ValidateMessage(message *models.Message) error {
+	if message.ContentMap == nil {
+		return fmt.Errorf("message content is empty")
+	}
+
+	return g.config.ValidateMessageRole(message.Role)
+}
+
+type anthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+func (g *Model) ConvertMessageToProviderFormat(message *models.Message) (interface{}, error) {
+	var contentMap map[string]interface{}
+	if err := json.Unmarshal(message.ContentMap, &contentMap); err != nil {
+		return nil, err
+	}
+	content, ok := contentMap["content"]
+	if !ok {
+		return nil, fmt.Errorf("content map does not contain 'content' key")
+	}
+
+	// tool results are sent back by the user in anthropic
+	if message.Role == "tool" {
+		return anthropicMessage{
+			Role: "user",
+			Content: []interface{}{
+				map[string]interface{}{
+					"type":        "tool_result",
+					"tool_use_id": message.ToolCallID,
+					"content":     fmt.Sprintf("%v", content),
+				},
+			},
+		}, nil
+	}
+
+	toolCalls, err := models.ParseToolCalls(message.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	if message.Role == "assistant" && len(toolCalls) > 0 {
+		contentBlocks := make([]interface{}, 0)
+		if contentStr, ok := content.(string); ok && contentStr != "" {
+			contentBlocks = append(contentBlocks, map[string]interface{}{
+				"type": "text",
+				"text": contentStr,
+			})
+		}
+		for _, toolCall := range toolCalls {
+			var input interface{}
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &input); err != nil {
+				return nil, fmt.Errorf("error unmarshalling tool call arguments: %v", err)
+			}
+			contentBlocks = append(contentBlocks, map[string]interface{}{
+				"type":  "tool_use",
+				"id":    toolCall.ID,
+				"name":  toolCall.Function.Name,
+				"input": input,
+			})
+		}
+		content = contentBlocks
+	}
+
+	return anthropicMessage{
+		Role:    message.Role,
+		Content: content,
+	}, nil
+}
+
+func (g *Model) ParseUsage(response interface{}) (*base.Usage, error) {
+	return parseUsage(response)
+}
+
+func (g *Model) ConvertExecutionResponseToMessage(response interface{}) (*models.Message, error) {
+	responseMap, ok := response.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("response is not a map")
+	}
+
+	contentChoices := responseMap["content"].([]interface{})
+	if len(contentChoices) == 0 {
+		return nil, fmt.Errorf("no content found")
+	}
+
+	// collect the text blocks and convert the tool_use blocks to tool calls
+	text := ""
+	hasText := false
+	toolCalls := make([]models.ToolCall, 0)
+	for _, contentChoice := range contentChoices {
+		contentBlock, ok := contentChoice.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch contentBlock["type"] {
+		case "text":
+			blockText, _ := contentBlock["text"].(string)
+			text += blockText
+			hasText = true
+		case "tool_use":
+			arguments, err := json.Marshal(contentBlock["input"])
+			if err != nil {
+				return nil, fmt.Errorf("error marshalling tool use input: %v", err)
+			}
+			toolCallID, _ := contentBlock["id"].(string)
+			toolName, _ := contentBlock["name"].(string)
+			toolCalls = append(toolCalls, models.ToolCall{
+				ID:   toolCallID,
+				Type: "function",
+				Function: models.ToolCallFunction{
+					Name:      toolName,
+					Arguments: string(arguments),
+				},
+			})
+		}
+	}
+
+	var content any = text
+	if !hasText && len(toolCalls) == 0 {
+		content = contentChoices[0]
+	}
+
+	var toolCallsJson json.RawMessage
+	if len(toolCalls) > 0 {
+		var err error
+		toolCallsJson, err = json.Marshal(toolCalls)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	role, ok := responseMap["role"].(string)
+	if !ok {
+		return nil, fmt.Errorf("role is not a string")
+	}
+
+	anthropicChatCompletionID := responseMap["id"].(string)
+	usage := responseMap["usage"].(map[string]interface{})
+
+	metadata := map[string]interface{}{
+		"anthropic_chat_completion_id": anthropicChatCompletionID,
+		"usage":                        usage,
+	}
+
+	metadataJson, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	contentMap := map[string]interface{}{
+		"content": content,
+	}
+	contentMapJson, err := json.Marshal(contentMap)
+	if err != nil {
+		logger.GetLogger().Errorf("Error marshalling content map: %v", err)
+		return nil, fmt.Errorf("error marshalling content map: %v", err)
+	}
+
+	return &models.Message{
+		Role:       role,
+		ContentMap: contentMapJson,
+		Metadata:   metadataJson,
+		ToolCalls:  toolCallsJson,
+	}, nil
+}
+
+type claudeTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+type anthropicExecutionData struct {
+	APIKeys        map[string]string  `json:"api_keys"`
+	Model          string             `json:"model"`
+	Messages       []anthropicMessage `json:"messages"`
+	Temperature    float64            `json:"temperature"`
+	Timeout        int                `json:"timeout"`
+	MaxTokens      int                `json:"max_tokens"`
+	SystemPrompt   string             `json:"system_prompt"`
+	ResponseFormat interface{}        `json:"response_format"`
+	Tools          []*claudeTool      `json:"tools"`
+}
+
+func (d *anthropicExecutionData) Validate() error {
+	return nil
+}
+
+func (g *Model) NewSession(threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) base.ChatCompletionsSession {
+	return base.NewSession(g.config.Model, threadExecutionParamsTemplate, g.executeThread, g)
+}
+
+func (g *Model) executeThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error) {
+	systemPrompt := ""
+
+	modelMessages := make([]anthropicMessage, 0)
+	previousRole := ""
+	for _, message := range messages {
+		modelMessage, err := g.ConvertMessageToProviderFormat(message)
+		if err != nil {
+			logger.GetLogger().Errorf("Error converting message to provider format: %v", err)
+			return -1, nil, err
+		}
+		var contentMap map[string]interface{}
+		if err := json.Unmarshal(message.ContentMap, &contentMap); err != nil {
+			logger.GetLogger().Errorf("Error unmarshalling content map: %v", err)
+			return -1, nil, err
+		}
+		content, ok := contentMap["content"]
+		if !ok {
+			logger.GetLogger().Errorf("Content map does not contain 'content' key")
+			return -1, nil, fmt.Errorf("content map does not contain 'content' key")
+		}
+		if message.Role == "system" {
+			systemPromptStr, ok := content.(string)
+			if !ok {
+				logger.GetLogger().Errorf("System message content is not a string")
+				return -1, nil, fmt.Errorf("system message content is not a string")
+			}
+			systemPrompt = systemPromptStr
+			continue
+		}
+		// the results of parallel tool calls go in a single user message
+		if message.Role == "tool" && previousRole == "tool" {
+			lastMessage := &modelMessages[len(modelMessages)-1]
+			lastMessage.Content = append(lastMessage.Content.([]interface{}), modelMessage.(anthropicMessage).Content.([]interface{})...)
+			continue
+		}
+		previousRole = message.Role
+		modelMessages = append(modelMessages, modelMessage.(anthropicMessage))
+	}
+
+	// override the system prompt if it is provided for execution
+	if threadExecutionParamsTemplate.SystemPrompt != "" {
+		systemPrompt = threadExecutionParamsTemplate.SystemPrompt
+	}
+
+	if threadExecutionParamsTemplate.Temperature <= 0 {
+		threadExecutionParamsTemplate.Temperature = g.config.DefaultTemperature
+	}
+	if threadExecutionParamsTemplate.MaxTokens <= 0 {
+		threadExecutionParamsTemplate.MaxTokens = g.config.DefaultMaxTokens
+	}
+	if threadExecutionParamsTemplate.Timeout <= 0 {
+		threadExecutionParamsTemplate.Timeout = g.config.DefaultTimeout
+	}
+
+	claudeTools := make([]*claudeTool, 0)
+	for _, tool := range tools {
+		claudeTools = append(claudeTools, &claudeTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
+	anthropicKey, err := user.GetAnthropicKey()
+	if err != nil {
+		logger.GetLogger().Errorf("Error decrypting anthropic key: %v", err)
+		return -1, nil, err
+	}
+
+	executionData := anthropicExecutionData{
+		APIKeys:        map[string]string{ANTHROPIC_OWNER: anthropicKey},
+		Model:          g.config.Model,
+		Messages:       modelMessages,
+		Temperature:    threadExecutionParamsTemplate.Temperature,
+		MaxTokens:      threadExecutionParamsTemplate.MaxTokens,
+		Timeout:        threadExecutionParamsTemplate.Timeout,
+		SystemPrompt:   systemPrompt,
+		ResponseFormat: threadExecutionParamsTemplate.ResponseFormat,
+		Tools:          claudeTools,
+	}
+
+	if err := executionData.Validate(); err != nil {
+		logger.GetLogger().Errorf("Error validating execution data: %v", err)
+		return -1, nil, err
+	}
+
+	if threadExecutionParamsTemplate.Client == models.TemplateClient_NATIVE {
+		return g.executeNative(ctx, db, &executionData, threadExecutionParamsTemplate.ResponseFormat, threadExecutionIdentifier)
+	}
+
+	executionParams := &base.ExecuteParams{
+		Timeout: time.Duration(executionData.Timeout) * time.Second,
+	}
+
+	return base.Execute(ctx, db, g.config.ExecutorRoute, executionParams, executionData, threadExecutionIdentifier, modelMessages)
+}
@@ -0,0 +1,102 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"gorm.io/gorm"
+)
+
+const (
+	ANTHROPIC_BASE_URL_ENV     = "ANTHROPIC_BASE_URL"
+	ANTHROPIC_DEFAULT_BASE_URL = "https://api.anthropic.com"
+	ANTHROPIC_MESSAGES_ROUTE   = "/v1/messages"
+	ANTHROPIC_API_VERSION      = "2023-06-01"
+)
+
+type nativeMessagesRequest struct {
+	Model       string             `json:"model"`
+	Messages    []anthropicMessage `json:"messages"`
+	System      string             `json:"system,omitempty"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature,omitempty"`
+	Tools       []*claudeTool      `json:"tools,omitempty"`
+}
+
+// newNativeMessagesRequest converts the execution data of the executor to a
+// messages api request. The messages api has no response format, so the
+// templates with one are left to the executor.
+func newNativeMessagesRequest(executionData *anthropicExecutionData, responseFormat json.RawMessage) (*nativeMessagesRequest, error) {
+	if base.HasResponseFormat(responseFormat) {
+		return nil, fmt.Errorf("response_format is not supported by the native anthropic client")
+	}
+
+	return &nativeMessagesRequest{
+		Model:       executionData.Model,
+		Messages:    executionData.Messages,
+		System:      executionData.SystemPrompt,
+		MaxTokens:   executionData.MaxTokens,
+		Temperature: executionData.Temperature,
+		Tools:       executionData.Tools,
+	}, nil
+}
+
+func newNativeClient(baseURL, apiKey string, timeout time.Duration) *base.NativeClient {
+	return base.NewNativeClient(baseURL, map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": ANTHROPIC_API_VERSION,
+	}, timeout, nil)
+}
+
+// executeNative runs the execution against the messages api, the response
+// has the same shape as the one returned by the executor.
+func (g *Model) executeNative(ctx context.Context, db *gorm.DB, executionData *anthropicExecutionData, responseFormat json.RawMessage, threadExecutionIdentifier string) (int, interface{}, error) {
+	request, err := newNativeMessagesRequest(executionData, responseFormat)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	apiKey := executionData.APIKeys[ANTHROPIC_OWNER]
+	if apiKey == "" {
+		return -1, nil, fmt.Errorf("anthropic api key is not set")
+	}
+
+	// update thread execution metadata
+	if err := base.UpdateThreadExecutionMetadata(db, threadExecutionIdentifier, request, request.Messages); err != nil {
+		logger.GetLogger().Errorf("Error updating thread execution metadata: %v", err)
+		return -1, nil, err
+	}
+
+	client := newNativeClient(base.GetNativeBaseURL(ANTHROPIC_BASE_URL_ENV, ANTHROPIC_DEFAULT_BASE_URL), apiKey, time.Duration(executionData.Timeout)*time.Second)
+	statusCode, response, err := client.Post(ctx, ANTHROPIC_MESSAGES_ROUTE, request)
+	if err != nil || statusCode != http.StatusOK {
+		return statusCode, response, err
+	}
+
+	// the native client does not stream, the content is sent as a single delta
+	if onDelta := base.DeltaHandlerFromContext(ctx); onDelta != nil {
+		if content := getMessagesContent(response); content != "" {
+			onDelta(content)
+		}
+	}
+	return statusCode, response, nil
+}
+
+func getMessagesContent(response interface{}) string {
+	responseMap, _ := response.(map[string]interface{})
+	contentBlocks, _ := responseMap["content"].([]interface{})
+	content := ""
+	for _, contentBlock := range contentBlocks {
+		block, _ := contentBlock.(map[string]interface{})
+		if block["type"] == "text" {
+			text, _ := block["text"].(string)
+			content += text
+		}
+	}
+	return content
+}
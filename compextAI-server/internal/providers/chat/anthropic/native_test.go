@@ -0,0 +1,109 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/models"
+)
+
+func TestNativeMessages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ANTHROPIC_MESSAGES_ROUTE {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "test-key" {
+			t.Errorf("unexpected api key header: %s", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != ANTHROPIC_API_VERSION {
+			t.Errorf("unexpected version header: %s", got)
+		}
+
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("error decoding request: %v", err)
+		}
+		if request["system"] != "be brief" {
+			t.Errorf("unexpected system prompt: %v", request["system"])
+		}
+		if request["max_tokens"] != float64(1024) {
+			t.Errorf("unexpected max tokens: %v", request["max_tokens"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"content": [
+				{"type": "text", "text": "Let me check."},
+				{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}
+			],
+			"usage": {"input_tokens": 10, "output_tokens": 5}
+		}`))
+	}))
+	defer server.Close()
+
+	request, err := newNativeMessagesRequest(&anthropicExecutionData{
+		Model:        "claude-3-5-sonnet-20241022",
+		Messages:     []anthropicMessage{{Role: "user", Content: "weather in paris?"}},
+		MaxTokens:    1024,
+		SystemPrompt: "be brief",
+	}, json.RawMessage(`null`))
+	if err != nil {
+		t.Fatalf("error creating request: %v", err)
+	}
+
+	statusCode, response, err := newNativeClient(server.URL, "test-key", 5*time.Second).Post(context.Background(), ANTHROPIC_MESSAGES_ROUTE, request)
+	if err != nil {
+		t.Fatalf("error executing request: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", statusCode)
+	}
+
+	message, err := NewModel(&base.ModelConfig{Identifier: "claude-3-5-sonnet"}).ConvertExecutionResponseToMessage(response)
+	if err != nil {
+		t.Fatalf("error converting response: %v", err)
+	}
+	var contentMap map[string]interface{}
+	if err := json.Unmarshal(message.ContentMap, &contentMap); err != nil {
+		t.Fatalf("error unmarshalling content map: %v", err)
+	}
+	if contentMap["content"] != "Let me check." {
+		t.Errorf("unexpected content: %v", contentMap["content"])
+	}
+	toolCalls, err := models.ParseToolCalls(message.ToolCalls)
+	if err != nil {
+		t.Fatalf("error parsing tool calls: %v", err)
+	}
+	if len(toolCalls) != 1 || toolCalls[0].ID != "toolu_1" || toolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", toolCalls)
+	}
+}
+
+func TestNativeMessagesRejectsResponseFormat(t *testing.T) {
+	_, err := newNativeMessagesRequest(&anthropicExecutionData{Model: "claude-3-5-sonnet-20241022"}, json.RawMessage(`{"type":"json_object"}`))
+	if err == nil {
+		t.Errorf("expected the response format to be rejected")
+	}
+}
+
+func TestParseUsage(t *testing.T) {
+	var response interface{}
+	json.Unmarshal([]byte(`{"usage": {"input_tokens": 50, "output_tokens": 20, "cache_read_input_tokens": 100, "cache_creation_input_tokens": 10}}`), &response)
+
+	usage, err := parseUsage(response)
+	if err != nil {
+		t.Fatalf("error parsing usage: %v", err)
+	}
+	// the prompt tokens include the tokens read from and written to the cache
+	if usage.PromptTokens != 160 || usage.CachedTokens != 100 || usage.CompletionTokens != 20 || usage.TotalTokens != 180 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
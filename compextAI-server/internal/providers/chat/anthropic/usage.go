@@ -0,0 +1,29 @@
+package anthropic
+
+import "github.com/burnerlee/compextAI/internal/providers/chat/base"
+
+type anthropicUsage struct {
+	// the input tokens leave out the tokens read from and written to the cache
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+func parseUsage(response interface{}) (*base.Usage, error) {
+	var message struct {
+		Usage anthropicUsage `json:"usage"`
+	}
+	if err := base.DecodeResponse(response, &message); err != nil {
+		return nil, err
+	}
+
+	usage := message.Usage
+	promptTokens := usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+	return &base.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: usage.OutputTokens,
+		CachedTokens:     usage.CacheReadInputTokens,
+		TotalTokens:      promptTokens + usage.OutputTokens,
+	}, nil
+}
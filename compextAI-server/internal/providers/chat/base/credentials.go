@@ -0,0 +1,62 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	CREDENTIALS_TEST_ROUTE = "/credentials/test"
+	// the test makes a single small request to the vendor
+	CREDENTIALS_TEST_TIMEOUT = 30 * time.Second
+)
+
+type credentialsTestData struct {
+	Provider string                 `json:"provider"`
+	APIKeys  map[string]interface{} `json:"api_keys"`
+}
+
+type credentialsTestResponse struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error"`
+}
+
+// TestCredentials asks the executor to validate the credentials of the
+// provider against the vendor. The credentials rejected by the vendor are
+// reported with the reason, the error is returned when the executor could not
+// run the test.
+func TestCredentials(ctx context.Context, provider string, apiKeys map[string]interface{}) (bool, string, error) {
+	executorClient := getExecutorClient()
+
+	request, err := executorClient.getRequest(ctx, CREDENTIALS_TEST_ROUTE, "POST", credentialsTestData{
+		Provider: provider,
+		APIKeys:  apiKeys,
+	})
+	if err != nil {
+		return false, "", fmt.Errorf("error getting request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{
+		Timeout: CREDENTIALS_TEST_TIMEOUT,
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return false, "", fmt.Errorf("error executing request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("executor returned status %d", response.StatusCode)
+	}
+
+	var testResponse credentialsTestResponse
+	if err := json.NewDecoder(response.Body).Decode(&testResponse); err != nil {
+		return false, "", fmt.Errorf("error decoding response: %w", err)
+	}
+	return testResponse.Valid, testResponse.Error, nil
+}
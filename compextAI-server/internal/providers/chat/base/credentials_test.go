@@ -0,0 +1,38 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTestCredentials(t *testing.T) {
+	executor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != CREDENTIALS_TEST_ROUTE {
+			t.Errorf("unexpected route: %s", r.URL.Path)
+		}
+		var data credentialsTestData
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		if data.APIKeys["openai"] == "valid" {
+			json.NewEncoder(w).Encode(credentialsTestResponse{Valid: true})
+			return
+		}
+		json.NewEncoder(w).Encode(credentialsTestResponse{Error: "invalid api key"})
+	}))
+	defer executor.Close()
+	t.Setenv("EXECUTOR_BASE_URL", executor.URL)
+
+	valid, testError, err := TestCredentials(context.Background(), "openai", map[string]interface{}{"openai": "valid"})
+	if err != nil || !valid || testError != "" {
+		t.Errorf("expected valid credentials: %v, %q, %v", valid, testError, err)
+	}
+
+	valid, testError, err = TestCredentials(context.Background(), "openai", map[string]interface{}{"openai": "invalid"})
+	if err != nil || valid || testError != "invalid api key" {
+		t.Errorf("expected rejected credentials: %v, %q, %v", valid, testError, err)
+	}
+}
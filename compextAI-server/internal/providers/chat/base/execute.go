@@ -2,10 +2,13 @@ package base
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
@@ -27,7 +30,7 @@ type ExecuteParams struct {
 	Timeout time.Duration
 }
 
-func (c *executorClient) getRequest(execRoute, method string, data interface{}) (*http.Request, error) {
+func (c *executorClient) getRequest(ctx context.Context, execRoute, method string, data interface{}) (*http.Request, error) {
 	var body io.Reader
 	if data != nil {
 		dataJson, err := json.Marshal(data)
@@ -36,14 +39,19 @@ func (c *executorClient) getRequest(execRoute, method string, data interface{})
 		}
 		body = bytes.NewBuffer(dataJson)
 	}
-	request, err := http.NewRequest(method, c.BaseURL+execRoute, body)
+	request, err := http.NewRequestWithContext(ctx, method, c.BaseURL+execRoute, body)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 	return request, nil
 }
 
-func Execute(db *gorm.DB, execRoute string, executeParams *ExecuteParams, threadExecutionData interface{}, threadExecutionIdentifier string, messages interface{}) (int, interface{}, error) {
+func Execute(ctx context.Context, db *gorm.DB, execRoute string, executeParams *ExecuteParams, threadExecutionData interface{}, threadExecutionIdentifier string, messages interface{}) (int, interface{}, error) {
+	// stream the response when the caller asked for the deltas
+	if onDelta := DeltaHandlerFromContext(ctx); onDelta != nil {
+		return ExecuteStream(ctx, db, execRoute, executeParams, threadExecutionData, threadExecutionIdentifier, messages, onDelta)
+	}
+
 	executorClient := getExecutorClient()
 
 	// update thread execution metadata
@@ -52,7 +60,7 @@ func Execute(db *gorm.DB, execRoute string, executeParams *ExecuteParams, thread
 		return -1, nil, err
 	}
 
-	request, err := executorClient.getRequest(execRoute, "POST", threadExecutionData)
+	request, err := executorClient.getRequest(ctx, execRoute, "POST", threadExecutionData)
 	if err != nil {
 		return -1, nil, fmt.Errorf("error getting request: %w", err)
 	}
@@ -78,3 +86,11 @@ func Execute(db *gorm.DB, execRoute string, executeParams *ExecuteParams, thread
 
 	return response.StatusCode, responseData, nil
 }
+
+// IsTransportError reports whether err was returned by the request to the
+// executor itself, e.g. a timeout or a refused connection, as opposed to an
+// error while preparing the request or decoding the response.
+func IsTransportError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
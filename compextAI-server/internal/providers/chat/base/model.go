@@ -0,0 +1,159 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// the message and response formats implemented by the providers
+	PROTOCOL_OPENAI    = "openai"
+	PROTOCOL_ANTHROPIC = "anthropic"
+	PROTOCOL_GOOGLE    = "google"
+
+	// the system prompt is sent as a system message, or as the first user
+	// message for the models that do not support system prompts
+	SYSTEM_PROMPT_SYSTEM = "system"
+	SYSTEM_PROMPT_USER   = "user"
+
+	DEFAULT_MODEL_TIMEOUT = 600
+
+	// the sampling parameters of the templates, named like their json fields
+	PARAMETER_TEMPERATURE           = "temperature"
+	PARAMETER_TOP_P                 = "top_p"
+	PARAMETER_MAX_TOKENS            = "max_tokens"
+	PARAMETER_MAX_COMPLETION_TOKENS = "max_completion_tokens"
+	PARAMETER_MAX_OUTPUT_TOKENS     = "max_output_tokens"
+)
+
+var (
+	defaultAllowedRoles = []string{"user", "assistant", "system", "tool"}
+
+	defaultExecutorRoutes = map[string]string{
+		PROTOCOL_OPENAI:    "/chatcompletion/openai",
+		PROTOCOL_ANTHROPIC: "/chatcompletion/anthropic",
+		PROTOCOL_GOOGLE:    "/chatcompletion/google",
+	}
+
+	// the parameters sent to the vendor by each protocol
+	defaultSupportedParameters = map[string][]string{
+		PROTOCOL_OPENAI:    {PARAMETER_TEMPERATURE, PARAMETER_MAX_COMPLETION_TOKENS},
+		PROTOCOL_ANTHROPIC: {PARAMETER_TEMPERATURE, PARAMETER_MAX_TOKENS},
+		PROTOCOL_GOOGLE:    {PARAMETER_TEMPERATURE, PARAMETER_TOP_P, PARAMETER_MAX_OUTPUT_TOKENS},
+	}
+)
+
+// ModelConfig describes a model of the model catalog
+type ModelConfig struct {
+	// the model name used by the templates
+	Identifier string `json:"identifier"`
+	Owner      string `json:"owner"`
+	// the model name sent to the vendor
+	Model              string   `json:"model"`
+	Protocol           string   `json:"protocol"`
+	ExecutorRoute      string   `json:"executor_route"`
+	DefaultTemperature float64  `json:"default_temperature"`
+	DefaultMaxTokens   int      `json:"default_max_tokens"`
+	DefaultTimeout     int      `json:"default_timeout"`
+	AllowedRoles       []string `json:"allowed_roles"`
+	SystemPrompt       string   `json:"system_prompt"`
+	// the template parameters accepted by the model, defaults to the ones of
+	// the protocol
+	SupportedParameters []string `json:"supported_parameters"`
+	// capabilities reported to the clients, the context window is in tokens
+	// and 0 when unknown
+	SupportsTools    bool `json:"supports_tools"`
+	SupportsJSONMode bool `json:"supports_json_mode"`
+	ContextWindow    int  `json:"context_window"`
+	// used to compute the cost of the executions, the cost is 0 when unset
+	Pricing *ModelPricing `json:"pricing"`
+}
+
+// Validate checks the config and fills in the defaults of the optional fields
+func (c *ModelConfig) Validate() error {
+	if c.Identifier == "" {
+		return errors.New("identifier is required")
+	}
+	if c.Model == "" {
+		return fmt.Errorf("%s: model is required", c.Identifier)
+	}
+
+	defaultExecutorRoute, ok := defaultExecutorRoutes[c.Protocol]
+	if !ok {
+		return fmt.Errorf("%s: invalid protocol: %s", c.Identifier, c.Protocol)
+	}
+	if c.ExecutorRoute == "" {
+		c.ExecutorRoute = defaultExecutorRoute
+	}
+	if c.Owner == "" {
+		c.Owner = c.Protocol
+	}
+
+	switch c.SystemPrompt {
+	case "":
+		c.SystemPrompt = SYSTEM_PROMPT_SYSTEM
+	case SYSTEM_PROMPT_SYSTEM, SYSTEM_PROMPT_USER:
+	default:
+		return fmt.Errorf("%s: invalid system_prompt: %s", c.Identifier, c.SystemPrompt)
+	}
+
+	if len(c.AllowedRoles) == 0 {
+		c.AllowedRoles = defaultAllowedRoles
+	}
+	for _, role := range c.AllowedRoles {
+		if !slices.Contains(defaultAllowedRoles, role) {
+			return fmt.Errorf("%s: invalid role: %s", c.Identifier, role)
+		}
+	}
+
+	if c.SupportedParameters == nil {
+		c.SupportedParameters = defaultSupportedParameters[c.Protocol]
+	}
+	for _, parameter := range c.SupportedParameters {
+		if !slices.Contains(defaultSupportedParameters[c.Protocol], parameter) {
+			return fmt.Errorf("%s: parameter %s is not supported by the %s protocol", c.Identifier, parameter, c.Protocol)
+		}
+	}
+
+	if c.DefaultTemperature < 0 || c.DefaultMaxTokens < 0 || c.DefaultTimeout < 0 || c.ContextWindow < 0 {
+		return fmt.Errorf("%s: the defaults and the context window must not be negative", c.Identifier)
+	}
+	if c.DefaultTimeout == 0 {
+		c.DefaultTimeout = DEFAULT_MODEL_TIMEOUT
+	}
+	if c.Pricing != nil {
+		if err := c.Pricing.validate(); err != nil {
+			return fmt.Errorf("%s: %w", c.Identifier, err)
+		}
+	}
+	return nil
+}
+
+// ValidateMessageRole checks the role of the message against the allowed roles
+func (c *ModelConfig) ValidateMessageRole(role string) error {
+	if !slices.Contains(c.AllowedRoles, role) {
+		return fmt.Errorf("message role is invalid, only %v are allowed", c.AllowedRoles)
+	}
+	return nil
+}
+
+// SupportsNativeClient reports whether the model can be called by the server
+// itself, without the executor
+func (c *ModelConfig) SupportsNativeClient() bool {
+	return c.Protocol == PROTOCOL_OPENAI || c.Protocol == PROTOCOL_ANTHROPIC
+}
+
+// GetSupportedParameters returns the template parameters sent to the model,
+// the configs that were not validated use the parameters of the protocol
+func (c *ModelConfig) GetSupportedParameters() []string {
+	if c.SupportedParameters == nil {
+		return defaultSupportedParameters[c.Protocol]
+	}
+	return c.SupportedParameters
+}
+
+// SupportsParameter reports whether the template parameter is sent to the model
+func (c *ModelConfig) SupportsParameter(parameter string) bool {
+	return slices.Contains(c.GetSupportedParameters(), parameter)
+}
@@ -0,0 +1,80 @@
+package base
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// NativeClient calls a vendor api directly instead of going through the
+// executor. It is used by the providers for the templates with the native
+// client.
+type NativeClient struct {
+	BaseURL    string
+	Headers    map[string]string
+	HTTPClient *http.Client
+}
+
+// GetNativeBaseURL returns the base url set in the baseURLEnv environment
+// variable, or defaultBaseURL when it is unset.
+func GetNativeBaseURL(baseURLEnv, defaultBaseURL string) string {
+	if baseURL := os.Getenv(baseURLEnv); baseURL != "" {
+		return baseURL
+	}
+	return defaultBaseURL
+}
+
+// NewNativeClient returns a client for the base url, sending the requests
+// with the transport, the default one when nil. The redirects are not
+// followed, the response would go to the users wherever they point.
+func NewNativeClient(baseURL string, headers map[string]string, timeout time.Duration, transport http.RoundTripper) *NativeClient {
+	return &NativeClient{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Headers: headers,
+		HTTPClient: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Post sends the request body as json to the route and returns the decoded
+// response, in the same way Execute returns the response of the executor.
+// Error responses of the vendor are returned with their status code.
+func (c *NativeClient) Post(ctx context.Context, route string, data interface{}) (int, interface{}, error) {
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return -1, nil, fmt.Errorf("error marshalling data: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+route, bytes.NewReader(dataJson))
+	if err != nil {
+		return -1, nil, fmt.Errorf("error creating request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range c.Headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return -1, nil, fmt.Errorf("error executing request: %w", err)
+	}
+
+	defer response.Body.Close()
+
+	var responseData interface{}
+	if err := json.NewDecoder(response.Body).Decode(&responseData); err != nil {
+		return response.StatusCode, nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return response.StatusCode, responseData, nil
+}
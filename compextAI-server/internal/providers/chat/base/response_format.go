@@ -0,0 +1,204 @@
+package base
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	RESPONSE_FORMAT_TEXT        = "text"
+	RESPONSE_FORMAT_JSON_OBJECT = "json_object"
+	RESPONSE_FORMAT_JSON_SCHEMA = "json_schema"
+)
+
+var (
+	responseFormatNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+	jsonSchemaTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+)
+
+type responseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema *struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+		Strict *bool           `json:"strict"`
+	} `json:"json_schema"`
+}
+
+// HasResponseFormat reports whether the response format of a template is set,
+// the column defaults to {} and the handlers store null when it is unset
+func HasResponseFormat(responseFormat json.RawMessage) bool {
+	switch strings.TrimSpace(string(responseFormat)) {
+	case "", "{}", "null":
+		return false
+	}
+	return true
+}
+
+// ValidateResponseFormat checks the response format of a template against the
+// capabilities of the model, json schemas are checked to be well formed
+func (c *ModelConfig) ValidateResponseFormat(rawResponseFormat json.RawMessage) error {
+	if !HasResponseFormat(rawResponseFormat) {
+		return nil
+	}
+
+	var format responseFormat
+	if err := json.Unmarshal(rawResponseFormat, &format); err != nil {
+		return fmt.Errorf("invalid response_format: %w", err)
+	}
+
+	switch format.Type {
+	case RESPONSE_FORMAT_TEXT:
+		// the anthropic executor treats any response format as a structured output
+		if c.Protocol == PROTOCOL_ANTHROPIC {
+			return fmt.Errorf("response_format %s is not supported by %s, leave it unset", format.Type, c.Identifier)
+		}
+		return nil
+	case RESPONSE_FORMAT_JSON_OBJECT, RESPONSE_FORMAT_JSON_SCHEMA:
+	default:
+		return fmt.Errorf("invalid response_format type: %s, only %s, %s and %s are allowed", format.Type, RESPONSE_FORMAT_TEXT, RESPONSE_FORMAT_JSON_OBJECT, RESPONSE_FORMAT_JSON_SCHEMA)
+	}
+
+	if !c.SupportsJSONMode {
+		return fmt.Errorf("response_format %s is not supported by %s", format.Type, c.Identifier)
+	}
+	if format.Type == RESPONSE_FORMAT_JSON_OBJECT {
+		if c.Protocol == PROTOCOL_ANTHROPIC {
+			return fmt.Errorf("response_format %s is not supported by %s, use %s", format.Type, c.Identifier, RESPONSE_FORMAT_JSON_SCHEMA)
+		}
+		return nil
+	}
+
+	if format.JSONSchema == nil {
+		return errors.New("response_format.json_schema is required")
+	}
+	if !responseFormatNameRegex.MatchString(format.JSONSchema.Name) {
+		return errors.New("response_format.json_schema.name must be 1 to 64 letters, digits, underscores or dashes")
+	}
+	if len(format.JSONSchema.Schema) == 0 {
+		return errors.New("response_format.json_schema.schema is required")
+	}
+
+	var schema interface{}
+	if err := json.Unmarshal(format.JSONSchema.Schema, &schema); err != nil {
+		return fmt.Errorf("invalid response_format.json_schema.schema: %w", err)
+	}
+	if err := validateJSONSchema("response_format.json_schema.schema", schema); err != nil {
+		return err
+	}
+	// the vendors only return objects as structured outputs
+	if rootType, _ := schema.(map[string]interface{})["type"].(string); rootType != "object" {
+		return errors.New("response_format.json_schema.schema must be of type object")
+	}
+	return nil
+}
+
+// validateJSONSchema checks the keywords used by the vendors for structured
+// outputs, unknown keywords are left to the vendor
+func validateJSONSchema(path string, schema interface{}) error {
+	schemaMap, ok := schema.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s must be an object", path)
+	}
+
+	if schemaType, ok := schemaMap["type"]; ok {
+		types := []interface{}{schemaType}
+		if typeList, ok := schemaType.([]interface{}); ok {
+			types = typeList
+		}
+		for _, t := range types {
+			typeName, ok := t.(string)
+			if !ok || !slices.Contains(jsonSchemaTypes, typeName) {
+				return fmt.Errorf("%s.type: invalid type: %v", path, t)
+			}
+		}
+	}
+
+	var properties map[string]interface{}
+	if rawProperties, ok := schemaMap["properties"]; ok {
+		properties, ok = rawProperties.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.properties must be an object", path)
+		}
+		for name, property := range properties {
+			if err := validateJSONSchema(path+".properties."+name, property); err != nil {
+				return err
+			}
+		}
+	}
+
+	if rawRequired, ok := schemaMap["required"]; ok {
+		required, ok := rawRequired.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s.required must be an array", path)
+		}
+		for _, r := range required {
+			name, ok := r.(string)
+			if !ok {
+				return fmt.Errorf("%s.required must only contain strings", path)
+			}
+			if _, ok := properties[name]; !ok {
+				return fmt.Errorf("%s.required: %s is not a property", path, name)
+			}
+		}
+	}
+
+	if items, ok := schemaMap["items"]; ok {
+		if err := validateJSONSchema(path+".items", items); err != nil {
+			return err
+		}
+	}
+
+	if additionalProperties, ok := schemaMap["additionalProperties"]; ok {
+		if _, ok := additionalProperties.(bool); !ok {
+			if err := validateJSONSchema(path+".additionalProperties", additionalProperties); err != nil {
+				return err
+			}
+		}
+	}
+
+	if rawEnum, ok := schemaMap["enum"]; ok {
+		if enum, ok := rawEnum.([]interface{}); !ok || len(enum) == 0 {
+			return fmt.Errorf("%s.enum must be a non empty array", path)
+		}
+	}
+
+	for _, keyword := range []string{"anyOf", "oneOf", "allOf"} {
+		rawSubschemas, ok := schemaMap[keyword]
+		if !ok {
+			continue
+		}
+		subschemas, ok := rawSubschemas.([]interface{})
+		if !ok || len(subschemas) == 0 {
+			return fmt.Errorf("%s.%s must be a non empty array", path, keyword)
+		}
+		for i, subschema := range subschemas {
+			if err := validateJSONSchema(fmt.Sprintf("%s.%s[%d]", path, keyword, i), subschema); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, keyword := range []string{"$defs", "definitions"} {
+		rawDefinitions, ok := schemaMap[keyword]
+		if !ok {
+			continue
+		}
+		definitions, ok := rawDefinitions.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.%s must be an object", path, keyword)
+		}
+		for name, definition := range definitions {
+			if err := validateJSONSchema(path+"."+keyword+"."+name, definition); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
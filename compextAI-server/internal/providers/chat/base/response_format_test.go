@@ -0,0 +1,45 @@
+package base
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateResponseFormat(t *testing.T) {
+	openaiConfig := &ModelConfig{Identifier: "gpt-4o", Protocol: PROTOCOL_OPENAI, SupportsJSONMode: true}
+	anthropicConfig := &ModelConfig{Identifier: "claude-3-5-sonnet", Protocol: PROTOCOL_ANTHROPIC, SupportsJSONMode: true}
+	noJSONModeConfig := &ModelConfig{Identifier: "o1-preview", Protocol: PROTOCOL_OPENAI}
+
+	schema := `{"type": "json_schema", "json_schema": {"name": "answer", "schema": {"type": "object", "properties": {"answer": {"type": "string"}, "tags": {"type": "array", "items": {"type": "string"}}}, "required": ["answer"]}}}`
+
+	for _, tc := range []struct {
+		config         *ModelConfig
+		responseFormat string
+		valid          bool
+	}{
+		{openaiConfig, `null`, true},
+		{noJSONModeConfig, `{}`, true},
+		{openaiConfig, `{"type": "text"}`, true},
+		{openaiConfig, `{"type": "json_object"}`, true},
+		{openaiConfig, schema, true},
+		{anthropicConfig, schema, true},
+		{anthropicConfig, `{"type": "json_object"}`, false},
+		{anthropicConfig, `{"type": "text"}`, false},
+		{noJSONModeConfig, `{"type": "json_object"}`, false},
+		{openaiConfig, `{"type": "xml"}`, false},
+		{openaiConfig, `{"type": "json_schema"}`, false},
+		{openaiConfig, `{"type": "json_schema", "json_schema": {"name": "a b", "schema": {"type": "object"}}}`, false},
+		{openaiConfig, `{"type": "json_schema", "json_schema": {"name": "answer", "schema": {"type": "string"}}}`, false},
+		{openaiConfig, `{"type": "json_schema", "json_schema": {"name": "answer", "schema": {"type": "object", "properties": {"answer": {"type": "text"}}}}}`, false},
+		{openaiConfig, `{"type": "json_schema", "json_schema": {"name": "answer", "schema": {"type": "object", "properties": {}, "required": ["answer"]}}}`, false},
+		{openaiConfig, `{"type": "json_schema", "json_schema": {"name": "answer", "schema": {"type": "object", "properties": {"tags": {"type": "array", "items": []}}}}}`, false},
+	} {
+		err := tc.config.ValidateResponseFormat(json.RawMessage(tc.responseFormat))
+		if tc.valid && err != nil {
+			t.Errorf("%s: expected %s to be valid: %v", tc.config.Identifier, tc.responseFormat, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected %s to be invalid", tc.config.Identifier, tc.responseFormat)
+		}
+	}
+}
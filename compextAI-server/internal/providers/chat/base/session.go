@@ -0,0 +1,57 @@
+package base
+
+import (
+	"context"
+
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+// ChatCompletionsSession runs the thread executions of a template. The
+// providers are shared by all the executions, so everything that depends on
+// the template lives in the session created for the execution.
+type ChatCompletionsSession interface {
+	ResponseParser
+	// GetModel returns the model sent to the vendor
+	GetModel() string
+	ExecuteThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error)
+}
+
+// ExecuteThreadFunc executes the thread with the template of the session
+type ExecuteThreadFunc func(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error)
+
+// ResponseParser reads the responses of the vendor, it is implemented by the
+// providers
+type ResponseParser interface {
+	ConvertExecutionResponseToMessage(response interface{}) (*models.Message, error)
+	ParseUsage(response interface{}) (*Usage, error)
+}
+
+type session struct {
+	ResponseParser
+	model                         string
+	threadExecutionParamsTemplate models.ThreadExecutionParamsTemplate
+	executeThread                 ExecuteThreadFunc
+}
+
+// NewSession returns a session that executes the threads with a copy of the
+// template, so that it is not affected by later changes to the template
+func NewSession(model string, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, executeThread ExecuteThreadFunc, responseParser ResponseParser) ChatCompletionsSession {
+	return &session{
+		ResponseParser:                responseParser,
+		model:                         model,
+		threadExecutionParamsTemplate: *threadExecutionParamsTemplate,
+		executeThread:                 executeThread,
+	}
+}
+
+func (s *session) GetModel() string {
+	return s.model
+}
+
+func (s *session) ExecuteThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error) {
+	// the providers fill in the defaults of the template, every call gets its
+	// own copy so that retries and tool iterations start from the same template
+	threadExecutionParamsTemplate := s.threadExecutionParamsTemplate
+	return s.executeThread(ctx, db, user, messages, &threadExecutionParamsTemplate, threadExecutionIdentifier, tools)
+}
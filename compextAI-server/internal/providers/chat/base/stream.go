@@ -0,0 +1,115 @@
+package base
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	// the executor serves the streaming variant of every chat completion
+	// route under this suffix
+	STREAM_ROUTE_SUFFIX = "/stream"
+
+	// the completion event carries the whole response, so the scanner needs
+	// room for more than a single token
+	MAX_STREAM_EVENT_SIZE = 16 * 1024 * 1024
+)
+
+type deltaHandlerKey struct{}
+
+// WithDeltaHandler returns a context that makes Execute stream the
+// execution from the executor, calling onDelta for every content delta.
+func WithDeltaHandler(ctx context.Context, onDelta func(delta string)) context.Context {
+	return context.WithValue(ctx, deltaHandlerKey{}, onDelta)
+}
+
+// DeltaHandlerFromContext returns the delta handler set by WithDeltaHandler,
+// or nil when the execution is not streamed.
+func DeltaHandlerFromContext(ctx context.Context) func(delta string) {
+	onDelta, _ := ctx.Value(deltaHandlerKey{}).(func(delta string))
+	return onDelta
+}
+
+type streamEvent struct {
+	Type     string      `json:"type"`
+	Content  string      `json:"content"`
+	Response interface{} `json:"response"`
+	Error    string      `json:"error"`
+}
+
+// ExecuteStream is the streaming variant of Execute. The executor sends the
+// content deltas as server-sent events followed by a completion event that
+// carries the assembled response, which is returned in the same shape as the
+// response of Execute.
+func ExecuteStream(ctx context.Context, db *gorm.DB, execRoute string, executeParams *ExecuteParams, threadExecutionData interface{}, threadExecutionIdentifier string, messages interface{}, onDelta func(delta string)) (int, interface{}, error) {
+	executorClient := getExecutorClient()
+
+	// update thread execution metadata
+	if err := UpdateThreadExecutionMetadata(db, threadExecutionIdentifier, threadExecutionData, messages); err != nil {
+		logger.GetLogger().Errorf("Error updating thread execution metadata: %v", err)
+		return -1, nil, err
+	}
+
+	request, err := executorClient.getRequest(ctx, execRoute+STREAM_ROUTE_SUFFIX, "POST", threadExecutionData)
+	if err != nil {
+		return -1, nil, fmt.Errorf("error getting request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "text/event-stream")
+
+	client := &http.Client{
+		Timeout: executeParams.Timeout,
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return -1, nil, fmt.Errorf("error executing request: %w", err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var responseData interface{}
+		if err := json.NewDecoder(response.Body).Decode(&responseData); err != nil {
+			return response.StatusCode, nil, fmt.Errorf("error decoding response: %w", err)
+		}
+		return response.StatusCode, responseData, nil
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), MAX_STREAM_EVENT_SIZE)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return response.StatusCode, nil, fmt.Errorf("error decoding stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "delta":
+			onDelta(event.Content)
+		case "completion":
+			return response.StatusCode, event.Response, nil
+		case "error":
+			// the executor reports errors after the status code has been sent
+			return http.StatusInternalServerError, map[string]interface{}{"error": event.Error}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return response.StatusCode, nil, fmt.Errorf("error reading stream: %w", err)
+	}
+
+	return response.StatusCode, nil, fmt.Errorf("stream ended before the completion event")
+}
@@ -0,0 +1,70 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const TOKENS_PER_PRICE_UNIT = 1000000
+
+// Usage is the token usage of a response, normalized across the vendors. The
+// prompt tokens include the cached tokens and the completion tokens include
+// the reasoning tokens, like in the openai api.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	CachedTokens     int `json:"cached_tokens"`
+	ReasoningTokens  int `json:"reasoning_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *Usage) Add(usage *Usage) {
+	u.PromptTokens += usage.PromptTokens
+	u.CompletionTokens += usage.CompletionTokens
+	u.CachedTokens += usage.CachedTokens
+	u.ReasoningTokens += usage.ReasoningTokens
+	u.TotalTokens += usage.TotalTokens
+}
+
+// ModelPricing is the price of the model in USD per million tokens
+type ModelPricing struct {
+	PromptPrice     float64 `json:"prompt"`
+	CompletionPrice float64 `json:"completion"`
+	// the price of the cached prompt tokens, defaults to the prompt price
+	CachedPromptPrice float64 `json:"cached_prompt"`
+}
+
+func (p *ModelPricing) validate() error {
+	if p.PromptPrice < 0 || p.CompletionPrice < 0 || p.CachedPromptPrice < 0 {
+		return fmt.Errorf("the prices must not be negative")
+	}
+	return nil
+}
+
+// Cost returns the cost of the usage in USD, 0 when the model has no pricing
+func (c *ModelConfig) Cost(usage *Usage) float64 {
+	if c.Pricing == nil {
+		return 0
+	}
+	cachedPromptPrice := c.Pricing.CachedPromptPrice
+	if cachedPromptPrice == 0 {
+		cachedPromptPrice = c.Pricing.PromptPrice
+	}
+	cost := float64(usage.PromptTokens-usage.CachedTokens)*c.Pricing.PromptPrice +
+		float64(usage.CachedTokens)*cachedPromptPrice +
+		float64(usage.CompletionTokens)*c.Pricing.CompletionPrice
+	return cost / TOKENS_PER_PRICE_UNIT
+}
+
+// DecodeResponse decodes the response of the vendor into v, the responses are
+// passed around as generic json values
+func DecodeResponse(response interface{}, v interface{}) error {
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("error marshalling response: %w", err)
+	}
+	if err := json.Unmarshal(responseJson, v); err != nil {
+		return fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	return nil
+}
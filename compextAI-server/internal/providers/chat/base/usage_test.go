@@ -0,0 +1,25 @@
+package base
+
+import (
+	"math"
+	"testing"
+)
+
+func TestModelConfigCost(t *testing.T) {
+	usage := &Usage{PromptTokens: 1000000, CompletionTokens: 500000, CachedTokens: 200000}
+
+	config := &ModelConfig{Pricing: &ModelPricing{PromptPrice: 2.5, CompletionPrice: 10, CachedPromptPrice: 1.25}}
+	if cost := config.Cost(usage); math.Abs(cost-(0.8*2.5+0.2*1.25+0.5*10)) > 1e-9 {
+		t.Errorf("unexpected cost: %f", cost)
+	}
+
+	// the cached tokens are billed at the prompt price when the model has no cache price
+	config.Pricing.CachedPromptPrice = 0
+	if cost := config.Cost(usage); math.Abs(cost-(2.5+0.5*10)) > 1e-9 {
+		t.Errorf("unexpected cost: %f", cost)
+	}
+
+	if cost := (&ModelConfig{}).Cost(usage); cost != 0 {
+		t.Errorf("expected no cost without pricing: %f", cost)
+	}
+}
@@ -0,0 +1,101 @@
+package chat
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat/anthropic"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/internal/providers/chat/google"
+	"github.com/burnerlee/compextAI/internal/providers/chat/litellm"
+	"github.com/burnerlee/compextAI/internal/providers/chat/local"
+	"github.com/burnerlee/compextAI/internal/providers/chat/openai"
+)
+
+// the models available out of the box, the catalog file set in
+// MODEL_CATALOG_PATH adds to them and overrides them
+//
+//go:embed models.json
+var defaultModelCatalog []byte
+
+const MODEL_CATALOG_PATH_ENV = "MODEL_CATALOG_PATH"
+
+// the providers that are not part of the catalog
+const (
+	LITELLM ChatCompletionsProvider_Enum = litellm.LITELLM_IDENTIFIER
+	LOCAL   ChatCompletionsProvider_Enum = local.LOCAL_IDENTIFIER
+)
+
+var reservedIdentifiers = []ChatCompletionsProvider_Enum{LITELLM, LOCAL}
+
+func parseModelCatalog(data []byte) ([]*base.ModelConfig, error) {
+	var modelConfigs []*base.ModelConfig
+	if err := json.Unmarshal(data, &modelConfigs); err != nil {
+		return nil, fmt.Errorf("error unmarshalling model catalog: %w", err)
+	}
+
+	identifiers := make(map[string]bool)
+	for _, modelConfig := range modelConfigs {
+		if err := modelConfig.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid model: %w", err)
+		}
+		for _, reservedIdentifier := range reservedIdentifiers {
+			if modelConfig.Identifier == string(reservedIdentifier) {
+				return nil, fmt.Errorf("invalid model: identifier %s is reserved", modelConfig.Identifier)
+			}
+		}
+		if identifiers[modelConfig.Identifier] {
+			return nil, fmt.Errorf("invalid model: duplicate identifier %s", modelConfig.Identifier)
+		}
+		identifiers[modelConfig.Identifier] = true
+	}
+	return modelConfigs, nil
+}
+
+func newModelProvider(modelConfig *base.ModelConfig) ChatCompletionsProvider {
+	switch modelConfig.Protocol {
+	case base.PROTOCOL_ANTHROPIC:
+		return anthropic.NewModel(modelConfig)
+	case base.PROTOCOL_GOOGLE:
+		return google.NewModel(modelConfig)
+	}
+	return openai.NewModel(modelConfig)
+}
+
+func (r *ChatCompletionsProviderRegistry) registerModelCatalog(data []byte) error {
+	modelConfigs, err := parseModelCatalog(data)
+	if err != nil {
+		return err
+	}
+	for _, modelConfig := range modelConfigs {
+		r.register(newModelProvider(modelConfig))
+	}
+	return nil
+}
+
+// LoadModelCatalog registers the models of the catalog file at path, so that
+// models can be added without rebuilding the server. A model replaces the
+// model with the same identifier. It must be called before serving requests.
+func LoadModelCatalog(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading model catalog: %w", err)
+	}
+	return chatCompletionsProviderRegistry.registerModelCatalog(data)
+}
+
+func init() {
+	chatCompletionsProviderRegistry = NewChatCompletionsProviderRegistry()
+
+	if err := chatCompletionsProviderRegistry.registerModelCatalog(defaultModelCatalog); err != nil {
+		panic(fmt.Sprintf("error registering the default model catalog: %v", err))
+	}
+
+	// litellm provider
+	chatCompletionsProviderRegistry.register(litellm.NewLitellm())
+
+	// project endpoints provider
+	chatCompletionsProviderRegistry.register(local.NewLocal())
+}
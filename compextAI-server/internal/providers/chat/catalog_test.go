@@ -0,0 +1,50 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+)
+
+func TestDefaultModelCatalog(t *testing.T) {
+	provider, err := GetChatCompletionsProvider("o1")
+	if err != nil {
+		t.Fatalf("error getting provider: %v", err)
+	}
+	if provider.GetProviderOwner() != "openai" || provider.GetProviderModel() != "o1" {
+		t.Errorf("unexpected provider: %s, %s", provider.GetProviderOwner(), provider.GetProviderModel())
+	}
+
+	// the o-series models only accept the default temperature
+	if modelConfig := provider.GetModelConfig(); modelConfig.SupportsParameter(base.PARAMETER_TEMPERATURE) || !modelConfig.SupportsParameter(base.PARAMETER_MAX_COMPLETION_TOKENS) {
+		t.Errorf("unexpected supported parameters: %v", modelConfig.GetSupportedParameters())
+	}
+
+	if _, err := GetChatCompletionsProvider(string(LITELLM)); err != nil {
+		t.Errorf("expected litellm to be registered: %v", err)
+	}
+}
+
+func TestParseModelCatalog(t *testing.T) {
+	modelConfigs, err := parseModelCatalog([]byte(`[{"identifier": "mistral-large", "model": "mistral-large-latest", "protocol": "openai"}]`))
+	if err != nil {
+		t.Fatalf("error parsing catalog: %v", err)
+	}
+	modelConfig := modelConfigs[0]
+	if modelConfig.Owner != "openai" || modelConfig.ExecutorRoute != "/chatcompletion/openai" || modelConfig.SystemPrompt != base.SYSTEM_PROMPT_SYSTEM || modelConfig.DefaultTimeout != base.DEFAULT_MODEL_TIMEOUT {
+		t.Errorf("expected the defaults to be filled in: %+v", modelConfig)
+	}
+
+	for _, catalog := range []string{
+		`[{"identifier": "x", "model": "x", "protocol": "cohere"}]`,
+		`[{"identifier": "x", "protocol": "openai"}]`,
+		`[{"identifier": "litellm", "model": "x", "protocol": "openai"}]`,
+		`[{"identifier": "x", "model": "x", "protocol": "openai"}, {"identifier": "x", "model": "y", "protocol": "openai"}]`,
+		`[{"identifier": "x", "model": "x", "protocol": "openai", "system_prompt": "developer"}]`,
+		`[{"identifier": "x", "model": "x", "protocol": "openai", "supported_parameters": ["max_tokens"]}]`,
+	} {
+		if _, err := parseModelCatalog([]byte(catalog)); err == nil {
+			t.Errorf("expected catalog to be invalid: %s", catalog)
+		}
+	}
+}
@@ -2,25 +2,34 @@ package chat
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
 	"github.com/burnerlee/compextAI/models"
-	"gorm.io/gorm"
 )
 
 var (
 	chatCompletionsProviderRegistry *ChatCompletionsProviderRegistry
 )
 
+// ChatCompletionsProvider is registered once and shared by all the executions,
+// so it must not keep any state of an execution. The executions go through the
+// session returned by NewSession instead.
 type ChatCompletionsProvider interface {
 	ValidateMessage(message *models.Message) error
 	ConvertMessageToProviderFormat(message *models.Message) (interface{}, error)
 	ConvertExecutionResponseToMessage(response interface{}) (*models.Message, error)
+	ParseUsage(response interface{}) (*base.Usage, error)
 	GetProviderOwner() string
 	GetProviderModel() string
 	GetProviderIdentifier() string
-	ExecuteThread(db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error)
+	GetModelConfig() *base.ModelConfig
+	NewSession(threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) ChatCompletionsSession
 }
 
+type ChatCompletionsSession = base.ChatCompletionsSession
+
 type ChatCompletionsProvider_Enum string
 
 type ChatCompletionsProviderRegistry struct {
@@ -46,10 +55,14 @@ func GetChatCompletionsProvider(providerIdentifier string) (ChatCompletionsProvi
 	return provider, nil
 }
 
-func (r *ChatCompletionsProviderRegistry) getAvailableProviders() []ChatCompletionsProvider {
+// GetAvailableProviders returns the registered providers sorted by identifier
+func GetAvailableProviders() []ChatCompletionsProvider {
 	providers := make([]ChatCompletionsProvider, 0)
-	for _, provider := range r.providers {
+	for _, provider := range chatCompletionsProviderRegistry.providers {
 		providers = append(providers, provider)
 	}
+	slices.SortFunc(providers, func(a, b ChatCompletionsProvider) int {
+		return strings.Compare(a.GetProviderIdentifier(), b.GetProviderIdentifier())
+	})
 	return providers
 }
@@ -0,0 +1,418 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func validateMessage(message *models.Message, config *base.ModelConfig) error {
+	if message.ContentMap == nil {
+		return fmt.Errorf("message content map is nil")
+	}
+
+	return config.ValidateMessageRole(message.Role)
+}
+
+type geminiFunctionCall struct {
+	Name string      `json:"name"`
+	Args interface{} `json:"args"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role"`
+	Parts []geminiPart `json:"parts"`
+}
+
+func getMessageContent(message *models.Message) (interface{}, error) {
+	var contentMap map[string]interface{}
+	if err := json.Unmarshal(message.ContentMap, &contentMap); err != nil {
+		return nil, err
+	}
+	content, ok := contentMap["content"]
+	if !ok {
+		return nil, fmt.Errorf("content map does not contain 'content' key")
+	}
+	return content, nil
+}
+
+// convertContentToParts converts the content of a message to text parts. The
+// content is either a string or a list of openai style content parts.
+func convertContentToParts(content interface{}) ([]geminiPart, error) {
+	switch content := content.(type) {
+	case string:
+		if content == "" {
+			return []geminiPart{}, nil
+		}
+		return []geminiPart{{Text: content}}, nil
+	case []interface{}:
+		parts := make([]geminiPart, 0)
+		for _, contentPart := range content {
+			contentPartMap, ok := contentPart.(map[string]interface{})
+			if !ok || contentPartMap["type"] != "text" {
+				return nil, fmt.Errorf("only text content parts are supported")
+			}
+			text, _ := contentPartMap["text"].(string)
+			parts = append(parts, geminiPart{Text: text})
+		}
+		return parts, nil
+	}
+	return nil, fmt.Errorf("message content is neither a string nor a list of content parts")
+}
+
+// convertMessageToProviderFormat converts the message to gemini contents.
+// Gemini identifies the function calls by name only, so the names of the
+// tool calls of the thread are needed to convert the tool results.
+func convertMessageToProviderFormat(message *models.Message, toolCallNames map[string]string) (geminiContent, error) {
+	content, err := getMessageContent(message)
+	if err != nil {
+		return geminiContent{}, err
+	}
+
+	switch message.Role {
+	case "tool":
+		name, ok := toolCallNames[message.ToolCallID]
+		if !ok {
+			name = message.ToolCallID
+		}
+		return geminiContent{
+			Role: "user",
+			Parts: []geminiPart{{
+				FunctionResponse: &geminiFunctionResponse{
+					Name: name,
+					Response: map[string]interface{}{
+						"content": content,
+					},
+				},
+			}},
+		}, nil
+	case "assistant":
+		parts, err := convertContentToParts(content)
+		if err != nil {
+			return geminiContent{}, err
+		}
+		toolCalls, err := models.ParseToolCalls(message.ToolCalls)
+		if err != nil {
+			return geminiContent{}, err
+		}
+		for _, toolCall := range toolCalls {
+			var args interface{}
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+				return geminiContent{}, fmt.Errorf("error unmarshalling tool call arguments: %v", err)
+			}
+			parts = append(parts, geminiPart{
+				FunctionCall: &geminiFunctionCall{
+					Name: toolCall.Function.Name,
+					Args: args,
+				},
+			})
+		}
+		return geminiContent{
+			Role:  "model",
+			Parts: parts,
+		}, nil
+	}
+
+	parts, err := convertContentToParts(content)
+	if err != nil {
+		return geminiContent{}, err
+	}
+	return geminiContent{
+		Role:  message.Role,
+		Parts: parts,
+	}, nil
+}
+
+func convertExecutionResponseToMessage(response interface{}) (*models.Message, error) {
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	var generateContentResponse struct {
+		ResponseID string `json:"responseId"`
+		Candidates []struct {
+			Content struct {
+				Parts []geminiPart `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		UsageMetadata map[string]interface{} `json:"usageMetadata"`
+		ModelVersion  string                 `json:"modelVersion"`
+	}
+	if err := json.Unmarshal(responseJson, &generateContentResponse); err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %v", err)
+	}
+	if len(generateContentResponse.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidates found")
+	}
+	candidate := generateContentResponse.Candidates[0]
+
+	// collect the text parts and convert the function calls to tool calls
+	var text strings.Builder
+	toolCalls := make([]models.ToolCall, 0)
+	for _, part := range candidate.Content.Parts {
+		if part.FunctionCall != nil {
+			arguments, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil {
+				return nil, fmt.Errorf("error marshalling function call args: %v", err)
+			}
+			toolCalls = append(toolCalls, models.ToolCall{
+				ID:   "call_" + uuid.New().String(),
+				Type: "function",
+				Function: models.ToolCallFunction{
+					Name:      part.FunctionCall.Name,
+					Arguments: string(arguments),
+				},
+			})
+			continue
+		}
+		text.WriteString(part.Text)
+	}
+
+	var toolCallsJson json.RawMessage
+	if len(toolCalls) > 0 {
+		toolCallsJson, err = json.Marshal(toolCalls)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	metadata := map[string]interface{}{
+		"gemini_response_id": generateContentResponse.ResponseID,
+		"model_version":      generateContentResponse.ModelVersion,
+		"finish_reason":      candidate.FinishReason,
+		"usage":              generateContentResponse.UsageMetadata,
+	}
+	metadataJson, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	contentMap := map[string]interface{}{
+		"content": text.String(),
+	}
+	contentMapJson, err := json.Marshal(contentMap)
+	if err != nil {
+		logger.GetLogger().Errorf("Error marshalling content map: %v", err)
+		return nil, fmt.Errorf("error marshalling content map: %v", err)
+	}
+
+	return &models.Message{
+		Role:       "assistant",
+		ContentMap: contentMapJson,
+		Metadata:   metadataJson,
+		ToolCalls:  toolCallsJson,
+	}, nil
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []*geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature      float64     `json:"temperature,omitempty"`
+	TopP             float64     `json:"topP,omitempty"`
+	MaxOutputTokens  int         `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string      `json:"responseMimeType,omitempty"`
+	ResponseSchema   interface{} `json:"responseSchema,omitempty"`
+}
+
+type geminiExecutionData struct {
+	APIKeys           map[string]interface{}  `json:"api_keys"`
+	Model             string                  `json:"model"`
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"system_instruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generation_config"`
+	Tools             []*geminiTool           `json:"tools,omitempty"`
+	Timeout           int                     `json:"timeout"`
+}
+
+func (d *geminiExecutionData) Validate() error {
+	if len(d.Contents) == 0 {
+		return fmt.Errorf("at least one non system message is required")
+	}
+	return nil
+}
+
+// setResponseFormat maps an openai style response format to the json mode
+// of gemini.
+func (c *geminiGenerationConfig) setResponseFormat(responseFormat json.RawMessage) error {
+	if len(responseFormat) == 0 {
+		return nil
+	}
+	var responseFormatMap map[string]interface{}
+	if err := json.Unmarshal(responseFormat, &responseFormatMap); err != nil {
+		return fmt.Errorf("error unmarshalling response format: %v", err)
+	}
+
+	switch responseFormatMap["type"] {
+	case nil, "text":
+		return nil
+	case "json_object":
+		c.ResponseMimeType = "application/json"
+	case "json_schema":
+		jsonSchema, _ := responseFormatMap["json_schema"].(map[string]interface{})
+		c.ResponseMimeType = "application/json"
+		c.ResponseSchema = jsonSchema["schema"]
+	default:
+		return fmt.Errorf("unsupported response format type: %v", responseFormatMap["type"])
+	}
+	return nil
+}
+
+type ExecuteParamConfigs struct {
+	Model                  string
+	ExecutorRoute          string
+	DefaultTemperature     float64
+	DefaultMaxOutputTokens int
+	DefaultTimeout         int
+}
+
+func BaseExecuteThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, configs *ExecuteParamConfigs, tools []*models.ExecutionTool) (int, interface{}, error) {
+	if threadExecutionParamsTemplate.Client == models.TemplateClient_NATIVE {
+		return -1, nil, fmt.Errorf("the native client is not supported for %s", configs.Model)
+	}
+
+	systemPrompt := ""
+
+	contents := make([]geminiContent, 0)
+	toolCallNames := make(map[string]string)
+	previousRole := ""
+	for _, message := range messages {
+		if message.Role == "system" {
+			content, err := getMessageContent(message)
+			if err != nil {
+				return -1, nil, err
+			}
+			systemPromptStr, ok := content.(string)
+			if !ok {
+				logger.GetLogger().Errorf("System message content is not a string")
+				return -1, nil, fmt.Errorf("system message content is not a string")
+			}
+			systemPrompt = systemPromptStr
+			continue
+		}
+
+		content, err := convertMessageToProviderFormat(message, toolCallNames)
+		if err != nil {
+			logger.GetLogger().Errorf("Error converting message to provider format: %v", err)
+			return -1, nil, err
+		}
+
+		toolCalls, err := models.ParseToolCalls(message.ToolCalls)
+		if err != nil {
+			return -1, nil, err
+		}
+		for _, toolCall := range toolCalls {
+			toolCallNames[toolCall.ID] = toolCall.Function.Name
+		}
+
+		// the responses of parallel function calls go in a single content
+		if message.Role == "tool" && previousRole == "tool" {
+			lastContent := &contents[len(contents)-1]
+			lastContent.Parts = append(lastContent.Parts, content.Parts...)
+			continue
+		}
+		previousRole = message.Role
+		contents = append(contents, content)
+	}
+
+	// override the system prompt if it is provided for execution
+	if threadExecutionParamsTemplate.SystemPrompt != "" {
+		systemPrompt = threadExecutionParamsTemplate.SystemPrompt
+	}
+
+	if threadExecutionParamsTemplate.Temperature <= 0 {
+		threadExecutionParamsTemplate.Temperature = configs.DefaultTemperature
+	}
+	if threadExecutionParamsTemplate.MaxOutputTokens <= 0 {
+		threadExecutionParamsTemplate.MaxOutputTokens = configs.DefaultMaxOutputTokens
+	}
+	if threadExecutionParamsTemplate.Timeout <= 0 {
+		threadExecutionParamsTemplate.Timeout = configs.DefaultTimeout
+	}
+
+	generationConfig := &geminiGenerationConfig{
+		Temperature:     threadExecutionParamsTemplate.Temperature,
+		TopP:            threadExecutionParamsTemplate.TopP,
+		MaxOutputTokens: threadExecutionParamsTemplate.MaxOutputTokens,
+	}
+	if err := generationConfig.setResponseFormat(threadExecutionParamsTemplate.ResponseFormat); err != nil {
+		return -1, nil, err
+	}
+
+	geminiTools := make([]*geminiTool, 0)
+	if len(tools) > 0 {
+		functionDeclarations := make([]*geminiFunctionDeclaration, 0)
+		for _, tool := range tools {
+			functionDeclarations = append(functionDeclarations, &geminiFunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			})
+		}
+		geminiTools = append(geminiTools, &geminiTool{
+			FunctionDeclarations: functionDeclarations,
+		})
+	}
+
+	googleServiceAccountCreds, err := user.GetGoogleServiceAccountCreds()
+	if err != nil {
+		logger.GetLogger().Errorf("Error decrypting google service account credentials: %v", err)
+		return -1, nil, err
+	}
+
+	executionData := geminiExecutionData{
+		APIKeys: map[string]interface{}{
+			"google_service_account_creds": googleServiceAccountCreds,
+		},
+		Model:            configs.Model,
+		Contents:         contents,
+		GenerationConfig: generationConfig,
+		Tools:            geminiTools,
+		Timeout:          threadExecutionParamsTemplate.Timeout,
+	}
+	if systemPrompt != "" {
+		executionData.SystemInstruction = &geminiContent{
+			Role:  "system",
+			Parts: []geminiPart{{Text: systemPrompt}},
+		}
+	}
+
+	if err := executionData.Validate(); err != nil {
+		logger.GetLogger().Errorf("Error validating execution data: %v", err)
+		return -1, nil, err
+	}
+
+	executionParams := &base.ExecuteParams{
+		Timeout: time.Duration(executionData.Timeout) * time.Second,
+	}
+
+	return base.Execute(ctx, db, configs.ExecutorRoute, executionParams, executionData, threadExecutionIdentifier, contents)
+}
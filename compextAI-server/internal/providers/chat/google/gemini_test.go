@@ -0,0 +1,82 @@
+package google
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/burnerlee/compextAI/models"
+)
+
+func TestConvertToolCallRoundTrip(t *testing.T) {
+	message, err := convertExecutionResponseToMessage(map[string]interface{}{
+		"responseId": "resp_1",
+		"candidates": []interface{}{map[string]interface{}{
+			"content": map[string]interface{}{
+				"role": "model",
+				"parts": []interface{}{
+					map[string]interface{}{"text": "Let me check."},
+					map[string]interface{}{"functionCall": map[string]interface{}{"name": "get_weather", "args": map[string]interface{}{"city": "Paris"}}},
+				},
+			},
+			"finishReason": "STOP",
+		}},
+		"usageMetadata": map[string]interface{}{"promptTokenCount": 10, "candidatesTokenCount": 5, "totalTokenCount": 15},
+	})
+	if err != nil {
+		t.Fatalf("error converting response: %v", err)
+	}
+
+	toolCalls, err := models.ParseToolCalls(message.ToolCalls)
+	if err != nil {
+		t.Fatalf("error parsing tool calls: %v", err)
+	}
+	if len(toolCalls) != 1 || toolCalls[0].Function.Name != "get_weather" || toolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Fatalf("unexpected tool calls: %+v", toolCalls)
+	}
+
+	assistantContent, err := convertMessageToProviderFormat(message, nil)
+	if err != nil {
+		t.Fatalf("error converting assistant message: %v", err)
+	}
+	if assistantContent.Role != "model" || len(assistantContent.Parts) != 2 || assistantContent.Parts[1].FunctionCall == nil {
+		t.Errorf("unexpected assistant content: %+v", assistantContent)
+	}
+
+	// the tool result refers to the call by id, gemini by name
+	toolContent, err := convertMessageToProviderFormat(&models.Message{
+		Role:       "tool",
+		ToolCallID: toolCalls[0].ID,
+		ContentMap: json.RawMessage(`{"content": "sunny"}`),
+	}, map[string]string{toolCalls[0].ID: toolCalls[0].Function.Name})
+	if err != nil {
+		t.Fatalf("error converting tool message: %v", err)
+	}
+	functionResponse := toolContent.Parts[0].FunctionResponse
+	if toolContent.Role != "user" || functionResponse == nil || functionResponse.Name != "get_weather" || functionResponse.Response["content"] != "sunny" {
+		t.Errorf("unexpected tool content: %+v", toolContent)
+	}
+}
+
+func TestSetResponseFormat(t *testing.T) {
+	generationConfig := &geminiGenerationConfig{}
+	if err := generationConfig.setResponseFormat(json.RawMessage(`{"type": "json_schema", "json_schema": {"name": "weather", "schema": {"type": "object"}}}`)); err != nil {
+		t.Fatalf("error setting response format: %v", err)
+	}
+	if generationConfig.ResponseMimeType != "application/json" || generationConfig.ResponseSchema == nil {
+		t.Errorf("unexpected generation config: %+v", generationConfig)
+	}
+}
+
+func TestParseUsage(t *testing.T) {
+	var response interface{}
+	json.Unmarshal([]byte(`{"usageMetadata": {"promptTokenCount": 100, "cachedContentTokenCount": 20, "candidatesTokenCount": 30, "thoughtsTokenCount": 10, "totalTokenCount": 140}}`), &response)
+
+	usage, err := parseUsage(response)
+	if err != nil {
+		t.Fatalf("error parsing usage: %v", err)
+	}
+	// the thinking tokens are counted as completion tokens, like in the openai api
+	if usage.PromptTokens != 100 || usage.CachedTokens != 20 || usage.CompletionTokens != 40 || usage.ReasoningTokens != 10 || usage.TotalTokens != 140 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
@@ -0,0 +1,66 @@
+package google
+
+import (
+	"context"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+// Model is a model of the catalog that follows the gemini api
+type Model struct {
+	config *base.ModelConfig
+}
+
+func NewModel(config *base.ModelConfig) *Model {
+	return &Model{
+		config: config,
+	}
+}
+
+func (g *Model) GetProviderOwner() string {
+	return g.config.Owner
+}
+
+func (g *Model) GetProviderModel() string {
+	return g.config.Model
+}
+
+func (g *Model) GetProviderIdentifier() string {
+	return g.config.Identifier
+}
+
+func (g *Model) GetModelConfig() *base.ModelConfig {
+	return g.config
+}
+
+func (g *Model) ValidateMessage(message *models.Message) error {
+	return validateMessage(message, g.config)
+}
+
+func (g *Model) ConvertMessageToProviderFormat(message *models.Message) (interface{}, error) {
+	return convertMessageToProviderFormat(message, nil)
+}
+
+func (g *Model) ConvertExecutionResponseToMessage(response interface{}) (*models.Message, error) {
+	return convertExecutionResponseToMessage(response)
+}
+
+func (g *Model) ParseUsage(response interface{}) (*base.Usage, error) {
+	return parseUsage(response)
+}
+
+func (g *Model) NewSession(threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) base.ChatCompletionsSession {
+	return base.NewSession(g.config.Model, threadExecutionParamsTemplate, g.executeThread, g)
+}
+
+func (g *Model) executeThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error) {
+	return BaseExecuteThread(ctx, db, user, messages, threadExecutionParamsTemplate, threadExecutionIdentifier, &ExecuteParamConfigs{
+		Model:                  g.config.Model,
+		ExecutorRoute:          g.config.ExecutorRoute,
+		DefaultTemperature:     g.config.DefaultTemperature,
+		DefaultMaxOutputTokens: g.config.DefaultMaxTokens,
+		DefaultTimeout:         g.config.DefaultTimeout,
+	}, tools)
+}
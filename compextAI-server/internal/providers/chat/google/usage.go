@@ -0,0 +1,35 @@
+package google
+
+import "github.com/burnerlee/compextAI/internal/providers/chat/base"
+
+type geminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	// the candidates token count leaves out the thinking tokens
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+func parseUsage(response interface{}) (*base.Usage, error) {
+	var generateContentResponse struct {
+		UsageMetadata geminiUsageMetadata `json:"usageMetadata"`
+	}
+	if err := base.DecodeResponse(response, &generateContentResponse); err != nil {
+		return nil, err
+	}
+
+	usage := generateContentResponse.UsageMetadata
+	completionTokens := usage.CandidatesTokenCount + usage.ThoughtsTokenCount
+	totalTokens := usage.TotalTokenCount
+	if totalTokens == 0 {
+		totalTokens = usage.PromptTokenCount + completionTokens
+	}
+	return &base.Usage{
+		PromptTokens:     usage.PromptTokenCount,
+		CompletionTokens: completionTokens,
+		CachedTokens:     usage.CachedContentTokenCount,
+		ReasoningTokens:  usage.ThoughtsTokenCount,
+		TotalTokens:      totalTokens,
+	}, nil
+}
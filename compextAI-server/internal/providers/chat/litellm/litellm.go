@@ -1,6 +1,10 @@
 package litellm
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
 	"github.com/burnerlee/compextAI/internal/providers/chat/openai"
 	"github.com/burnerlee/compextAI/models"
 	"gorm.io/gorm"
@@ -14,18 +18,26 @@ const (
 type Litellm struct {
 	allowedRoles   []string
 	executorRoute  string
-	openaiProvider *openai.GPT4O
+	openaiProvider *openai.Model
 	owner          string
-	model          string
 }
 
 func NewLitellm() *Litellm {
+	allowedRoles := []string{"user", "assistant", "system", "tool"}
 	return &Litellm{
-		allowedRoles:  []string{"user", "assistant", "system", "tool"},
+		allowedRoles:  allowedRoles,
 		executorRoute: LITTELM_EXECUTOR_ROUTE,
 		// adding openai provider since litellm follows openai specs
-		openaiProvider: openai.NewGPT4O(),
-		owner:          "litellm",
+		openaiProvider: openai.NewModel(&base.ModelConfig{
+			Identifier:   LITELLM_IDENTIFIER,
+			Owner:        "litellm",
+			Protocol:     base.PROTOCOL_OPENAI,
+			AllowedRoles: allowedRoles,
+			// the model is the one of the template, so only the api is known
+			SupportsTools:    true,
+			SupportsJSONMode: true,
+		}),
+		owner: "litellm",
 	}
 }
 
@@ -33,14 +45,19 @@ func (l *Litellm) GetProviderOwner() string {
 	return l.owner
 }
 
+// GetProviderModel returns an empty model, the model is the one of the template
 func (l *Litellm) GetProviderModel() string {
-	return l.model
+	return ""
 }
 
 func (l *Litellm) GetProviderIdentifier() string {
 	return LITELLM_IDENTIFIER
 }
 
+func (l *Litellm) GetModelConfig() *base.ModelConfig {
+	return l.openaiProvider.GetModelConfig()
+}
+
 func (l *Litellm) ValidateMessage(message *models.Message) error {
 	return l.openaiProvider.ValidateMessage(message)
 }
@@ -53,16 +70,51 @@ func (l *Litellm) ConvertExecutionResponseToMessage(response interface{}) (*mode
 	return l.openaiProvider.ConvertExecutionResponseToMessage(response)
 }
 
-func (l *Litellm) ExecuteThread(db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error) {
-	l.model = threadExecutionParamsTemplate.Model
-	return openai.BaseExecuteThread(db, user, messages, threadExecutionParamsTemplate, threadExecutionIdentifier, &openai.ExecuteParamConfigs{
-		Model:         l.model,
+func (l *Litellm) ParseUsage(response interface{}) (*base.Usage, error) {
+	return l.openaiProvider.ParseUsage(response)
+}
+
+func (l *Litellm) NewSession(threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) base.ChatCompletionsSession {
+	return base.NewSession(threadExecutionParamsTemplate.Model, threadExecutionParamsTemplate, l.executeThread, l)
+}
+
+func (l *Litellm) executeThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error) {
+	apiKeys, err := getAPIKeys(user)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	return openai.BaseExecuteThread(ctx, db, user, messages, threadExecutionParamsTemplate, threadExecutionIdentifier, &openai.ExecuteParamConfigs{
+		Model:         threadExecutionParamsTemplate.Model,
 		ExecutorRoute: l.executorRoute,
-	}, tools, map[string]interface{}{
-		"openai":                       user.OpenAIKey,
-		"anthropic":                    user.AnthropicKey,
-		"azure":                        user.AzureKey,
+	}, tools, apiKeys)
+}
+
+// getAPIKeys decrypts the credentials of every vendor, litellm picks the one
+// of the model
+func getAPIKeys(user *models.User) (map[string]interface{}, error) {
+	openAIKey, err := user.GetOpenAIKey()
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting openai key: %w", err)
+	}
+	anthropicKey, err := user.GetAnthropicKey()
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting anthropic key: %w", err)
+	}
+	azureKey, err := user.GetAzureKey()
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting azure key: %w", err)
+	}
+	googleServiceAccountCreds, err := user.GetGoogleServiceAccountCreds()
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting google service account credentials: %w", err)
+	}
+
+	return map[string]interface{}{
+		"openai":                       openAIKey,
+		"anthropic":                    anthropicKey,
+		"azure":                        azureKey,
 		"azure_endpoint":               user.AzureEndpoint,
-		"google_service_account_creds": user.GoogleServiceAccountCreds,
-	})
+		"google_service_account_creds": googleServiceAccountCreds,
+	}, nil
 }
@@ -0,0 +1,81 @@
+package litellm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// the sessions are executed concurrently against a fake executor, run with
+// -race to catch state shared between the executions
+func TestConcurrentSessions(t *testing.T) {
+	executor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// echo the model, so that the response tells which model was sent
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": "chatcmpl-1",
+			"choices": []interface{}{map[string]interface{}{
+				"index":   0,
+				"message": map[string]interface{}{"role": "assistant", "content": request["model"]},
+			}},
+		})
+	}))
+	defer executor.Close()
+	t.Setenv("EXECUTOR_BASE_URL", executor.URL)
+
+	// the executions record their metadata, nothing is sent to the database
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+
+	provider := NewLitellm()
+	contentMap, _ := json.Marshal(map[string]interface{}{"content": "hello"})
+	messages := []*models.Message{{Role: "user", ContentMap: contentMap}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			model := fmt.Sprintf("model-%d", i)
+			session := provider.NewSession(&models.ThreadExecutionParamsTemplate{Model: model})
+			if session.GetModel() != model {
+				t.Errorf("unexpected session model: %s, expected %s", session.GetModel(), model)
+			}
+
+			statusCode, response, err := session.ExecuteThread(context.Background(), db, &models.User{}, messages, fmt.Sprintf("execution-%d", i), nil)
+			if err != nil || statusCode != http.StatusOK {
+				t.Errorf("error executing thread: %d: %v", statusCode, err)
+				return
+			}
+			message, err := session.ConvertExecutionResponseToMessage(response)
+			if err != nil {
+				t.Errorf("error converting response: %v", err)
+				return
+			}
+			var content map[string]interface{}
+			if err := json.Unmarshal(message.ContentMap, &content); err != nil {
+				t.Errorf("error unmarshalling content: %v", err)
+				return
+			}
+			if content["content"] != model {
+				t.Errorf("executor received model %v, expected %s", content["content"], model)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
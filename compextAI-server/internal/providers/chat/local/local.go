@@ -0,0 +1,162 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/internal/providers/chat/openai"
+	"github.com/burnerlee/compextAI/internal/safehttp"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const (
+	LOCAL_IDENTIFIER = "local"
+	LOCAL_OWNER      = "local"
+	// the models of the project endpoints are named local/<endpoint name>/<model>
+	LOCAL_MODEL_PREFIX = "local/"
+
+	DEFAULT_TEMPERATURE = 0.5
+	DEFAULT_TIMEOUT     = 600
+
+	// the private hosts, ips and cidrs the project endpoints may point to,
+	// comma separated, the endpoints are otherwise limited to public addresses
+	LOCAL_ENDPOINT_ALLOWED_HOSTS_ENV = "LOCAL_ENDPOINT_ALLOWED_HOSTS"
+)
+
+var (
+	endpointAllowlist *safehttp.Allowlist
+	endpointTransport http.RoundTripper = safehttp.NewTransport(nil)
+)
+
+// LoadEndpointAllowlistFromEnv loads the allowlist of LOCAL_ENDPOINT_ALLOWED_HOSTS,
+// it runs at startup before the endpoints are validated or called
+func LoadEndpointAllowlistFromEnv() error {
+	allowlist, err := safehttp.ParseAllowlist(os.Getenv(LOCAL_ENDPOINT_ALLOWED_HOSTS_ENV))
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %w", LOCAL_ENDPOINT_ALLOWED_HOSTS_ENV, err)
+	}
+	endpointAllowlist = allowlist
+	endpointTransport = safehttp.NewTransport(allowlist)
+	return nil
+}
+
+// ValidateEndpointURL rejects the base urls of the project endpoints that
+// target a private address which is not allowed
+func ValidateEndpointURL(baseURL string) error {
+	return safehttp.ValidateAllowedURL(baseURL, endpointAllowlist)
+}
+
+type Local struct {
+	owner          string
+	allowedRoles   []string
+	openaiProvider *openai.Model
+}
+
+func NewLocal() *Local {
+	allowedRoles := []string{"user", "assistant", "system", "tool"}
+	return &Local{
+		owner:        LOCAL_OWNER,
+		allowedRoles: allowedRoles,
+		// the endpoints follow the openai specs
+		openaiProvider: openai.NewModel(&base.ModelConfig{
+			Identifier:   LOCAL_IDENTIFIER,
+			Owner:        LOCAL_OWNER,
+			Protocol:     base.PROTOCOL_OPENAI,
+			AllowedRoles: allowedRoles,
+			// local models can be slow, especially on their first call
+			DefaultTemperature: DEFAULT_TEMPERATURE,
+			DefaultTimeout:     DEFAULT_TIMEOUT,
+			// the model is the one of the template, so only the api is known
+			SupportsTools:    true,
+			SupportsJSONMode: true,
+		}),
+	}
+}
+
+func (l *Local) GetProviderOwner() string {
+	return l.owner
+}
+
+func (l *Local) GetProviderModel() string {
+	return ""
+}
+
+func (l *Local) GetProviderIdentifier() string {
+	return LOCAL_IDENTIFIER
+}
+
+func (l *Local) GetModelConfig() *base.ModelConfig {
+	return l.openaiProvider.GetModelConfig()
+}
+
+func (l *Local) ValidateMessage(message *models.Message) error {
+	return l.openaiProvider.ValidateMessage(message)
+}
+
+func (l *Local) ConvertMessageToProviderFormat(message *models.Message) (interface{}, error) {
+	return l.openaiProvider.ConvertMessageToProviderFormat(message)
+}
+
+func (l *Local) ConvertExecutionResponseToMessage(response interface{}) (*models.Message, error) {
+	return l.openaiProvider.ConvertExecutionResponseToMessage(response)
+}
+
+func (l *Local) ParseUsage(response interface{}) (*base.Usage, error) {
+	return l.openaiProvider.ParseUsage(response)
+}
+
+// IsLocalModel reports whether the model is served by a project endpoint
+func IsLocalModel(model string) bool {
+	return strings.HasPrefix(model, LOCAL_MODEL_PREFIX)
+}
+
+// ParseLocalModel splits a local model into the name of the project endpoint
+// and the name of the model on the endpoint.
+func ParseLocalModel(model string) (string, string, error) {
+	endpointName, endpointModel, ok := strings.Cut(strings.TrimPrefix(model, LOCAL_MODEL_PREFIX), "/")
+	if !IsLocalModel(model) || !ok || endpointName == "" || endpointModel == "" {
+		return "", "", fmt.Errorf("invalid local model: %s, expected %s<endpoint name>/<model>", model, LOCAL_MODEL_PREFIX)
+	}
+	return endpointName, endpointModel, nil
+}
+
+func (l *Local) NewSession(threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) base.ChatCompletionsSession {
+	// invalid models fail when the thread is executed
+	model := threadExecutionParamsTemplate.Model
+	if _, endpointModel, err := ParseLocalModel(threadExecutionParamsTemplate.Model); err == nil {
+		model = endpointModel
+	}
+	return base.NewSession(model, threadExecutionParamsTemplate, l.executeThread, l)
+}
+
+func (l *Local) executeThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error) {
+	endpointName, endpointModel, err := ParseLocalModel(threadExecutionParamsTemplate.Model)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	endpoint, err := models.GetProjectEndpointByName(db, threadExecutionParamsTemplate.ProjectID, endpointName)
+	if err != nil {
+		return -1, nil, fmt.Errorf("error getting project endpoint: %s: %v", endpointName, err)
+	}
+	apiKey, err := endpoint.GetAPIKey()
+	if err != nil {
+		return -1, nil, fmt.Errorf("error decrypting api key of project endpoint: %s: %v", endpointName, err)
+	}
+
+	return openai.BaseExecuteThread(ctx, db, user, messages, threadExecutionParamsTemplate, threadExecutionIdentifier, &openai.ExecuteParamConfigs{
+		Model:              endpointModel,
+		DefaultTemperature: l.GetModelConfig().DefaultTemperature,
+		DefaultTimeout:     l.GetModelConfig().DefaultTimeout,
+		Endpoint: &openai.NativeEndpoint{
+			BaseURL:   endpoint.BaseURL,
+			APIKey:    apiKey,
+			Transport: endpointTransport,
+		},
+	}, tools, nil)
+}
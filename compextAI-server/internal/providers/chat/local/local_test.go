@@ -0,0 +1,25 @@
+package local
+
+import "testing"
+
+func TestParseLocalModel(t *testing.T) {
+	endpointName, endpointModel, err := ParseLocalModel("local/ollama/llama3.1:8b")
+	if err != nil {
+		t.Fatalf("error parsing local model: %v", err)
+	}
+	if endpointName != "ollama" || endpointModel != "llama3.1:8b" {
+		t.Errorf("unexpected endpoint and model: %s, %s", endpointName, endpointModel)
+	}
+
+	// models of the endpoint may contain slashes themselves
+	_, endpointModel, err = ParseLocalModel("local/vllm/meta-llama/Llama-3.1-8B-Instruct")
+	if err != nil || endpointModel != "meta-llama/Llama-3.1-8B-Instruct" {
+		t.Errorf("unexpected model: %s: %v", endpointModel, err)
+	}
+
+	for _, model := range []string{"gpt-4o", "local/ollama", "local//llama3", "local/ollama/"} {
+		if _, _, err := ParseLocalModel(model); err == nil {
+			t.Errorf("expected %s to be invalid", model)
+		}
+	}
+}
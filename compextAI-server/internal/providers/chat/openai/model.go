@@ -0,0 +1,116 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+// Model is a model of the catalog that follows the openai specs
+type Model struct {
+	config *base.ModelConfig
+}
+
+func NewModel(config *base.ModelConfig) *Model {
+	return &Model{
+		config: config,
+	}
+}
+
+func (g *Model) GetProviderOwner() string {
+	return g.config.Owner
+}
+
+func (g *Model) GetProviderModel() string {
+	return g.config.Model
+}
+
+func (g *Model) GetProviderIdentifier() string {
+	return g.config.Identifier
+}
+
+func (g *Model) GetModelConfig() *base.ModelConfig {
+	return g.config
+}
+
+func (g *Model) ValidateMessage(message *models.Message) error {
+	return validateMessage(message, g.config)
+}
+
+func (g *Model) ConvertMessageToProviderFormat(message *models.Message) (interface{}, error) {
+	return convertMessageToProviderFormat(message)
+}
+
+func (g *Model) ConvertExecutionResponseToMessage(response interface{}) (*models.Message, error) {
+	return convertExecutionResponseToMessage(response)
+}
+
+func (g *Model) ParseUsage(response interface{}) (*base.Usage, error) {
+	return ParseUsage(response)
+}
+
+func (g *Model) NewSession(threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) base.ChatCompletionsSession {
+	return base.NewSession(g.config.Model, threadExecutionParamsTemplate, g.executeThread, g)
+}
+
+func (g *Model) executeThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, tools []*models.ExecutionTool) (int, interface{}, error) {
+	if g.config.SystemPrompt == base.SYSTEM_PROMPT_USER {
+		var err error
+		messages, err = handleSystemPromptAsUserMessage(messages, threadExecutionParamsTemplate)
+		if err != nil {
+			logger.GetLogger().Errorf("Error handling system prompt for %s: %v", g.config.Identifier, err)
+			return -1, nil, err
+		}
+	}
+
+	openAIKey, err := user.GetOpenAIKey()
+	if err != nil {
+		logger.GetLogger().Errorf("Error decrypting openai key: %v", err)
+		return -1, nil, err
+	}
+
+	return BaseExecuteThread(ctx, db, user, messages, threadExecutionParamsTemplate, threadExecutionIdentifier, &ExecuteParamConfigs{
+		Model:                      g.config.Model,
+		ExecutorRoute:              g.config.ExecutorRoute,
+		DefaultTemperature:         g.config.DefaultTemperature,
+		DefaultMaxCompletionTokens: g.config.DefaultMaxTokens,
+		DefaultTimeout:             g.config.DefaultTimeout,
+	}, tools, map[string]interface{}{
+		OPENAI_OWNER: openAIKey,
+	})
+}
+
+// handleSystemPromptAsUserMessage sends the system prompt as the first user
+// message, for the models that don't support system prompts like o1
+func handleSystemPromptAsUserMessage(messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) ([]*models.Message, error) {
+	systemPrompt, err := getSystemPrompt(messages, threadExecutionParamsTemplate)
+	if err != nil {
+		logger.GetLogger().Errorf("Error getting system prompt: %v", err)
+		return nil, err
+	}
+
+	messages = filterNonSystemMessages(messages)
+
+	contentMap := map[string]interface{}{
+		"content": systemPrompt,
+	}
+	contentMapJson, err := json.Marshal(contentMap)
+	if err != nil {
+		logger.GetLogger().Errorf("Error marshalling content map: %v", err)
+		return nil, err
+	}
+	if systemPrompt != "" {
+		messages = append([]*models.Message{{
+			Role:       "user",
+			ContentMap: contentMapJson,
+		}}, messages...)
+	}
+
+	threadExecutionParamsTemplate.SystemPrompt = ""
+
+	return messages, nil
+}
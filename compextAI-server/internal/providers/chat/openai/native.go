@@ -0,0 +1,137 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+	"gorm.io/gorm"
+)
+
+const (
+	OPENAI_OWNER = "openai"
+
+	// the base url can point to any server implementing the chat completions api
+	OPENAI_BASE_URL_ENV           = "OPENAI_BASE_URL"
+	OPENAI_DEFAULT_BASE_URL       = "https://api.openai.com/v1"
+	OPENAI_CHAT_COMPLETIONS_ROUTE = "/chat/completions"
+)
+
+type nativeChatCompletionMessage struct {
+	Role       string      `json:"role"`
+	Content    interface{} `json:"content"`
+	ToolCallID string      `json:"tool_call_id,omitempty"`
+	ToolCalls  interface{} `json:"tool_calls,omitempty"`
+}
+
+type nativeChatCompletionRequest struct {
+	Model               string                        `json:"model"`
+	Messages            []nativeChatCompletionMessage `json:"messages"`
+	Temperature         float64                       `json:"temperature,omitempty"`
+	MaxCompletionTokens int                           `json:"max_completion_tokens,omitempty"`
+	ResponseFormat      interface{}                   `json:"response_format,omitempty"`
+	Tools               []*openaiTool                 `json:"tools,omitempty"`
+}
+
+// newNativeChatCompletionRequest converts the execution data of the executor
+// to a chat completions request, dropping the fields the api does not know.
+func newNativeChatCompletionRequest(executionData *openaiExecutionData, responseFormat json.RawMessage) (*nativeChatCompletionRequest, error) {
+	messages := make([]nativeChatCompletionMessage, 0, len(executionData.Messages))
+	for _, message := range executionData.Messages {
+		messages = append(messages, nativeChatCompletionMessage{
+			Role:       message.Role,
+			Content:    message.Content,
+			ToolCallID: message.ToolCallID,
+			ToolCalls:  message.ToolCalls,
+		})
+	}
+
+	responseFormatValue, err := unmarshalOptionalJSON(responseFormat)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling response format: %w", err)
+	}
+
+	return &nativeChatCompletionRequest{
+		Model:               executionData.Model,
+		Messages:            messages,
+		Temperature:         executionData.Temperature,
+		MaxCompletionTokens: executionData.MaxCompletionTokens,
+		ResponseFormat:      responseFormatValue,
+		Tools:               executionData.Tools,
+	}, nil
+}
+
+// NativeEndpoint is an api implementing the openai chat completions api,
+// the api key is optional.
+type NativeEndpoint struct {
+	BaseURL string
+	APIKey  string
+	// restricts the addresses the requests reach, e.g. for the endpoints set
+	// by the users, the default transport when nil
+	Transport http.RoundTripper
+}
+
+func newNativeClient(endpoint *NativeEndpoint, timeout time.Duration) *base.NativeClient {
+	headers := make(map[string]string)
+	if endpoint.APIKey != "" {
+		headers["Authorization"] = "Bearer " + endpoint.APIKey
+	}
+	return base.NewNativeClient(endpoint.BaseURL, headers, timeout, endpoint.Transport)
+}
+
+// executeNative runs the execution against the chat completions api of the
+// endpoint, or of openai when it is nil. The response has the same shape as
+// the one returned by the executor.
+func executeNative(ctx context.Context, db *gorm.DB, executionData *openaiExecutionData, responseFormat json.RawMessage, threadExecutionIdentifier string, endpoint *NativeEndpoint) (int, interface{}, error) {
+	request, err := newNativeChatCompletionRequest(executionData, responseFormat)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	if endpoint == nil {
+		apiKey, _ := executionData.APIKeys[OPENAI_OWNER].(string)
+		if apiKey == "" {
+			return -1, nil, fmt.Errorf("openai api key is not set")
+		}
+		endpoint = &NativeEndpoint{
+			BaseURL: base.GetNativeBaseURL(OPENAI_BASE_URL_ENV, OPENAI_DEFAULT_BASE_URL),
+			APIKey:  apiKey,
+		}
+	}
+
+	// update thread execution metadata
+	if err := base.UpdateThreadExecutionMetadata(db, threadExecutionIdentifier, request, request.Messages); err != nil {
+		logger.GetLogger().Errorf("Error updating thread execution metadata: %v", err)
+		return -1, nil, err
+	}
+
+	client := newNativeClient(endpoint, time.Duration(executionData.Timeout)*time.Second)
+	statusCode, response, err := client.Post(ctx, OPENAI_CHAT_COMPLETIONS_ROUTE, request)
+	if err != nil || statusCode != http.StatusOK {
+		return statusCode, response, err
+	}
+
+	// the native client does not stream, the content is sent as a single delta
+	if onDelta := base.DeltaHandlerFromContext(ctx); onDelta != nil {
+		if content := getChatCompletionContent(response); content != "" {
+			onDelta(content)
+		}
+	}
+	return statusCode, response, nil
+}
+
+func getChatCompletionContent(response interface{}) string {
+	responseMap, _ := response.(map[string]interface{})
+	choices, _ := responseMap["choices"].([]interface{})
+	if len(choices) == 0 {
+		return ""
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	message, _ := choice["message"].(map[string]interface{})
+	content, _ := message["content"].(string)
+	return content
+}
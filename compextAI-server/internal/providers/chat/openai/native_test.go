@@ -0,0 +1,111 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/burnerlee/compextAI/models"
+)
+
+func TestNativeChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != OPENAI_CHAT_COMPLETIONS_ROUTE {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("unexpected authorization header: %s", got)
+		}
+
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("error decoding request: %v", err)
+		}
+		if request["model"] != "gpt-4o" {
+			t.Errorf("unexpected model: %v", request["model"])
+		}
+		if _, ok := request["response_format"]; ok {
+			t.Errorf("empty response format should not be sent")
+		}
+		messages := request["messages"].([]interface{})
+		if len(messages) != 2 {
+			t.Fatalf("expected 2 messages, got %d", len(messages))
+		}
+		if _, ok := messages[0].(map[string]interface{})["metadata"]; ok {
+			t.Errorf("message metadata should not be sent")
+		}
+		if len(request["tools"].([]interface{})) != 1 {
+			t.Errorf("expected the tool to be sent")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "chatcmpl-1",
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}`))
+	}))
+	defer server.Close()
+
+	request, err := newNativeChatCompletionRequest(&openaiExecutionData{
+		Model: "gpt-4o",
+		Messages: []OpenaiMessage{
+			{Role: "system", Content: "be brief", Metadata: map[string]interface{}{"source": "test"}},
+			{Role: "user", Content: "weather in paris?"},
+		},
+		Temperature: 0.5,
+		Tools: []*openaiTool{{
+			Type: "function",
+			Function: openaiFunction{
+				Name:       "get_weather",
+				Parameters: json.RawMessage(`{"type":"object"}`),
+			},
+		}},
+	}, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("error creating request: %v", err)
+	}
+
+	statusCode, response, err := newNativeClient(&NativeEndpoint{BaseURL: server.URL, APIKey: "test-key"}, 5*time.Second).Post(context.Background(), OPENAI_CHAT_COMPLETIONS_ROUTE, request)
+	if err != nil {
+		t.Fatalf("error executing request: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", statusCode)
+	}
+
+	message, err := convertExecutionResponseToMessage(response)
+	if err != nil {
+		t.Fatalf("error converting response: %v", err)
+	}
+	toolCalls, err := models.ParseToolCalls(message.ToolCalls)
+	if err != nil {
+		t.Fatalf("error parsing tool calls: %v", err)
+	}
+	if len(toolCalls) != 1 || toolCalls[0].Function.Name != "get_weather" || toolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", toolCalls)
+	}
+}
+
+func TestNativeChatCompletionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"message": "rate limited", "type": "rate_limit_error"}}`))
+	}))
+	defer server.Close()
+
+	statusCode, response, err := newNativeClient(&NativeEndpoint{BaseURL: server.URL, APIKey: "test-key"}, 5*time.Second).Post(context.Background(), OPENAI_CHAT_COMPLETIONS_ROUTE, &nativeChatCompletionRequest{Model: "gpt-4o"})
+	if err != nil {
+		t.Fatalf("vendor errors should be returned as responses: %v", err)
+	}
+	if statusCode != http.StatusTooManyRequests {
+		t.Errorf("unexpected status code: %d", statusCode)
+	}
+	if _, ok := response.(map[string]interface{})["error"]; !ok {
+		t.Errorf("expected the error body to be returned: %v", response)
+	}
+}
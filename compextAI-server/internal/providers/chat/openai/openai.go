@@ -1,9 +1,9 @@
 package openai
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"slices"
 	"time"
 
 	"github.com/burnerlee/compextAI/internal/logger"
@@ -12,28 +12,21 @@ import (
 	"gorm.io/gorm"
 )
 
-var (
-	openaiAllowedRoles = []string{"user", "assistant", "system", "tool"}
-)
-
-func validateMessage(message *models.Message) error {
+func validateMessage(message *models.Message, config *base.ModelConfig) error {
 	if message.ContentMap == nil {
 		return fmt.Errorf("message content map is nil")
 	}
 
-	if !slices.Contains(openaiAllowedRoles, message.Role) {
-		return fmt.Errorf("message role is invalid, only %v are allowed", openaiAllowedRoles)
-	}
-	return nil
+	return config.ValidateMessageRole(message.Role)
 }
 
 type OpenaiMessage struct {
 	Role         string                 `json:"role"`
 	Content      interface{}            `json:"content"`
-	ToolCallID   string                 `json:"tool_call_id"`
+	ToolCallID   string                 `json:"tool_call_id,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata"`
-	ToolCalls    interface{}            `json:"tool_calls"`
-	FunctionCall interface{}            `json:"function_call"`
+	ToolCalls    interface{}            `json:"tool_calls,omitempty"`
+	FunctionCall interface{}            `json:"function_call,omitempty"`
 }
 
 func convertMessageToProviderFormat(message *models.Message) (interface{}, error) {
@@ -53,13 +46,13 @@ func convertMessageToProviderFormat(message *models.Message) (interface{}, error
 		return nil, fmt.Errorf("content map does not contain 'content' key")
 	}
 
-	var toolCalls interface{}
-	if err := json.Unmarshal(message.ToolCalls, &toolCalls); err != nil {
+	toolCalls, err := unmarshalOptionalJSON(message.ToolCalls)
+	if err != nil {
 		return nil, err
 	}
 
-	var functionCall interface{}
-	if err := json.Unmarshal(message.FunctionCall, &functionCall); err != nil {
+	functionCall, err := unmarshalOptionalJSON(message.FunctionCall)
+	if err != nil {
 		return nil, err
 	}
 
@@ -73,6 +66,22 @@ func convertMessageToProviderFormat(message *models.Message) (interface{}, error
 	}, nil
 }
 
+// unmarshalOptionalJSON decodes an optional json column, the column defaults
+// to {} which must not be sent as a value
+func unmarshalOptionalJSON(data json.RawMessage) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	if valueMap, ok := value.(map[string]interface{}); ok && len(valueMap) == 0 {
+		return nil, nil
+	}
+	return value, nil
+}
+
 func convertExecutionResponseToMessage(response interface{}) (*models.Message, error) {
 	responseMap, ok := response.(map[string]interface{})
 	if !ok {
@@ -90,13 +99,27 @@ func convertExecutionResponseToMessage(response interface{}) (*models.Message, e
 	if !ok {
 		return nil, fmt.Errorf("message role is not a string")
 	}
-	content, ok := message["content"].(string)
-	if !ok {
-		return nil, fmt.Errorf("message content is not a string")
+	// the content is null when the model only calls tools
+	content := ""
+	if message["content"] != nil {
+		content, ok = message["content"].(string)
+		if !ok {
+			return nil, fmt.Errorf("message content is not a string")
+		}
+	}
+
+	var toolCallsJson json.RawMessage
+	if toolCalls, ok := message["tool_calls"].([]interface{}); ok && len(toolCalls) > 0 {
+		var err error
+		toolCallsJson, err = json.Marshal(toolCalls)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	openAIChatCompletionID := responseMap["id"].(string)
-	usage := responseMap["usage"].(map[string]interface{})
+	// some openai compatible servers leave out the id and the usage
+	openAIChatCompletionID, _ := responseMap["id"].(string)
+	usage, _ := responseMap["usage"].(map[string]interface{})
 
 	metadata := map[string]interface{}{
 		"openai_chat_completion_id": openAIChatCompletionID,
@@ -120,6 +143,7 @@ func convertExecutionResponseToMessage(response interface{}) (*models.Message, e
 		Role:       role,
 		ContentMap: contentMapJson,
 		Metadata:   metadataJson,
+		ToolCalls:  toolCallsJson,
 	}, nil
 }
 
@@ -155,6 +179,8 @@ type ExecuteParamConfigs struct {
 	DefaultTemperature         float64
 	DefaultMaxCompletionTokens int
 	DefaultTimeout             int
+	// the execution is sent to this endpoint instead of the executor when set
+	Endpoint *NativeEndpoint
 }
 
 func getSystemPrompt(messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate) (string, error) {
@@ -197,7 +223,7 @@ func filterNonSystemMessages(messages []*models.Message) []*models.Message {
 	return nonSystemMessages
 }
 
-func BaseExecuteThread(db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, configs *ExecuteParamConfigs, tools []*models.ExecutionTool, apiKeys map[string]interface{}) (int, interface{}, error) {
+func BaseExecuteThread(ctx context.Context, db *gorm.DB, user *models.User, messages []*models.Message, threadExecutionParamsTemplate *models.ThreadExecutionParamsTemplate, threadExecutionIdentifier string, configs *ExecuteParamConfigs, tools []*models.ExecutionTool, apiKeys map[string]interface{}) (int, interface{}, error) {
 	systemPrompt := ""
 
 	modelMessages := make([]OpenaiMessage, 0)
@@ -278,9 +304,13 @@ func BaseExecuteThread(db *gorm.DB, user *models.User, messages []*models.Messag
 		return -1, nil, err
 	}
 
+	if configs.Endpoint != nil || threadExecutionParamsTemplate.Client == models.TemplateClient_NATIVE {
+		return executeNative(ctx, db, &executionData, threadExecutionParamsTemplate.ResponseFormat, threadExecutionIdentifier, configs.Endpoint)
+	}
+
 	executionParams := &base.ExecuteParams{
 		Timeout: time.Duration(executionData.Timeout) * time.Second,
 	}
 
-	return base.Execute(db, configs.ExecutorRoute, executionParams, executionData, threadExecutionIdentifier, modelMessages)
+	return base.Execute(ctx, db, configs.ExecutorRoute, executionParams, executionData, threadExecutionIdentifier, modelMessages)
 }
@@ -0,0 +1,42 @@
+package openai
+
+import "github.com/burnerlee/compextAI/internal/providers/chat/base"
+
+type openaiUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+// ParseUsage reads the usage of a chat completion, the usage is empty when the
+// server leaves it out
+func ParseUsage(response interface{}) (*base.Usage, error) {
+	var chatCompletion struct {
+		Usage *openaiUsage `json:"usage"`
+	}
+	if err := base.DecodeResponse(response, &chatCompletion); err != nil {
+		return nil, err
+	}
+	if chatCompletion.Usage == nil {
+		return &base.Usage{}, nil
+	}
+
+	usage := chatCompletion.Usage
+	totalTokens := usage.TotalTokens
+	if totalTokens == 0 {
+		totalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return &base.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		CachedTokens:     usage.PromptTokensDetails.CachedTokens,
+		ReasoningTokens:  usage.CompletionTokensDetails.ReasoningTokens,
+		TotalTokens:      totalTokens,
+	}, nil
+}
@@ -0,0 +1,35 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/burnerlee/compextAI/internal/providers/chat/base"
+)
+
+func TestParseUsage(t *testing.T) {
+	var response interface{}
+	json.Unmarshal([]byte(`{
+		"id": "chatcmpl-1",
+		"usage": {
+			"prompt_tokens": 100, "completion_tokens": 50, "total_tokens": 150,
+			"prompt_tokens_details": {"cached_tokens": 40},
+			"completion_tokens_details": {"reasoning_tokens": 30}
+		}
+	}`), &response)
+
+	usage, err := ParseUsage(response)
+	if err != nil {
+		t.Fatalf("error parsing usage: %v", err)
+	}
+	expected := base.Usage{PromptTokens: 100, CompletionTokens: 50, CachedTokens: 40, ReasoningTokens: 30, TotalTokens: 150}
+	if *usage != expected {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+
+	// some openai compatible servers leave out the usage
+	usage, err = ParseUsage(map[string]interface{}{"id": "chatcmpl-2"})
+	if err != nil || *usage != (base.Usage{}) {
+		t.Errorf("expected an empty usage: %+v: %v", usage, err)
+	}
+}
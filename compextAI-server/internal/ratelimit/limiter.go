@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/models"
+	"gorm.io/gorm"
+)
+
+const (
+	RATE_LIMIT_REDIS_URL_ENV = "RATE_LIMIT_REDIS_URL"
+	// the limits set by the admins are reloaded from the database at this
+	// interval, so that every instance of the server picks them up
+	RATE_LIMITS_RELOAD_INTERVAL = 30 * time.Second
+)
+
+var rateLimitScopes = []string{models.RateLimitScope_USER, models.RateLimitScope_PROJECT, models.RateLimitScope_MODEL}
+
+// Limiter applies the rate limits of the users, the projects and the models.
+// The limit of a subject is the one set by the admins for the subject, else
+// the one set for its whole scope, else the default of the scope.
+type Limiter struct {
+	db       *gorm.DB
+	store    Store
+	defaults map[string]Limit
+
+	mu         sync.RWMutex
+	overrides  map[string]Limit
+	reloadedAt time.Time
+}
+
+func NewLimiter(db *gorm.DB, store Store, defaults map[string]Limit) *Limiter {
+	return &Limiter{
+		db:       db,
+		store:    store,
+		defaults: defaults,
+	}
+}
+
+// NewLimiterFromEnv returns a limiter with the redis store when
+// RATE_LIMIT_REDIS_URL is set, else the memory store. The defaults are read
+// from RATE_LIMIT_<SCOPE>_REQUESTS_PER_MINUTE and RATE_LIMIT_<SCOPE>_BURST,
+// the scopes without them are not limited by default.
+func NewLimiterFromEnv(db *gorm.DB) (*Limiter, error) {
+	var store Store = NewMemoryStore()
+	if redisURL := os.Getenv(RATE_LIMIT_REDIS_URL_ENV); redisURL != "" {
+		redisStore, err := NewRedisStore(redisURL)
+		if err != nil {
+			return nil, err
+		}
+		store = redisStore
+	}
+
+	defaults := make(map[string]Limit)
+	for _, scope := range rateLimitScopes {
+		limit := Limit{}
+		for name, value := range map[string]*int{
+			"REQUESTS_PER_MINUTE": &limit.RequestsPerMinute,
+			"BURST":               &limit.Burst,
+		} {
+			envName := fmt.Sprintf("RATE_LIMIT_%s_%s", strings.ToUpper(scope), name)
+			envValue := os.Getenv(envName)
+			if envValue == "" {
+				continue
+			}
+			parsedValue, err := strconv.Atoi(envValue)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s: %w", envName, err)
+			}
+			*value = parsedValue
+		}
+		defaults[scope] = limit
+	}
+
+	return NewLimiter(db, store, defaults), nil
+}
+
+func limitKey(scope, subject string) string {
+	return fmt.Sprintf("%s:%s", scope, subject)
+}
+
+// Reload loads the limits set by the admins from the database
+func (l *Limiter) Reload() error {
+	rateLimits, err := models.GetRateLimits(l.db)
+	if err != nil {
+		return fmt.Errorf("error getting rate limits: %w", err)
+	}
+
+	overrides := make(map[string]Limit, len(rateLimits))
+	for _, rateLimit := range rateLimits {
+		overrides[limitKey(rateLimit.Scope, rateLimit.Subject)] = Limit{
+			RequestsPerMinute: rateLimit.RequestsPerMinute,
+			Burst:             rateLimit.Burst,
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides = overrides
+	l.reloadedAt = time.Now()
+	return nil
+}
+
+// GetLimit returns the limit that applies to the subject of the scope
+func (l *Limiter) GetLimit(scope, subject string) Limit {
+	l.mu.RLock()
+	stale := time.Since(l.reloadedAt) >= RATE_LIMITS_RELOAD_INTERVAL
+	l.mu.RUnlock()
+	if stale {
+		if err := l.Reload(); err != nil {
+			// the previous limits stay in place
+			logger.GetLogger().Errorf("Error reloading rate limits: %v", err)
+			l.mu.Lock()
+			l.reloadedAt = time.Now()
+			l.mu.Unlock()
+		}
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if limit, ok := l.overrides[limitKey(scope, subject)]; ok {
+		return limit
+	}
+	if limit, ok := l.overrides[limitKey(scope, "")]; ok {
+		return limit
+	}
+	return l.defaults[scope]
+}
+
+// Allow takes a token from the bucket of the subject of the scope. Errors of
+// the store let the request through, the limits must not take the api down.
+func (l *Limiter) Allow(ctx context.Context, scope, subject string) *Result {
+	limit := l.GetLimit(scope, subject)
+	if limit.Unlimited() {
+		return &Result{Allowed: true}
+	}
+
+	result, err := l.store.Take(ctx, limitKey(scope, subject), limit)
+	if err != nil {
+		logger.GetLogger().Errorf("Error taking rate limit token: %s: %s: %v", scope, subject, err)
+		return &Result{Allowed: true}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// the interval between the sweeps of the refilled buckets
+const MEMORY_STORE_SWEEP_INTERVAL = time.Minute
+
+type memoryBucket struct {
+	*bucket
+	limit Limit
+}
+
+// MemoryStore keeps the buckets in the memory of the server, the limits are
+// not shared between the instances of the server
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	sweptAt   time.Time
+	timeNowFn func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*memoryBucket),
+		timeNowFn: time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timeNowFn()
+	if now.Sub(s.sweptAt) >= MEMORY_STORE_SWEEP_INTERVAL {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: newBucket(limit, now)}
+		s.buckets[key] = b
+	}
+	// the limit may have been changed since the bucket was created
+	b.limit = limit
+	return b.take(limit, now), nil
+}
+
+// sweep drops the refilled buckets so that idle keys do not pile up
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.full(b.limit, now) {
+			delete(s.buckets, key)
+		}
+	}
+	s.sweptAt = now
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.timeNowFn = func() time.Time { return now }
+
+	// 60 requests per minute refill a token every second
+	limit := Limit{RequestsPerMinute: 60, Burst: 3}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, _ := store.Take(ctx, "user:1", limit)
+		if !result.Allowed {
+			t.Fatalf("expected request %d of the burst to be allowed", i)
+		}
+	}
+
+	result, _ := store.Take(ctx, "user:1", limit)
+	if result.Allowed {
+		t.Fatalf("expected the request after the burst to be refused")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("expected to retry after 1s, got %s", result.RetryAfter)
+	}
+
+	// the buckets of the other keys are not affected
+	if result, _ := store.Take(ctx, "user:2", limit); !result.Allowed {
+		t.Errorf("expected the request of another key to be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if result, _ := store.Take(ctx, "user:1", limit); result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected to retry after 500ms, got %+v", result)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if result, _ := store.Take(ctx, "user:1", limit); !result.Allowed {
+		t.Errorf("expected the request to be allowed once a token is refilled")
+	}
+
+	// the refilled buckets are swept
+	now = now.Add(MEMORY_STORE_SWEEP_INTERVAL)
+	store.Take(ctx, "user:3", limit)
+	if _, ok := store.buckets["user:1"]; ok {
+		t.Errorf("expected the refilled bucket to be swept")
+	}
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket that refills RequestsPerMinute tokens per minute and
+// holds at most Burst tokens
+type Limit struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	Burst             int `json:"burst"`
+}
+
+// Unlimited reports whether the limit lets every request through
+func (l Limit) Unlimited() bool {
+	return l.RequestsPerMinute <= 0
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst <= 0 {
+		return float64(l.RequestsPerMinute)
+	}
+	return float64(l.Burst)
+}
+
+// tokens per second
+func (l Limit) rate() float64 {
+	return float64(l.RequestsPerMinute) / 60
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	// the time until a token is available when the request is not allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the token buckets, it is shared by all the limits of the limiter
+type Store interface {
+	// Take takes a token from the bucket of the key
+	Take(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// bucket is the state of a token bucket at updatedAt
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{
+		tokens:    limit.capacity(),
+		updatedAt: now,
+	}
+}
+
+func (b *bucket) take(limit Limit, now time.Time) *Result {
+	if elapsed := now.Sub(b.updatedAt).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(limit.capacity(), b.tokens+elapsed*limit.rate())
+	}
+	b.updatedAt = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return &Result{Allowed: true}
+	}
+	return &Result{
+		Allowed:    false,
+		RetryAfter: time.Duration(math.Ceil((1 - b.tokens) / limit.rate() * float64(time.Second))),
+	}
+}
+
+// full reports whether the bucket is refilled, a full bucket is the same as a
+// missing one
+func (b *bucket) full(limit Limit, now time.Time) bool {
+	return b.tokens+now.Sub(b.updatedAt).Seconds()*limit.rate() >= limit.capacity()
+}
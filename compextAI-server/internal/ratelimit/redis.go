@@ -0,0 +1,265 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	REDIS_DIAL_TIMEOUT          = 5 * time.Second
+	REDIS_COMMAND_TIMEOUT       = 2 * time.Second
+	REDIS_MAX_IDLE_CONNS        = 16
+	REDIS_RATE_LIMIT_KEY_PREFIX = "compext:ratelimit:"
+)
+
+// takeTokenScript takes a token from the bucket stored in a hash, with the
+// same arithmetic as bucket.take. The bucket expires once it is refilled.
+// It returns whether the request is allowed and the milliseconds to wait.
+const takeTokenScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated_at')
+local tokens = tonumber(state[1])
+local updated_at = tonumber(state[2])
+if tokens == nil or updated_at == nil then
+	tokens = capacity
+	updated_at = now
+end
+if now > updated_at then
+	tokens = math.min(capacity, tokens + (now - updated_at) * rate)
+end
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) / rate)
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'updated_at', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate) + 1000)
+return {allowed, retry_after}
+`
+
+// RedisStore keeps the buckets in redis, so that the limits are shared
+// between the instances of the server. It speaks the redis protocol directly,
+// every command gets a connection of a small pool.
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+	conns    chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisStore returns a store for a redis url like
+// redis://:password@localhost:6379/0
+func NewRedisStore(redisURL string) (*RedisStore, error) {
+	parsedURL, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing redis url: %w", err)
+	}
+	if parsedURL.Scheme != "redis" {
+		return nil, fmt.Errorf("redis url should use the redis scheme")
+	}
+
+	store := &RedisStore{
+		addr:  parsedURL.Host,
+		conns: make(chan *redisConn, REDIS_MAX_IDLE_CONNS),
+	}
+	if parsedURL.Port() == "" {
+		store.addr = net.JoinHostPort(parsedURL.Hostname(), "6379")
+	}
+	if password, ok := parsedURL.User.Password(); ok {
+		store.password = password
+	}
+	if db := strings.TrimPrefix(parsedURL.Path, "/"); db != "" {
+		store.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing redis database: %w", err)
+		}
+	}
+	return store, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (*Result, error) {
+	// the rate is passed per millisecond, the time of the bucket is in milliseconds
+	reply, err := s.do(ctx, "EVAL", takeTokenScript, "1", REDIS_RATE_LIMIT_KEY_PREFIX+key,
+		strconv.FormatFloat(limit.capacity(), 'f', -1, 64),
+		strconv.FormatFloat(limit.rate()/1000, 'f', -1, 64),
+		strconv.FormatInt(time.Now().UnixMilli(), 10))
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("unexpected redis reply: %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	retryAfter, _ := values[1].(int64)
+	return &Result{
+		Allowed:    allowed == 1,
+		RetryAfter: time.Duration(retryAfter) * time.Millisecond,
+	}, nil
+}
+
+func (s *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	for {
+		conn, pooled, err := s.getConn(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		reply, err := conn.do(ctx, args...)
+		var redisErr redisError
+		if err != nil && !errors.As(err, &redisErr) {
+			// the state of the connection is unknown after a network error
+			conn.Close()
+			// an idle connection may have been closed by redis, the command
+			// is retried on a new one
+			if pooled && ctx.Err() == nil {
+				continue
+			}
+			return nil, err
+		}
+
+		select {
+		case s.conns <- conn:
+		default:
+			conn.Close()
+		}
+		return reply, err
+	}
+}
+
+// getConn returns an idle connection of the pool, or a new one when the pool
+// is empty
+func (s *RedisStore) getConn(ctx context.Context) (*redisConn, bool, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, true, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: REDIS_DIAL_TIMEOUT}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, false, fmt.Errorf("error connecting to redis: %w", err)
+	}
+	conn := &redisConn{
+		Conn:   netConn,
+		reader: bufio.NewReader(netConn),
+	}
+
+	if s.password != "" {
+		if _, err := conn.do(ctx, "AUTH", s.password); err != nil {
+			conn.Close()
+			return nil, false, fmt.Errorf("error authenticating to redis: %w", err)
+		}
+	}
+	if s.db != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(s.db)); err != nil {
+			conn.Close()
+			return nil, false, fmt.Errorf("error selecting redis database: %w", err)
+		}
+	}
+	return conn, false, nil
+}
+
+// redisError is an error reply of redis, the connection stays usable
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func (c *redisConn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(REDIS_COMMAND_TIMEOUT)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write([]byte(command.String())); err != nil {
+		return nil, fmt.Errorf("error writing redis command: %w", err)
+	}
+	return readRedisReply(c.reader)
+}
+
+// readRedisReply reads a reply of the redis protocol, the integers are
+// returned as int64, the bulk strings as string and the arrays as
+// []interface{}
+func readRedisReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("error reading redis reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing redis bulk string length: %w", err)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("error reading redis bulk string: %w", err)
+		}
+		return string(data[:length]), nil
+	case '*':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing redis array length: %w", err)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, length)
+		for i := range values {
+			values[i], err = readRedisReply(reader)
+			if err != nil {
+				var redisErr redisError
+				if !errors.As(err, &redisErr) {
+					return nil, err
+				}
+				values[i] = redisErr
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unexpected redis reply: %q", line)
+}
@@ -0,0 +1,182 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const DIAL_TIMEOUT = 30 * time.Second
+
+var ErrNonPublicAddress = errors.New("the address is not a public address")
+
+// the shared address space of the carrier-grade nats, used by some cloud
+// metadata endpoints
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP reports whether the ip is routable on the internet, i.e. not a
+// loopback, private, link-local, shared, multicast or unspecified address
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && (ip4[0] == 0 || sharedAddressSpace.Contains(ip4)) {
+		return false
+	}
+	return true
+}
+
+// Allowlist holds the private hosts and networks an admin lets the users
+// reach, e.g. the self hosted model servers
+type Allowlist struct {
+	hosts    map[string]bool
+	networks []*net.IPNet
+}
+
+// ParseAllowlist parses a comma separated list of host names, ips and cidrs,
+// the empty list allows nothing
+func ParseAllowlist(value string) (*Allowlist, error) {
+	allowlist := &Allowlist{
+		hosts: make(map[string]bool),
+	}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cidr: %s", entry)
+			}
+			allowlist.networks = append(allowlist.networks, network)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			allowlist.networks = append(allowlist.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		allowlist.hosts[entry] = true
+	}
+	return allowlist, nil
+}
+
+// AllowsHost reports whether the host name or ip is allowed, a nil allowlist
+// allows nothing
+func (a *Allowlist) AllowsHost(host string) bool {
+	if a == nil {
+		return false
+	}
+	host = strings.ToLower(host)
+	if a.hosts[host] {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return a.allowsIP(ip)
+	}
+	return false
+}
+
+func (a *Allowlist) allowsIP(ip net.IP) bool {
+	if a == nil {
+		return false
+	}
+	for _, network := range a.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// controlAddress rejects the connections to the addresses that are neither
+// public nor allowed. It runs once the host is resolved, so that a public
+// name cannot resolve to a private address.
+func controlAddress(allowlist *Allowlist) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || (!IsPublicIP(ip) && !allowlist.allowsIP(ip)) {
+			return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+		}
+		return nil
+	}
+}
+
+// NewTransport returns a transport that only connects to public addresses
+// and to the hosts and networks of the allowlist, which may be nil
+func NewTransport(allowlist *Allowlist) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: DIAL_TIMEOUT,
+		Control: controlAddress(allowlist),
+	}
+	// the host names of the allowlist may resolve to any address
+	allowedDialer := &net.Dialer{
+		Timeout: DIAL_TIMEOUT,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(address); err == nil && allowlist != nil && allowlist.hosts[strings.ToLower(host)] {
+			return allowedDialer.DialContext(ctx, network, address)
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+	// a proxy would connect to the address on behalf of the client
+	transport.Proxy = nil
+	return transport
+}
+
+// NewClient returns a client for the urls set by the users, e.g. the webhooks
+// and the tools. It only connects to public addresses and does not follow
+// the redirects, which could point to a private address.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: NewTransport(nil),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// ValidateURL rejects the urls whose host is obviously not public, the names
+// are only resolved when connecting
+func ValidateURL(rawURL string) error {
+	return ValidateAllowedURL(rawURL, nil)
+}
+
+// ValidateAllowedURL is ValidateURL for the urls that may also target the
+// hosts and networks of the allowlist
+func ValidateAllowedURL(rawURL string, allowlist *Allowlist) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %s", rawURL)
+	}
+	host := strings.ToLower(parsedURL.Hostname())
+	if allowlist.AllowsHost(host) {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package safehttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for address, public := range map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.100.100.200":  false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00:ec2::254":    false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := IsPublicIP(net.ParseIP(address)); got != public {
+			t.Errorf("IsPublicIP(%s) = %v, expected %v", address, got, public)
+		}
+	}
+}
+
+func TestClientRejectsLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request should not reach the server")
+	}))
+	defer server.Close()
+
+	_, err := NewClient(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("expected a non public address error: %v", err)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for rawURL, valid := range map[string]bool{
+		"https://example.com/hook":      true,
+		"http://localhost:8080/hook":    false,
+		"http://169.254.169.254/latest": false,
+		"http://[::1]/hook":             false,
+		"http://10.0.0.1/hook":          false,
+	} {
+		if err := ValidateURL(rawURL); (err == nil) != valid {
+			t.Errorf("ValidateURL(%s) = %v, expected valid: %v", rawURL, err, valid)
+		}
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	allowlist, err := ParseAllowlist("ollama.internal, 10.0.0.0/8, 192.168.1.20")
+	if err != nil {
+		t.Fatalf("error parsing allowlist: %v", err)
+	}
+	for rawURL, valid := range map[string]bool{
+		"http://ollama.internal:11434/v1": true,
+		"http://10.1.2.3:8000/v1":         true,
+		"http://192.168.1.20/v1":          true,
+		"http://192.168.1.21/v1":          false,
+		"http://localhost:11434/v1":       false,
+		"https://example.com/v1":          true,
+	} {
+		if err := ValidateAllowedURL(rawURL, allowlist); (err == nil) != valid {
+			t.Errorf("ValidateAllowedURL(%s) = %v, expected valid: %v", rawURL, err, valid)
+		}
+	}
+
+	if _, err := ParseAllowlist("10.0.0.0/33"); err == nil {
+		t.Error("expected an invalid cidr error")
+	}
+}
+
+func TestTransportAllowsAllowlistedAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	allowlist, err := ParseAllowlist("127.0.0.1")
+	if err != nil {
+		t.Fatalf("error parsing allowlist: %v", err)
+	}
+	client := &http.Client{Timeout: time.Second, Transport: NewTransport(allowlist)}
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the allowed address to be reached: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+}
@@ -0,0 +1,308 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	CREDENTIALS_ENCRYPTION_KEYS_ENV = "CREDENTIALS_ENCRYPTION_KEYS"
+	// the values encrypted by the keyring start with the prefix, the other
+	// values are stored in plaintext
+	ENCRYPTED_VALUE_PREFIX = "enc:v1:"
+	// the size of the keys of the environment and of the data keys, AES-256
+	KEY_SIZE = 32
+)
+
+var ErrKeyringNotLoaded = errors.New("the credentials encryption keys are not loaded")
+
+// Keyring encrypts the values with envelope encryption. Every value is
+// encrypted with its own random data key, the data key is encrypted with the
+// active key of the keyring and stored next to the value. Rotating the keys
+// only re-encrypts the data keys.
+//
+// An encrypted value looks like
+// enc:v1:<key id>:<base64 encrypted data key>:<base64 encrypted value>
+type Keyring struct {
+	// the key encrypting the data keys of the new values
+	activeKeyID string
+	keys        map[string]cipher.AEAD
+}
+
+// NewKeyring parses the keys of the environment, a comma separated list of
+// <key id>:<base64 key> of 32 bytes keys. The first key is the active one,
+// the others only decrypt the values encrypted before a rotation.
+func NewKeyring(keys string) (*Keyring, error) {
+	keyring := &Keyring{
+		keys: make(map[string]cipher.AEAD),
+	}
+	for _, entry := range strings.Split(keys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		keyID, encodedKey, ok := strings.Cut(entry, ":")
+		if !ok || keyID == "" {
+			return nil, fmt.Errorf("encryption keys should look like <key id>:<base64 key>")
+		}
+		if _, ok := keyring.keys[keyID]; ok {
+			return nil, fmt.Errorf("duplicate encryption key id: %s", keyID)
+		}
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding encryption key %s: %w", keyID, err)
+		}
+		if len(key) != KEY_SIZE {
+			return nil, fmt.Errorf("encryption key %s should be %d bytes long", keyID, KEY_SIZE)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		keyring.keys[keyID] = aead
+		if keyring.activeKeyID == "" {
+			keyring.activeKeyID = keyID
+		}
+	}
+	if keyring.activeKeyID == "" {
+		return nil, fmt.Errorf("no encryption key")
+	}
+	return keyring, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext with a random nonce, the nonce is prepended to
+// the ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], additionalData)
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, ENCRYPTED_VALUE_PREFIX)
+}
+
+type encryptedValue struct {
+	keyID            string
+	encryptedDataKey []byte
+	ciphertext       []byte
+}
+
+func parseEncryptedValue(value string) (*encryptedValue, error) {
+	parts := strings.Split(strings.TrimPrefix(value, ENCRYPTED_VALUE_PREFIX), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed encrypted value")
+	}
+	encryptedDataKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding data key: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding ciphertext: %w", err)
+	}
+	return &encryptedValue{
+		keyID:            parts[0],
+		encryptedDataKey: encryptedDataKey,
+		ciphertext:       ciphertext,
+	}, nil
+}
+
+func (v *encryptedValue) String() string {
+	return fmt.Sprintf("%s%s:%s:%s", ENCRYPTED_VALUE_PREFIX, v.keyID,
+		base64.StdEncoding.EncodeToString(v.encryptedDataKey),
+		base64.StdEncoding.EncodeToString(v.ciphertext))
+}
+
+// Encrypt encrypts the value with a new data key, the empty value stays empty
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, KEY_SIZE)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataAEAD, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	// the key id is authenticated with the data key, so that it cannot be
+	// swapped for another key of the keyring
+	encryptedDataKey, err := seal(k.keys[k.activeKeyID], dataKey, []byte(k.activeKeyID))
+	if err != nil {
+		return "", err
+	}
+
+	return (&encryptedValue{
+		keyID:            k.activeKeyID,
+		encryptedDataKey: encryptedDataKey,
+		ciphertext:       ciphertext,
+	}).String(), nil
+}
+
+func (k *Keyring) decryptDataKey(value *encryptedValue) ([]byte, error) {
+	keyAEAD, ok := k.keys[value.keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key: %s", value.keyID)
+	}
+	dataKey, err := open(keyAEAD, value.encryptedDataKey, []byte(value.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// Decrypt decrypts the value, the values that are not encrypted are returned
+// as they are
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parsedValue, err := parseEncryptedValue(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := k.decryptDataKey(parsedValue)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAEAD, parsedValue.ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencryption reports whether the value is stored in plaintext or its
+// data key is encrypted with a key other than the active one
+func (k *Keyring) NeedsReencryption(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	parsedValue, err := parseEncryptedValue(value)
+	if err != nil {
+		return false
+	}
+	return parsedValue.keyID != k.activeKeyID
+}
+
+// Reencrypt encrypts the plaintext values, and encrypts the data key of the
+// encrypted values with the active key, the value itself is left untouched
+func (k *Keyring) Reencrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return k.Encrypt(value)
+	}
+
+	parsedValue, err := parseEncryptedValue(value)
+	if err != nil {
+		return "", err
+	}
+	if parsedValue.keyID == k.activeKeyID {
+		return value, nil
+	}
+	dataKey, err := k.decryptDataKey(parsedValue)
+	if err != nil {
+		return "", err
+	}
+	parsedValue.encryptedDataKey, err = seal(k.keys[k.activeKeyID], dataKey, []byte(k.activeKeyID))
+	if err != nil {
+		return "", err
+	}
+	parsedValue.keyID = k.activeKeyID
+	return parsedValue.String(), nil
+}
+
+var (
+	defaultKeyringMu sync.RWMutex
+	defaultKeyring   *Keyring
+)
+
+// LoadKeyringFromEnv loads the keys of CREDENTIALS_ENCRYPTION_KEYS, used by
+// Encrypt and Decrypt
+func LoadKeyringFromEnv() error {
+	keys := os.Getenv(CREDENTIALS_ENCRYPTION_KEYS_ENV)
+	if keys == "" {
+		return fmt.Errorf("%s is required to encrypt the stored credentials", CREDENTIALS_ENCRYPTION_KEYS_ENV)
+	}
+	keyring, err := NewKeyring(keys)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %w", CREDENTIALS_ENCRYPTION_KEYS_ENV, err)
+	}
+	SetKeyring(keyring)
+	return nil
+}
+
+func SetKeyring(keyring *Keyring) {
+	defaultKeyringMu.Lock()
+	defer defaultKeyringMu.Unlock()
+	defaultKeyring = keyring
+}
+
+func GetKeyring() (*Keyring, error) {
+	defaultKeyringMu.RLock()
+	defer defaultKeyringMu.RUnlock()
+	if defaultKeyring == nil {
+		return nil, ErrKeyringNotLoaded
+	}
+	return defaultKeyring, nil
+}
+
+// Encrypt encrypts the value with the loaded keyring
+func Encrypt(plaintext string) (string, error) {
+	keyring, err := GetKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.Encrypt(plaintext)
+}
+
+// Decrypt decrypts the value with the loaded keyring, the values that are not
+// encrypted do not need the keyring
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	keyring, err := GetKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.Decrypt(value)
+}
@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), KEY_SIZE)))
+}
+
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	keyring, err := NewKeyring("k1:" + testKey('a'))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	encrypted, err := keyring.Encrypt("sk-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "sk-secret") {
+		t.Fatalf("expected an encrypted value, got %s", encrypted)
+	}
+
+	decrypted, err := keyring.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decrypted != "sk-secret" {
+		t.Errorf("expected sk-secret, got %s", decrypted)
+	}
+
+	// the plaintext values and the empty values are left as they are
+	if decrypted, _ := keyring.Decrypt("sk-plain"); decrypted != "sk-plain" {
+		t.Errorf("expected the plaintext value, got %s", decrypted)
+	}
+	if encrypted, _ := keyring.Encrypt(""); encrypted != "" {
+		t.Errorf("expected the empty value to stay empty, got %s", encrypted)
+	}
+
+	// a tampered value does not decrypt
+	tampered := encrypted[:len(encrypted)-4] + "AAA="
+	if _, err := keyring.Decrypt(tampered); err == nil {
+		t.Errorf("expected an error decrypting a tampered value")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKeyring, _ := NewKeyring("k1:" + testKey('a'))
+	encrypted, _ := oldKeyring.Encrypt("sk-secret")
+
+	keyring, err := NewKeyring("k2:" + testKey('b') + ",k1:" + testKey('a'))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !keyring.NeedsReencryption(encrypted) {
+		t.Fatalf("expected the value of the old key to need re-encryption")
+	}
+	if decrypted, err := keyring.Decrypt(encrypted); err != nil || decrypted != "sk-secret" {
+		t.Fatalf("expected the old key to still decrypt, got %s, %v", decrypted, err)
+	}
+
+	reencrypted, err := keyring.Reencrypt(encrypted)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keyring.NeedsReencryption(reencrypted) {
+		t.Errorf("expected the re-encrypted value to use the active key")
+	}
+
+	// the old key can be dropped once the values are re-encrypted
+	newKeyring, _ := NewKeyring("k2:" + testKey('b'))
+	if decrypted, err := newKeyring.Decrypt(reencrypted); err != nil || decrypted != "sk-secret" {
+		t.Errorf("expected the active key to decrypt, got %s, %v", decrypted, err)
+	}
+	if _, err := newKeyring.Decrypt(encrypted); err == nil {
+		t.Errorf("expected an error decrypting with a dropped key")
+	}
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	for _, keys := range []string{
+		"",
+		"k1",
+		"k1:not-base64!",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"k1:" + testKey('a') + ",k1:" + testKey('b'),
+	} {
+		if _, err := NewKeyring(keys); err == nil {
+			t.Errorf("expected an error for %q", keys)
+		}
+	}
+}
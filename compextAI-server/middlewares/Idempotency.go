@@ -0,0 +1,205 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/burnerlee/compextAI/internal/logger"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"github.com/burnerlee/compextAI/utils/responses"
+	"gorm.io/gorm"
+)
+
+const (
+	IDEMPOTENCY_KEY_HEADER          = "Idempotency-Key"
+	IDEMPOTENCY_KEY_REPLAYED_HEADER = "Idempotent-Replayed"
+	IDEMPOTENCY_KEY_MAX_LENGTH      = 255
+	// a repeated key returns the original response within this window
+	IDEMPOTENCY_KEY_RETENTION = 24 * time.Hour
+)
+
+// responseRecorder passes the response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+type idempotencyCommittedKey struct{}
+
+// MarkIdempotentRequestCommitted records that the handler created the
+// resource of the request, e.g. the execution it enqueued. From then on the
+// response is stored for the key whatever its status, a retry must not
+// create the resource again.
+func MarkIdempotentRequestCommitted(r *http.Request) {
+	if committed, ok := r.Context().Value(idempotencyCommittedKey{}).(*bool); ok {
+		*committed = true
+	}
+}
+
+// IdempotencyMiddleware makes the request idempotent when it carries an
+// Idempotency-Key header. The first response for a key is stored per user
+// and returned as is for any repetition of the request within the retention
+// window. The key is released on a server error or a 429 if the handler did
+// not mark the request as committed, so that the client can retry it. It must run
+// after AuthMiddleware.
+func IdempotencyMiddleware(next http.HandlerFunc, db *gorm.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IDEMPOTENCY_KEY_HEADER)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > IDEMPOTENCY_KEY_MAX_LENGTH {
+			responses.Error(w, http.StatusBadRequest, fmt.Sprintf("%s must be at most %d characters", IDEMPOTENCY_KEY_HEADER, IDEMPOTENCY_KEY_MAX_LENGTH))
+			return
+		}
+
+		userID, err := utils.GetUserIDFromRequest(r)
+		if err != nil {
+			responses.Error(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			responses.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := sha256.Sum256(body)
+
+		idempotencyKey := &models.IdempotencyKey{
+			UserID:      uint(userID),
+			Key:         key,
+			Scope:       fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+			RequestHash: hex.EncodeToString(requestHash[:]),
+		}
+
+		reserved, err := reserveIdempotencyKey(db, idempotencyKey)
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !reserved {
+			replayIdempotentResponse(w, db, idempotencyKey)
+			return
+		}
+
+		committed := false
+		r = r.WithContext(context.WithValue(r.Context(), idempotencyCommittedKey{}, &committed))
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		// the server errors and the rate limited requests that changed
+		// nothing are not stored, e.g. the project and model rate limits or
+		// the budgets checked by the handler, the client may retry them with
+		// the same key
+		if !committed && (recorder.statusCode == 0 || recorder.statusCode == http.StatusTooManyRequests || recorder.statusCode >= http.StatusInternalServerError) {
+			if err := models.DeleteIdempotencyKey(db, idempotencyKey.Identifier); err != nil {
+				logger.GetLogger().Errorf("Error releasing idempotency key: %s: %v", idempotencyKey.Identifier, err)
+			}
+			return
+		}
+		statusCode := recorder.statusCode
+		if statusCode == 0 {
+			// nothing was written, the server responded with an empty 200
+			statusCode = http.StatusOK
+		}
+		if err := models.CompleteIdempotencyKey(db, idempotencyKey.Identifier, statusCode, recorder.body.String()); err != nil {
+			logger.GetLogger().Errorf("Error storing idempotent response: %s: %v", idempotencyKey.Identifier, err)
+		}
+	})
+}
+
+// reserveIdempotencyKey reserves the key, taking over a key of the same user
+// and scope that is past the retention window.
+func reserveIdempotencyKey(db *gorm.DB, idempotencyKey *models.IdempotencyKey) (bool, error) {
+	reserved, err := models.ReserveIdempotencyKey(db, idempotencyKey)
+	if err != nil || reserved {
+		return reserved, err
+	}
+
+	existingKey, err := models.GetIdempotencyKey(db, idempotencyKey.UserID, idempotencyKey.Key, idempotencyKey.Scope)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// released in the meantime
+			return models.ReserveIdempotencyKey(db, idempotencyKey)
+		}
+		return false, err
+	}
+	if time.Since(existingKey.CreatedAt) < IDEMPOTENCY_KEY_RETENTION {
+		return false, nil
+	}
+
+	if err := models.DeleteIdempotencyKey(db, existingKey.Identifier); err != nil {
+		return false, err
+	}
+	return models.ReserveIdempotencyKey(db, idempotencyKey)
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, db *gorm.DB, idempotencyKey *models.IdempotencyKey) {
+	existingKey, err := models.GetIdempotencyKey(db, idempotencyKey.UserID, idempotencyKey.Key, idempotencyKey.Scope)
+	if err != nil {
+		responses.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if existingKey.RequestHash != idempotencyKey.RequestHash {
+		responses.Error(w, http.StatusUnprocessableEntity, fmt.Sprintf("%s was already used for a different request", IDEMPOTENCY_KEY_HEADER))
+		return
+	}
+	if existingKey.Status != models.IdempotencyKeyStatus_COMPLETED {
+		responses.Error(w, http.StatusConflict, fmt.Sprintf("a request with this %s is still in progress", IDEMPOTENCY_KEY_HEADER))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(IDEMPOTENCY_KEY_REPLAYED_HEADER, "true")
+	w.WriteHeader(existingKey.ResponseStatusCode)
+	w.Write([]byte(existingKey.ResponseBody))
+}
+
+// PurgeExpiredIdempotencyKeys deletes the keys past the retention window
+// every interval until ctx is done.
+func PurgeExpiredIdempotencyKeys(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := models.DeleteExpiredIdempotencyKeys(db, time.Now().Add(-IDEMPOTENCY_KEY_RETENTION))
+		if err != nil {
+			logger.GetLogger().Errorf("Error purging expired idempotency keys: %v", err)
+		} else if purged > 0 {
+			logger.GetLogger().Infof("Purged %d expired idempotency keys", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
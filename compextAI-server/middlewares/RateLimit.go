@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/burnerlee/compextAI/internal/ratelimit"
+	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
+	"github.com/burnerlee/compextAI/utils/responses"
+)
+
+const RETRY_AFTER_HEADER = "Retry-After"
+
+// RateLimitMiddleware applies the rate limit of the user to the request. It
+// must run after AuthMiddleware.
+func RateLimitMiddleware(next http.HandlerFunc, limiter *ratelimit.Limiter) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := utils.GetUserIDFromRequest(r)
+		if err != nil {
+			responses.Error(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		if !AllowRequest(w, r, limiter, models.RateLimitScope_USER, strconv.Itoa(userID)) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AllowRequest applies the rate limit of the subject of the scope to the
+// request and writes the 429 response when it is exceeded. The handlers use
+// it for the limits that depend on the body of the request.
+func AllowRequest(w http.ResponseWriter, r *http.Request, limiter *ratelimit.Limiter, scope, subject string) bool {
+	result := limiter.Allow(r.Context(), scope, subject)
+	if result.Allowed {
+		return true
+	}
+
+	// Retry-After is in whole seconds
+	retryAfter := max(int(math.Ceil(result.RetryAfter.Seconds())), 1)
+	w.Header().Set(RETRY_AFTER_HEADER, strconv.Itoa(retryAfter))
+	responses.Error(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit of the %s exceeded, retry after %d seconds", scope, retryAfter))
+	return false
+}
@@ -1,15 +1,22 @@
 package middlewares
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/burnerlee/compextAI/internal/logger"
 	"github.com/burnerlee/compextAI/models"
+	"github.com/burnerlee/compextAI/utils"
 	"github.com/burnerlee/compextAI/utils/responses"
 	"gorm.io/gorm"
 )
 
+// AuthMiddleware authenticates the request with an api key or with the token
+// of a session started by a login.
 func AuthMiddleware(next http.HandlerFunc, db *gorm.DB) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		token := r.Header.Get("Authorization")
@@ -20,13 +27,90 @@ func AuthMiddleware(next http.HandlerFunc, db *gorm.DB) http.HandlerFunc {
 
 		token = strings.TrimPrefix(token, "Bearer ")
 
-		userID, err := models.GetUserIDByAPIToken(db, token)
+		// the session and the api key of the request are only set by this
+		// middleware
+		r.Header.Del(utils.SESSION_ID_HEADER)
+		r.Header.Del(utils.API_KEY_ID_HEADER)
+		r.Header.Del(utils.API_KEY_PROJECT_ID_HEADER)
+		r.Header.Del(utils.API_KEY_SCOPE_HEADER)
+
+		var userID uint
+		if strings.HasPrefix(token, constants.SESSION_TOKEN_PREFIX) {
+			session, err := models.GetActiveSessionByTokenHash(db, utils.HashToken(token))
+			if err != nil {
+				responses.Error(w, http.StatusUnauthorized, "Authenticated token is invalid or expired")
+				return
+			}
+			userID = session.UserID
+			r.Header.Set(utils.SESSION_ID_HEADER, session.Identifier)
+		} else {
+			apiKey, err := models.GetActiveAPIKeyByHash(db, utils.HashToken(token))
+			if err != nil {
+				responses.Error(w, http.StatusUnauthorized, "Authenticated token is invalid or expired")
+				return
+			}
+			if apiKey.Scope == models.APIKeyScope_READ_ONLY && r.Method != http.MethodGet {
+				responses.Error(w, http.StatusForbidden, "the api key is read only")
+				return
+			}
+			if err := models.TouchAPIKey(db, apiKey); err != nil {
+				logger.GetLogger().Errorf("Error recording api key use: %s: %v", apiKey.Identifier, err)
+			}
+			userID = apiKey.UserID
+			r.Header.Set(utils.API_KEY_ID_HEADER, apiKey.Identifier)
+			r.Header.Set(utils.API_KEY_SCOPE_HEADER, apiKey.Scope)
+			if apiKey.ProjectID != "" {
+				r.Header.Set(utils.API_KEY_PROJECT_ID_HEADER, apiKey.ProjectID)
+			}
+		}
+
+		r.Header.Set("X-User-ID", fmt.Sprintf("%d", userID))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminMiddleware restricts the request to the admin user. It must run after
+// AuthMiddleware.
+func AdminMiddleware(next http.HandlerFunc, db *gorm.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := utils.GetUserIDFromRequest(r)
 		if err != nil {
-			responses.Error(w, http.StatusUnauthorized, "Authenticated token is invalid")
+			responses.Error(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		user, err := models.GetUserByID(db, uint(userID))
+		if err != nil {
+			responses.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if user.Username != constants.ADMIN_USERNAME {
+			responses.Error(w, http.StatusForbidden, "only the admin can access this resource")
 			return
 		}
 
-		r.Header.Set("X-User-ID", fmt.Sprintf("%d", userID))
 		next.ServeHTTP(w, r)
 	})
 }
+
+// PurgeInactiveSessions deletes the expired and revoked sessions every
+// interval until ctx is done.
+func PurgeInactiveSessions(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := models.DeleteInactiveSessions(db, time.Now())
+		if err != nil {
+			logger.GetLogger().Errorf("Error purging inactive sessions: %v", err)
+		} else if purged > 0 {
+			logger.GetLogger().Infof("Purged %d inactive sessions", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// read only keys are limited to GET requests
+	APIKeyScope_READ_ONLY = "read_only"
+	APIKeyScope_EXECUTE   = "execute"
+)
+
+// the last use of a key is recorded at most once per interval, so that the
+// authentication does not write on every request
+const API_KEY_LAST_USED_INTERVAL = time.Minute
+
+// APIKey authenticates the api requests of a user. Only the sha256 of the key
+// is stored, the key itself is returned once when it is created.
+type APIKey struct {
+	Base
+	UserID  uint   `json:"user_id" gorm:"index"`
+	Name    string `json:"name"`
+	KeyHash string `json:"-" gorm:"uniqueIndex"`
+	// the start of the key, shown to tell the keys apart
+	KeyPrefix string `json:"key_prefix"`
+	// the only project the key can access, all the projects of the user when empty
+	ProjectID  string     `json:"project_id" gorm:"index"`
+	Scope      string     `json:"scope"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func CreateAPIKey(db *gorm.DB, apiKey *APIKey) (*APIKey, error) {
+	apiKeyID := fmt.Sprintf("%s%s", constants.API_KEY_ID_PREFIX, uuid.New().String())
+	apiKey.Identifier = apiKeyID
+	if err := db.Create(apiKey).Error; err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// GetActiveAPIKeyByHash returns the key of the hash unless it is expired or
+// revoked
+func GetActiveAPIKeyByHash(db *gorm.DB, keyHash string) (*APIKey, error) {
+	var apiKey APIKey
+	if err := db.Where("key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", keyHash, time.Now()).First(&apiKey).Error; err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+func GetUserAPIKeys(db *gorm.DB, userID uint) ([]APIKey, error) {
+	var apiKeys []APIKey
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&apiKeys).Error; err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+func GetAPIKey(db *gorm.DB, apiKeyID string) (*APIKey, error) {
+	var apiKey APIKey
+	if err := db.Where("identifier = ?", apiKeyID).First(&apiKey).Error; err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+func RevokeAPIKey(db *gorm.DB, apiKeyID string) error {
+	return db.Model(&APIKey{}).Where("identifier = ? AND revoked_at IS NULL", apiKeyID).Update("revoked_at", time.Now()).Error
+}
+
+// ExpireAPIKey moves the expiry of the key to the given time unless it
+// already expires earlier
+func ExpireAPIKey(db *gorm.DB, apiKeyID string, expiresAt time.Time) error {
+	return db.Model(&APIKey{}).Where("identifier = ? AND (expires_at IS NULL OR expires_at > ?)", apiKeyID, expiresAt).Update("expires_at", expiresAt).Error
+}
+
+// TouchAPIKey records the use of the key, unless it was recorded within the
+// last API_KEY_LAST_USED_INTERVAL
+func TouchAPIKey(db *gorm.DB, apiKey *APIKey) error {
+	now := time.Now()
+	if apiKey.LastUsedAt != nil && now.Sub(*apiKey.LastUsedAt) < API_KEY_LAST_USED_INTERVAL {
+		return nil
+	}
+	return db.Model(&APIKey{}).Where("identifier = ?", apiKey.Identifier).Update("last_used_at", now).Error
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	BudgetPeriod_DAILY   = "daily"
+	BudgetPeriod_MONTHLY = "monthly"
+
+	BudgetMetric_TOKENS = "tokens"
+	// in USD
+	BudgetMetric_COST = "cost"
+)
+
+// ProjectBudget limits the tokens or the cost of the executions of a project
+// over a day or a calendar month in UTC. New executions are refused once the
+// limit is reached.
+type ProjectBudget struct {
+	Base
+	UserID    uint   `json:"user_id"`
+	ProjectID string `json:"project_id" gorm:"index"`
+	// limits only the executions requested with the api key when set
+	APIKeyID string `json:"api_key_id"`
+	Period   string `json:"period"`
+	Metric   string `json:"metric"`
+	// limit is a reserved word in sql
+	Limit float64 `json:"limit" gorm:"column:budget_limit"`
+	// percentages of the limit that send a budget.threshold_reached webhook
+	// when the spending crosses them
+	WarningThresholds json.RawMessage `json:"warning_thresholds" gorm:"type:jsonb;default:'[]'"`
+	// the highest threshold notified in the period that started at
+	// NotifiedPeriodStart, so that every threshold is notified once per period
+	NotifiedThreshold   int       `json:"-"`
+	NotifiedPeriodStart time.Time `json:"-"`
+}
+
+func (b *ProjectBudget) GetWarningThresholds() ([]int, error) {
+	warningThresholds := make([]int, 0)
+	if len(b.WarningThresholds) == 0 {
+		return warningThresholds, nil
+	}
+	if err := json.Unmarshal(b.WarningThresholds, &warningThresholds); err != nil {
+		return nil, fmt.Errorf("error unmarshalling warning thresholds: %w", err)
+	}
+	return warningThresholds, nil
+}
+
+// GetPeriodStart returns the start of the budget period that contains now
+func (b *ProjectBudget) GetPeriodStart(now time.Time) time.Time {
+	now = now.UTC()
+	if b.Period == BudgetPeriod_DAILY {
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func CreateProjectBudget(db *gorm.DB, budget *ProjectBudget) (*ProjectBudget, error) {
+	budgetID := fmt.Sprintf("%s%s", constants.PROJECT_BUDGET_ID_PREFIX, uuid.New().String())
+	budget.Identifier = budgetID
+	if err := db.Create(budget).Error; err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
+
+func GetProjectBudgets(db *gorm.DB, projectID string) ([]ProjectBudget, error) {
+	var budgets []ProjectBudget
+	if err := db.Where("project_id = ?", projectID).Order("created_at ASC").Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	return budgets, nil
+}
+
+func GetProjectBudget(db *gorm.DB, budgetID string) (*ProjectBudget, error) {
+	var budget ProjectBudget
+	if err := db.Where("identifier = ?", budgetID).First(&budget).Error; err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func UpdateProjectBudget(db *gorm.DB, budget *ProjectBudget) error {
+	updateData := make(map[string]interface{})
+	if budget.Limit != 0 {
+		updateData["budget_limit"] = budget.Limit
+	}
+	if budget.WarningThresholds != nil {
+		updateData["warning_thresholds"] = budget.WarningThresholds
+	}
+	// the thresholds are notified again against the new limit
+	if len(updateData) > 0 {
+		updateData["notified_threshold"] = 0
+	}
+	return db.Model(&ProjectBudget{}).Where("identifier = ?", budget.Identifier).Updates(updateData).Error
+}
+
+func DeleteProjectBudget(db *gorm.DB, budgetID string) error {
+	return db.Delete(&ProjectBudget{}, "identifier = ?", budgetID).Error
+}
+
+// ClaimProjectBudgetThreshold records that the threshold was notified for the
+// period. It returns false when the threshold, or a higher one, was already
+// notified for the period, so that concurrent workers notify it only once.
+func ClaimProjectBudgetThreshold(db *gorm.DB, budgetID string, threshold int, periodStart time.Time) (bool, error) {
+	result := db.Model(&ProjectBudget{}).
+		Where("identifier = ? AND (notified_period_start IS NULL OR notified_period_start < ? OR notified_threshold < ?)", budgetID, periodStart, threshold).
+		Updates(map[string]interface{}{
+			"notified_threshold":    threshold,
+			"notified_period_start": periodStart,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetProjectSpending sums the tokens or the cost of the executions of the
+// project created since the given time, whatever their status, only the
+// executions of the api key are counted when apiKeyID is set
+func GetProjectSpending(db *gorm.DB, projectID, apiKeyID, metric string, since time.Time) (float64, error) {
+	column := "total_tokens"
+	if metric == BudgetMetric_COST {
+		column = "cost"
+	}
+
+	query := db.Model(&ThreadExecution{}).
+		Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", column)).
+		Where("project_id = ? AND created_at >= ?", projectID, since)
+	if apiKeyID != "" {
+		query = query.Where("api_key_id = ?", apiKeyID)
+	}
+
+	var spending float64
+	if err := query.Scan(&spending).Error; err != nil {
+		return 0, err
+	}
+	return spending, nil
+}
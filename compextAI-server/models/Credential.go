@@ -0,0 +1,224 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the vendors of the provider credentials
+const (
+	CredentialProvider_OPENAI    = "openai"
+	CredentialProvider_ANTHROPIC = "anthropic"
+	// an azure openai resource, the credential has an endpoint
+	CredentialProvider_AZURE = "azure"
+	// a google cloud service account, the value is its json key
+	CredentialProvider_GOOGLE = "google"
+)
+
+var CredentialProviders = []string{
+	CredentialProvider_OPENAI,
+	CredentialProvider_ANTHROPIC,
+	CredentialProvider_AZURE,
+	CredentialProvider_GOOGLE,
+}
+
+func IsValidCredentialProvider(provider string) bool {
+	for _, credentialProvider := range CredentialProviders {
+		if provider == credentialProvider {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	CredentialTestStatus_SUCCESS = "success"
+	CredentialTestStatus_FAILED  = "failed"
+)
+
+// ProviderCredential is a named credential of a vendor. The credentials of a
+// user are used by the executions of the projects they own, the credentials
+// of a project override them for the executions of the project. The
+// executions use the default credential of every provider.
+type ProviderCredential struct {
+	Base
+	// the owner of the user credentials, the creator of the project credentials
+	UserID uint `json:"user_id" gorm:"index"`
+	// set for the credentials of a project
+	ProjectID string `json:"project_id" gorm:"index"`
+	Provider  string `json:"provider"`
+	Name      string `json:"name"`
+	// encrypted with the keyring of the secrets package
+	Value string `json:"-"`
+	// the endpoint of the azure openai resource
+	Endpoint  string `json:"endpoint"`
+	IsDefault bool   `json:"is_default"`
+	// the outcome of the last connection test
+	LastTestedAt   *time.Time `json:"last_tested_at"`
+	LastTestStatus string     `json:"last_test_status"`
+	LastTestError  string     `json:"last_test_error"`
+}
+
+// credentialScope filters the credentials of the project, or the credentials
+// of the user outside the projects when the project is empty
+func credentialScope(db *gorm.DB, userID uint, projectID string) *gorm.DB {
+	if projectID != "" {
+		return db.Where("project_id = ?", projectID)
+	}
+	return db.Where("user_id = ? AND project_id = ''", userID)
+}
+
+// CreateProviderCredential creates the credential, the first credential of a
+// provider becomes its default
+func CreateProviderCredential(db *gorm.DB, credential *ProviderCredential) (*ProviderCredential, error) {
+	credentialID := fmt.Sprintf("%s%s", constants.PROVIDER_CREDENTIAL_ID_PREFIX, uuid.New().String())
+	credential.Identifier = credentialID
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := credentialScope(tx.Model(&ProviderCredential{}), credential.UserID, credential.ProjectID).
+			Where("provider = ?", credential.Provider).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			credential.IsDefault = true
+		}
+		if credential.IsDefault && count > 0 {
+			if err := credentialScope(tx.Model(&ProviderCredential{}), credential.UserID, credential.ProjectID).
+				Where("provider = ?", credential.Provider).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(credential).Error
+	}); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// GetUserProviderCredentials returns the credentials of the user outside the
+// projects
+func GetUserProviderCredentials(db *gorm.DB, userID uint) ([]ProviderCredential, error) {
+	var credentials []ProviderCredential
+	if err := credentialScope(db, userID, "").Order("provider ASC, created_at ASC").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func GetProjectProviderCredentials(db *gorm.DB, projectID string) ([]ProviderCredential, error) {
+	var credentials []ProviderCredential
+	if err := db.Where("project_id = ?", projectID).Order("provider ASC, created_at ASC").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// GetAllProviderCredentials returns the credentials of every user and project
+func GetAllProviderCredentials(db *gorm.DB) ([]ProviderCredential, error) {
+	var credentials []ProviderCredential
+	if err := db.Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func GetProviderCredential(db *gorm.DB, credentialID string) (*ProviderCredential, error) {
+	var credential ProviderCredential
+	if err := db.Where("identifier = ?", credentialID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func GetProviderCredentialByName(db *gorm.DB, userID uint, projectID, provider, name string) (*ProviderCredential, error) {
+	var credential ProviderCredential
+	if err := credentialScope(db, userID, projectID).Where("provider = ? AND name = ?", provider, name).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// GetDefaultProviderCredential returns the default credential of the
+// provider, of the project or of the user when the project is empty
+func GetDefaultProviderCredential(db *gorm.DB, userID uint, projectID, provider string) (*ProviderCredential, error) {
+	var credential ProviderCredential
+	if err := credentialScope(db, userID, projectID).Where("provider = ? AND is_default", provider).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// UpdateProviderCredential updates the name, the value and the endpoint that
+// are set. A new value invalidates the outcome of the last test.
+func UpdateProviderCredential(db *gorm.DB, credential *ProviderCredential) error {
+	updateData := make(map[string]interface{})
+	if credential.Name != "" {
+		updateData["name"] = credential.Name
+	}
+	if credential.Value != "" {
+		updateData["value"] = credential.Value
+		updateData["last_tested_at"] = nil
+		updateData["last_test_status"] = ""
+		updateData["last_test_error"] = ""
+	}
+	if credential.Endpoint != "" {
+		updateData["endpoint"] = credential.Endpoint
+	}
+	return db.Model(&ProviderCredential{}).Where("identifier = ?", credential.Identifier).Updates(updateData).Error
+}
+
+// SetDefaultProviderCredential makes the credential the default of its
+// provider
+func SetDefaultProviderCredential(db *gorm.DB, credential *ProviderCredential) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := credentialScope(tx.Model(&ProviderCredential{}), credential.UserID, credential.ProjectID).
+			Where("provider = ? AND identifier <> ?", credential.Provider, credential.Identifier).
+			Update("is_default", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ProviderCredential{}).Where("identifier = ?", credential.Identifier).Update("is_default", true).Error
+	})
+}
+
+func UpdateProviderCredentialTestResult(db *gorm.DB, credentialID, status, testError string) error {
+	return db.Model(&ProviderCredential{}).Where("identifier = ?", credentialID).Updates(map[string]interface{}{
+		"last_tested_at":   time.Now(),
+		"last_test_status": status,
+		"last_test_error":  testError,
+	}).Error
+}
+
+// UpdateProviderCredentialValue replaces the encrypted value, e.g. when its
+// data key is re-encrypted, the outcome of the last test stays valid
+func UpdateProviderCredentialValue(db *gorm.DB, credentialID, value string) error {
+	return db.Model(&ProviderCredential{}).Where("identifier = ?", credentialID).Update("value", value).Error
+}
+
+// DeleteProviderCredential deletes the credential, the oldest remaining
+// credential of the provider becomes the default when it was the default
+func DeleteProviderCredential(db *gorm.DB, credential *ProviderCredential) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&ProviderCredential{}, "identifier = ?", credential.Identifier).Error; err != nil {
+			return err
+		}
+		if !credential.IsDefault {
+			return nil
+		}
+		var next ProviderCredential
+		err := credentialScope(tx, credential.UserID, credential.ProjectID).
+			Where("provider = ?", credential.Provider).Order("created_at ASC").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&ProviderCredential{}).Where("identifier = ?", next.Identifier).Update("is_default", true).Error
+	})
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/burnerlee/compextAI/internal/secrets"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProjectEndpoint is a self hosted model server implementing the openai chat
+// completions api, e.g. ollama, vllm or the llama.cpp server. The templates
+// of the project use its models as "local/<endpoint name>/<model>".
+type ProjectEndpoint struct {
+	Base
+	UserID    uint   `json:"user_id"`
+	ProjectID string `json:"project_id" gorm:"index"`
+	Name      string `json:"name"`
+	BaseURL   string `json:"base_url"`
+	// sent as a bearer token when set, encrypted with the keyring of the
+	// secrets package
+	APIKey string `json:"-"`
+}
+
+func (e *ProjectEndpoint) GetAPIKey() (string, error) {
+	return secrets.Decrypt(e.APIKey)
+}
+
+func CreateProjectEndpoint(db *gorm.DB, endpoint *ProjectEndpoint) (*ProjectEndpoint, error) {
+	endpointID := fmt.Sprintf("%s%s", constants.PROJECT_ENDPOINT_ID_PREFIX, uuid.New().String())
+	endpoint.Identifier = endpointID
+	if err := db.Create(endpoint).Error; err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func GetProjectEndpoints(db *gorm.DB, projectID string) ([]ProjectEndpoint, error) {
+	var endpoints []ProjectEndpoint
+	if err := db.Where("project_id = ?", projectID).Order("created_at ASC").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// GetAllProjectEndpoints returns the endpoints of every project
+func GetAllProjectEndpoints(db *gorm.DB) ([]ProjectEndpoint, error) {
+	var endpoints []ProjectEndpoint
+	if err := db.Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func GetProjectEndpoint(db *gorm.DB, endpointID string) (*ProjectEndpoint, error) {
+	var endpoint ProjectEndpoint
+	if err := db.Where("identifier = ?", endpointID).First(&endpoint).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func GetProjectEndpointByName(db *gorm.DB, projectID, name string) (*ProjectEndpoint, error) {
+	var endpoint ProjectEndpoint
+	if err := db.Where("project_id = ? AND name = ?", projectID, name).First(&endpoint).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func UpdateProjectEndpoint(db *gorm.DB, endpoint *ProjectEndpoint) error {
+	updateData := make(map[string]interface{})
+	if endpoint.BaseURL != "" {
+		updateData["base_url"] = endpoint.BaseURL
+	}
+	if endpoint.APIKey != "" {
+		updateData["api_key"] = endpoint.APIKey
+	}
+	return db.Model(&ProjectEndpoint{}).Where("identifier = ?", endpoint.Identifier).Updates(updateData).Error
+}
+
+// UpdateProjectEndpointAPIKey replaces the encrypted api key, e.g. when its
+// data key is re-encrypted
+func UpdateProjectEndpointAPIKey(db *gorm.DB, endpointID, apiKey string) error {
+	return db.Model(&ProjectEndpoint{}).Where("identifier = ?", endpointID).Update("api_key", apiKey).Error
+}
+
+func DeleteProjectEndpoint(db *gorm.DB, endpointID string) error {
+	return db.Delete(&ProjectEndpoint{}, "identifier = ?", endpointID).Error
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"slices"
+	"time"
 
 	"github.com/burnerlee/compextAI/constants"
 	"github.com/google/uuid"
@@ -14,12 +15,21 @@ const (
 	ThreadExecutionStatus_IN_PROGRESS = "in_progress"
 	ThreadExecutionStatus_COMPLETED   = "completed"
 	ThreadExecutionStatus_FAILED      = "failed"
+	ThreadExecutionStatus_TIMED_OUT   = "timed_out"
+	ThreadExecutionStatus_CANCELLED   = "cancelled"
+)
+
+const (
+	TemplateClient_EXECUTOR = "executor"
+	TemplateClient_NATIVE   = "native"
 )
 
 type ThreadExecution struct {
 	Base
-	UserID                          uint                          `json:"user_id"`
-	ProjectID                       string                        `json:"project_id" gorm:"index"`
+	UserID    uint   `json:"user_id"`
+	ProjectID string `json:"project_id" gorm:"index"`
+	// the api key that requested the execution, empty for the dashboard
+	APIKeyID                        string                        `json:"api_key_id" gorm:"index"`
 	ThreadID                        string                        `json:"thread_id"`
 	Thread                          Thread                        `json:"thread" gorm:"foreignKey:ThreadID;references:Identifier"`
 	ThreadExecutionParamsTemplateID string                        `json:"thread_execution_params_template_id"`
@@ -38,6 +48,36 @@ type ThreadExecution struct {
 	// this is displayed in the UI and can be used for filtering
 	Metadata json.RawMessage `json:"metadata" gorm:"type:jsonb;default:'{}'"`
 	Tools    json.RawMessage `json:"tools" gorm:"type:jsonb;default:'{}'"`
+	// notified in addition to the project webhooks once the execution finishes
+	WebhookURL string `json:"webhook_url"`
+	// every call made to the executor, earlier entries failed and were retried
+	Attempts json.RawMessage `json:"attempts" gorm:"type:jsonb;default:'[]'"`
+	// the template and model that produced the response, they differ from the
+	// requested template when a fallback template served the execution
+	ServedTemplateID string `json:"served_template_id"`
+	ServedModel      string `json:"served_model"`
+	// tool calls requested by the model in the response, see ToolCall
+	ToolCalls json.RawMessage `json:"tool_calls" gorm:"type:jsonb;default:'[]'"`
+	// token usage of the calls made for the execution, normalized across the
+	// vendors, and its cost in USD
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
+	ReasoningTokens  int     `json:"reasoning_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// ExecutionAttempt records a single call to the executor for an execution
+type ExecutionAttempt struct {
+	Attempt    int       `json:"attempt"`
+	TemplateID string    `json:"template_id"`
+	Model      string    `json:"model"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	// stores the attempt duration in milliseconds
+	Duration int64 `json:"duration"`
 }
 
 // ThreadExecutionParams are the parameters for executing a thread
@@ -49,6 +89,20 @@ type ThreadExecutionParams struct {
 	Environment string                        `json:"environment"`
 	TemplateID  string                        `json:"template_id"`
 	Template    ThreadExecutionParamsTemplate `json:"template" gorm:"foreignKey:TemplateID;references:Identifier"`
+	// ordered list of template ids that are tried in turn when the template
+	// fails with a retryable error
+	FallbackTemplateIDs json.RawMessage `json:"fallback_template_ids" gorm:"type:jsonb;default:'[]'"`
+}
+
+func (p *ThreadExecutionParams) GetFallbackTemplateIDs() ([]string, error) {
+	fallbackTemplateIDs := make([]string, 0)
+	if len(p.FallbackTemplateIDs) == 0 {
+		return fallbackTemplateIDs, nil
+	}
+	if err := json.Unmarshal(p.FallbackTemplateIDs, &fallbackTemplateIDs); err != nil {
+		return nil, fmt.Errorf("error unmarshalling fallback template ids: %w", err)
+	}
+	return fallbackTemplateIDs, nil
 }
 
 type ThreadExecutionParamsTemplate struct {
@@ -66,6 +120,14 @@ type ThreadExecutionParamsTemplate struct {
 	ResponseFormat      json.RawMessage `json:"response_format" gorm:"type:jsonb;default:'{}'"`
 	SystemPrompt        string          `json:"system_prompt"`
 	UseLiteLLM          bool            `json:"use_litellm" gorm:"default:true"`
+	// retry policy for transient executor failures, the defaults apply when unset
+	MaxAttempts int `json:"max_attempts"`
+	// backoff before the first retry in seconds, doubled for every retry
+	RetryBackoff         int             `json:"retry_backoff"`
+	RetryableStatusCodes json.RawMessage `json:"retryable_status_codes" gorm:"type:jsonb;default:'[]'"`
+	// executor sends the execution to the python executor, native calls the
+	// vendor api from the server itself
+	Client string `json:"client" gorm:"default:'executor'"`
 }
 
 func CreateThreadExecution(db *gorm.DB, threadExecution *ThreadExecution) (*ThreadExecution, error) {
@@ -79,7 +141,7 @@ func CreateThreadExecution(db *gorm.DB, threadExecution *ThreadExecution) (*Thre
 	return threadExecution, nil
 }
 
-func UpdateThreadExecution(db *gorm.DB, threadExecution *ThreadExecution) error {
+func threadExecutionUpdateData(threadExecution *ThreadExecution) map[string]interface{} {
 	updateData := make(map[string]interface{})
 	if threadExecution.Status != "" {
 		updateData["status"] = threadExecution.Status
@@ -105,7 +167,54 @@ func UpdateThreadExecution(db *gorm.DB, threadExecution *ThreadExecution) error
 	if threadExecution.ExecutionTime != 0 {
 		updateData["execution_time"] = threadExecution.ExecutionTime
 	}
-	return db.Model(&ThreadExecution{}).Where("identifier = ?", threadExecution.Identifier).Updates(updateData).Error
+	if threadExecution.Attempts != nil {
+		updateData["attempts"] = threadExecution.Attempts
+	}
+	if threadExecution.ServedTemplateID != "" {
+		updateData["served_template_id"] = threadExecution.ServedTemplateID
+	}
+	if threadExecution.ServedModel != "" {
+		updateData["served_model"] = threadExecution.ServedModel
+	}
+	if threadExecution.ToolCalls != nil {
+		updateData["tool_calls"] = threadExecution.ToolCalls
+	}
+	if threadExecution.PromptTokens != 0 {
+		updateData["prompt_tokens"] = threadExecution.PromptTokens
+	}
+	if threadExecution.CompletionTokens != 0 {
+		updateData["completion_tokens"] = threadExecution.CompletionTokens
+	}
+	if threadExecution.CachedTokens != 0 {
+		updateData["cached_tokens"] = threadExecution.CachedTokens
+	}
+	if threadExecution.ReasoningTokens != 0 {
+		updateData["reasoning_tokens"] = threadExecution.ReasoningTokens
+	}
+	if threadExecution.TotalTokens != 0 {
+		updateData["total_tokens"] = threadExecution.TotalTokens
+	}
+	if threadExecution.Cost != 0 {
+		updateData["cost"] = threadExecution.Cost
+	}
+	return updateData
+}
+
+func UpdateThreadExecution(db *gorm.DB, threadExecution *ThreadExecution) error {
+	return db.Model(&ThreadExecution{}).Where("identifier = ?", threadExecution.Identifier).Updates(threadExecutionUpdateData(threadExecution)).Error
+}
+
+// FinishThreadExecution moves the execution to its terminal status only while
+// it is in progress. It reports whether the execution was moved, a cancel or
+// another worker may have finished it first.
+func FinishThreadExecution(db *gorm.DB, threadExecution *ThreadExecution) (bool, error) {
+	result := db.Model(&ThreadExecution{}).
+		Where("identifier = ? AND status = ?", threadExecution.Identifier, ThreadExecutionStatus_IN_PROGRESS).
+		Updates(threadExecutionUpdateData(threadExecution))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
 }
 
 func GetThreadExecutionByID(db *gorm.DB, executionID string) (*ThreadExecution, error) {
@@ -116,6 +225,22 @@ func GetThreadExecutionByID(db *gorm.DB, executionID string) (*ThreadExecution,
 	return &threadExecution, nil
 }
 
+func GetThreadExecutionStatus(db *gorm.DB, executionID string) (string, error) {
+	var threadExecution ThreadExecution
+	if err := db.Select("status").Where("identifier = ?", executionID).First(&threadExecution).Error; err != nil {
+		return "", err
+	}
+	return threadExecution.Status, nil
+}
+
+func GetThreadExecutionsByStatus(db *gorm.DB, status string) ([]ThreadExecution, error) {
+	var threadExecutions []ThreadExecution
+	if err := db.Where("status = ?", status).Preload("ThreadExecutionParamsTemplate").Find(&threadExecutions).Error; err != nil {
+		return nil, err
+	}
+	return threadExecutions, nil
+}
+
 func GetThreadExecutionParamsByID(db *gorm.DB, threadExecutionParamsID string) (*ThreadExecutionParams, error) {
 	var threadExecutionParams ThreadExecutionParams
 	if err := db.Where("identifier = ?", threadExecutionParamsID).Preload("Template").First(&threadExecutionParams).Error; err != nil {
@@ -124,11 +249,11 @@ func GetThreadExecutionParamsByID(db *gorm.DB, threadExecutionParamsID string) (
 	return &threadExecutionParams, nil
 }
 
-func GetAllThreadExecutionParams(db *gorm.DB, userID uint, projectID string) ([]ThreadExecutionParams, error) {
+func GetAllThreadExecutionParams(db *gorm.DB, projectID string) ([]ThreadExecutionParams, error) {
 	var threadExecutionParams []ThreadExecutionParams
 	// preload the template
 	// this request is too slow, need to optimize
-	if err := db.Where("user_id = ? AND project_id = ?", userID, projectID).Preload("Template").Find(&threadExecutionParams).Error; err != nil {
+	if err := db.Where("project_id = ?", projectID).Preload("Template").Find(&threadExecutionParams).Error; err != nil {
 		return nil, err
 	}
 	return threadExecutionParams, nil
@@ -148,9 +273,9 @@ func DeleteThreadExecutionParams(db *gorm.DB, threadExecutionParamsID string) er
 	return db.Delete(&ThreadExecutionParams{}, "identifier = ?", threadExecutionParamsID).Error
 }
 
-func GetThreadExecutionParamsByUserIDAndNameAndEnvironment(db *gorm.DB, userID uint, name, environment, projectID string) (*ThreadExecutionParams, error) {
+func GetThreadExecutionParamsByNameAndEnvironment(db *gorm.DB, name, environment, projectID string) (*ThreadExecutionParams, error) {
 	var threadExecutionParams ThreadExecutionParams
-	if err := db.Where("user_id = ? AND name = ? AND environment = ? AND project_id = ?", userID, name, environment, projectID).Preload("Template").First(&threadExecutionParams).Error; err != nil {
+	if err := db.Where("name = ? AND environment = ? AND project_id = ?", name, environment, projectID).Preload("Template").First(&threadExecutionParams).Error; err != nil {
 		return nil, err
 	}
 	return &threadExecutionParams, nil
@@ -210,13 +335,25 @@ func UpdateThreadExecutionParamsTemplate(db *gorm.DB, threadExecutionParamsTempl
 	if threadExecutionParamsTemplate.SystemPrompt != "" {
 		updateData["system_prompt"] = threadExecutionParamsTemplate.SystemPrompt
 	}
+	if threadExecutionParamsTemplate.MaxAttempts != 0 {
+		updateData["max_attempts"] = threadExecutionParamsTemplate.MaxAttempts
+	}
+	if threadExecutionParamsTemplate.RetryBackoff != 0 {
+		updateData["retry_backoff"] = threadExecutionParamsTemplate.RetryBackoff
+	}
+	if threadExecutionParamsTemplate.RetryableStatusCodes != nil {
+		updateData["retryable_status_codes"] = threadExecutionParamsTemplate.RetryableStatusCodes
+	}
+	if threadExecutionParamsTemplate.Client != "" {
+		updateData["client"] = threadExecutionParamsTemplate.Client
+	}
 
 	return db.Model(&ThreadExecutionParamsTemplate{}).Where("identifier = ?", threadExecutionParamsTemplate.Identifier).Updates(updateData).Error
 }
 
-func GetAllThreadExecutionParamsTemplates(db *gorm.DB, userID uint, projectID string) ([]ThreadExecutionParamsTemplate, error) {
+func GetAllThreadExecutionParamsTemplates(db *gorm.DB, projectID string) ([]ThreadExecutionParamsTemplate, error) {
 	var threadExecutionParamsTemplates []ThreadExecutionParamsTemplate
-	if err := db.Where("user_id = ? AND project_id = ?", userID, projectID).Find(&threadExecutionParamsTemplates).Error; err != nil {
+	if err := db.Where("project_id = ?", projectID).Find(&threadExecutionParamsTemplates).Error; err != nil {
 		return nil, err
 	}
 	return threadExecutionParamsTemplates, nil
@@ -234,6 +371,10 @@ func UpdateThreadExecutionParamsTemplateID(db *gorm.DB, threadExecutionParamsID,
 	return db.Model(&ThreadExecutionParams{}).Where("identifier = ?", threadExecutionParamsID).Update("template_id", templateID).Error
 }
 
+func UpdateThreadExecutionParamsFallbackTemplateIDs(db *gorm.DB, threadExecutionParamsID string, fallbackTemplateIDs json.RawMessage) error {
+	return db.Model(&ThreadExecutionParams{}).Where("identifier = ?", threadExecutionParamsID).Update("fallback_template_ids", fallbackTemplateIDs).Error
+}
+
 func GetAllThreadExecutionsByProjectID(db *gorm.DB, projectID string, searchQuery string, searchParamsMap map[string]string, page, limit int) ([]ThreadExecution, int64, error) {
 
 	offset := (page - 1) * limit
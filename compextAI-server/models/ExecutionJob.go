@@ -0,0 +1,185 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ExecutionJobStatus_QUEUED    = "queued"
+	ExecutionJobStatus_RUNNING   = "running"
+	ExecutionJobStatus_COMPLETED = "completed"
+	ExecutionJobStatus_FAILED    = "failed"
+	ExecutionJobStatus_CANCELLED = "cancelled"
+)
+
+// ExecutionJob is a persisted unit of work for the execution worker pool.
+// Every thread execution gets a job, so pending executions survive restarts
+// and are picked up by whichever worker is free.
+type ExecutionJob struct {
+	Base
+	ThreadExecutionID string          `json:"thread_execution_id" gorm:"index"`
+	Status            string          `json:"status" gorm:"index"`
+	Payload           json.RawMessage `json:"payload" gorm:"type:jsonb;default:'{}'"`
+	Attempts          int             `json:"attempts"`
+	LastError         string          `json:"last_error"`
+	StartedAt         *time.Time      `json:"started_at"`
+	FinishedAt        *time.Time      `json:"finished_at"`
+	// the worker running the job renews the lease, a running job whose lease
+	// expired has lost its worker and is requeued
+	LeaseExpiresAt *time.Time `json:"lease_expires_at" gorm:"index"`
+}
+
+func CreateExecutionJob(db *gorm.DB, job *ExecutionJob) (*ExecutionJob, error) {
+	jobIDUniqueIdentifier := uuid.New().String()
+	jobID := fmt.Sprintf("%s%s", constants.EXECUTION_JOB_ID_PREFIX, jobIDUniqueIdentifier)
+	job.Identifier = jobID
+	if job.Status == "" {
+		job.Status = ExecutionJobStatus_QUEUED
+	}
+	if err := db.Create(job).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ClaimNextExecutionJob locks the oldest queued job with SKIP LOCKED, so that
+// concurrent workers (in this process or in other replicas) never pick up the
+// same job, and marks it as running with a lease of the given duration. It
+// returns gorm.ErrRecordNotFound when the queue is empty.
+func ClaimNextExecutionJob(db *gorm.DB, leaseDuration time.Duration) (*ExecutionJob, error) {
+	var job ExecutionJob
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", ExecutionJobStatus_QUEUED).
+			Order("id ASC").
+			First(&job).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		job.Status = ExecutionJobStatus_RUNNING
+		job.Attempts++
+		job.StartedAt = &now
+		leaseExpiresAt := now.Add(leaseDuration)
+		job.LeaseExpiresAt = &leaseExpiresAt
+		return tx.Model(&ExecutionJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":           job.Status,
+			"attempts":         job.Attempts,
+			"started_at":       job.StartedAt,
+			"lease_expires_at": job.LeaseExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FinishExecutionJob moves the queued or running job to its terminal status,
+// the jobs already finished, e.g. by the recovery, are left unchanged
+func FinishExecutionJob(db *gorm.DB, jobID string, status string, jobErr error) error {
+	updateData := map[string]interface{}{
+		"status":      status,
+		"finished_at": time.Now(),
+	}
+	if jobErr != nil {
+		updateData["last_error"] = jobErr.Error()
+	}
+	return db.Model(&ExecutionJob{}).
+		Where("identifier = ? AND status IN ?", jobID, []string{ExecutionJobStatus_QUEUED, ExecutionJobStatus_RUNNING}).
+		Updates(updateData).Error
+}
+
+// ReleaseExecutionJob puts the running job back on the queue, e.g. when the
+// worker stops before it is done with it
+func ReleaseExecutionJob(db *gorm.DB, jobID string) error {
+	return db.Model(&ExecutionJob{}).
+		Where("identifier = ? AND status = ?", jobID, ExecutionJobStatus_RUNNING).
+		Updates(map[string]interface{}{
+			"status":           ExecutionJobStatus_QUEUED,
+			"started_at":       nil,
+			"lease_expires_at": nil,
+		}).Error
+}
+
+func GetExecutionJobByThreadExecutionID(db *gorm.DB, threadExecutionID string) (*ExecutionJob, error) {
+	var job ExecutionJob
+	if err := db.Where("thread_execution_id = ?", threadExecutionID).Order("id DESC").First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RenewExecutionJobLease extends the lease of the running job. It reports
+// whether the job is still running, a job whose lease expired may have been
+// requeued in the meantime.
+func RenewExecutionJobLease(db *gorm.DB, jobID string, leaseDuration time.Duration) (bool, error) {
+	result := db.Model(&ExecutionJob{}).
+		Where("identifier = ? AND status = ?", jobID, ExecutionJobStatus_RUNNING).
+		Update("lease_expires_at", time.Now().Add(leaseDuration))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// expiredExecutionJobs filters the running jobs whose lease expired. The jobs
+// claimed before the leases have none, they are expired once they started
+// before the legacy cutoff.
+func expiredExecutionJobs(db *gorm.DB, now, legacyCutoff time.Time) *gorm.DB {
+	return db.Where("status = ? AND (lease_expires_at < ? OR (lease_expires_at IS NULL AND started_at < ?))",
+		ExecutionJobStatus_RUNNING, now, legacyCutoff)
+}
+
+func GetExpiredExecutionJobs(db *gorm.DB, now, legacyCutoff time.Time) ([]ExecutionJob, error) {
+	var jobs []ExecutionJob
+	if err := expiredExecutionJobs(db, now, legacyCutoff).Order("id ASC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// RequeueExpiredExecutionJob puts a running job whose lease expired back on
+// the queue, keeping its payload and attempt count. It reports whether the
+// job was requeued, another replica may have requeued it first.
+func RequeueExpiredExecutionJob(db *gorm.DB, jobID string, now, legacyCutoff time.Time) (bool, error) {
+	result := expiredExecutionJobs(db.Model(&ExecutionJob{}), now, legacyCutoff).
+		Where("identifier = ?", jobID).
+		Updates(map[string]interface{}{
+			"status":           ExecutionJobStatus_QUEUED,
+			"started_at":       nil,
+			"finished_at":      nil,
+			"lease_expires_at": nil,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FinishExpiredExecutionJob finishes a running job whose lease expired. It
+// reports whether the job was finished, another replica may have handled it
+// first.
+func FinishExpiredExecutionJob(db *gorm.DB, jobID string, status string, jobErr error, now, legacyCutoff time.Time) (bool, error) {
+	updateData := map[string]interface{}{
+		"status":      status,
+		"finished_at": now,
+	}
+	if jobErr != nil {
+		updateData["last_error"] = jobErr.Error()
+	}
+	result := expiredExecutionJobs(db.Model(&ExecutionJob{}), now, legacyCutoff).
+		Where("identifier = ?", jobID).
+		Updates(updateData)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	IdempotencyKeyStatus_IN_PROGRESS = "in_progress"
+	IdempotencyKeyStatus_COMPLETED   = "completed"
+)
+
+// IdempotencyKey stores the response of a request made with an
+// Idempotency-Key header, so that a retry of the request gets the original
+// response instead of repeating its side effects.
+type IdempotencyKey struct {
+	Base
+	UserID uint   `json:"user_id" gorm:"uniqueIndex:idx_idempotency_keys_user_key_scope"`
+	Key    string `json:"key" gorm:"uniqueIndex:idx_idempotency_keys_user_key_scope"`
+	// method and path of the request, the same key may be reused on another endpoint
+	Scope string `json:"scope" gorm:"uniqueIndex:idx_idempotency_keys_user_key_scope"`
+	// sha256 of the request body, a key must not be reused for another request
+	RequestHash        string `json:"request_hash"`
+	Status             string `json:"status"`
+	ResponseStatusCode int    `json:"response_status_code"`
+	ResponseBody       string `json:"response_body"`
+}
+
+// ReserveIdempotencyKey inserts the key in progress. It returns false when
+// the key already exists for the user and scope.
+func ReserveIdempotencyKey(db *gorm.DB, idempotencyKey *IdempotencyKey) (bool, error) {
+	idempotencyKeyID := fmt.Sprintf("%s%s", constants.IDEMPOTENCY_KEY_ID_PREFIX, uuid.New().String())
+	idempotencyKey.Identifier = idempotencyKeyID
+	idempotencyKey.Status = IdempotencyKeyStatus_IN_PROGRESS
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(idempotencyKey)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func GetIdempotencyKey(db *gorm.DB, userID uint, key, scope string) (*IdempotencyKey, error) {
+	var idempotencyKey IdempotencyKey
+	if err := db.Where("user_id = ? AND key = ? AND scope = ?", userID, key, scope).First(&idempotencyKey).Error; err != nil {
+		return nil, err
+	}
+	return &idempotencyKey, nil
+}
+
+func CompleteIdempotencyKey(db *gorm.DB, identifier string, statusCode int, body string) error {
+	return db.Model(&IdempotencyKey{}).Where("identifier = ?", identifier).Updates(map[string]interface{}{
+		"status":               IdempotencyKeyStatus_COMPLETED,
+		"response_status_code": statusCode,
+		"response_body":        body,
+	}).Error
+}
+
+// DeleteIdempotencyKey removes the key for good, so that it can be reserved
+// again. The unique index would otherwise still cover the soft deleted row.
+func DeleteIdempotencyKey(db *gorm.DB, identifier string) error {
+	return db.Unscoped().Delete(&IdempotencyKey{}, "identifier = ?", identifier).Error
+}
+
+func DeleteExpiredIdempotencyKeys(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Unscoped().Delete(&IdempotencyKey{}, "created_at < ?", before)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Invitation invites the user with the email to a project or to an
+// organization with a role. Only the sha256 of the token is stored, the token
+// is returned once to the inviter, who hands it to the invitee.
+type Invitation struct {
+	Base
+	// exactly one of the organization and the project is set
+	OrganizationID string     `json:"organization_id" gorm:"index"`
+	ProjectID      string     `json:"project_id" gorm:"index"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	InvitedByID    uint       `json:"invited_by_id"`
+	TokenHash      string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedByID   *uint      `json:"accepted_by_id"`
+}
+
+func CreateInvitation(db *gorm.DB, invitation *Invitation) (*Invitation, error) {
+	invitation.Identifier = fmt.Sprintf("%s%s", constants.INVITATION_ID_PREFIX, uuid.New().String())
+	if err := db.Create(invitation).Error; err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// GetPendingInvitationByTokenHash returns the invitation of the token unless
+// it is accepted or expired
+func GetPendingInvitationByTokenHash(db *gorm.DB, tokenHash string) (*Invitation, error) {
+	var invitation Invitation
+	if err := db.Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", tokenHash, time.Now()).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func GetInvitation(db *gorm.DB, invitationID string) (*Invitation, error) {
+	var invitation Invitation
+	if err := db.Where("identifier = ?", invitationID).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func GetProjectInvitations(db *gorm.DB, projectID string) ([]Invitation, error) {
+	var invitations []Invitation
+	if err := db.Where("project_id = ?", projectID).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func GetOrganizationInvitations(db *gorm.DB, organizationID string) ([]Invitation, error) {
+	var invitations []Invitation
+	if err := db.Where("organization_id = ?", organizationID).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// AcceptInvitation marks the invitation as accepted by the user. It returns
+// false when the invitation was accepted in the meantime, so that a token is
+// used once.
+func AcceptInvitation(db *gorm.DB, invitationID string, userID uint) (bool, error) {
+	result := db.Model(&Invitation{}).
+		Where("identifier = ? AND accepted_at IS NULL", invitationID).
+		Updates(map[string]interface{}{
+			"accepted_at":    time.Now(),
+			"accepted_by_id": userID,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func DeleteInvitation(db *gorm.DB, invitationID string) error {
+	return db.Delete(&Invitation{}, "identifier = ?", invitationID).Error
+}
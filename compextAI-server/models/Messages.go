@@ -12,7 +12,7 @@ import (
 type Message struct {
 	Base
 	ContentMap   json.RawMessage `json:"content_map" gorm:"not null;type:jsonb;default:'{}'"`
-	Content      string          `json:"content""`
+	Content      string          `json:"content"`
 	ToolCallID   string          `json:"tool_call_id"`
 	Role         string          `json:"role" gorm:"not null"`
 	ThreadID     string          `json:"thread_id" gorm:"not null;index"`
@@ -20,10 +20,6 @@ type Message struct {
 	Metadata     json.RawMessage `json:"metadata" gorm:"type:jsonb;default:'{}'"`
 	ToolCalls    json.RawMessage `json:"tool_calls" gorm:"type:jsonb;default:'{}'"`
 	FunctionCall json.RawMessage `json:"function_call" gorm:"type:jsonb;default:'{}'"`
-
-	// Implement support for tool calls and function calls later on
-	// ToolCalls []ToolCall        `json:"tool_calls"`
-	// FunctionCall FunctionCall      `json:"function_call"`
 }
 
 func GetAllMessages(db *gorm.DB, threadID string) ([]*Message, error) {
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Organization groups the projects of a team, its members get their role in
+// every project of the organization
+type Organization struct {
+	Base
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// OrganizationMember gives a user a role in every project of an organization,
+// the owners also manage the organization
+type OrganizationMember struct {
+	Base
+	OrganizationID string `json:"organization_id" gorm:"uniqueIndex:idx_organization_member"`
+	UserID         uint   `json:"user_id" gorm:"uniqueIndex:idx_organization_member"`
+	User           User   `json:"user" gorm:"foreignKey:UserID"`
+	Role           string `json:"role"`
+}
+
+// CreateOrganization creates the organization with the user as its owner
+func CreateOrganization(db *gorm.DB, organization *Organization, ownerID uint) error {
+	organization.Identifier = fmt.Sprintf("%s%s", constants.ORGANIZATION_ID_PREFIX, uuid.New().String())
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		return AddOrganizationMember(tx, &OrganizationMember{
+			OrganizationID: organization.Identifier,
+			UserID:         ownerID,
+			Role:           Role_OWNER,
+		})
+	})
+}
+
+func GetOrganization(db *gorm.DB, organizationID string) (*Organization, error) {
+	var organization Organization
+	if err := db.Where("identifier = ?", organizationID).First(&organization).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// GetUserOrganizations returns the organizations the user is a member of
+func GetUserOrganizations(db *gorm.DB, userID uint) ([]Organization, error) {
+	var organizations []Organization
+	if err := db.Where("identifier IN (?)", db.Model(&OrganizationMember{}).Select("organization_id").Where("user_id = ?", userID)).
+		Order("created_at ASC").Find(&organizations).Error; err != nil {
+		return nil, err
+	}
+	return organizations, nil
+}
+
+func UpdateOrganization(db *gorm.DB, organization *Organization) error {
+	updateData := make(map[string]interface{})
+	if organization.Name != "" {
+		updateData["name"] = organization.Name
+	}
+	if organization.Description != "" {
+		updateData["description"] = organization.Description
+	}
+	return db.Model(&Organization{}).Where("identifier = ?", organization.Identifier).Updates(updateData).Error
+}
+
+// DeleteOrganization deletes the organization and its memberships
+func DeleteOrganization(db *gorm.DB, organizationID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&OrganizationMember{}, "organization_id = ?", organizationID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{}, "identifier = ?", organizationID).Error
+	})
+}
+
+// AddOrganizationMember adds the user to the organization, or changes its
+// role when it is already a member
+func AddOrganizationMember(db *gorm.DB, member *OrganizationMember) error {
+	result := db.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", member.OrganizationID, member.UserID).
+		Update("role", member.Role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	member.Identifier = fmt.Sprintf("%s%s", constants.ORGANIZATION_MEMBER_ID_PREFIX, uuid.New().String())
+	return db.Create(member).Error
+}
+
+func GetOrganizationMembers(db *gorm.DB, organizationID string) ([]OrganizationMember, error) {
+	var members []OrganizationMember
+	if err := db.Where("organization_id = ?", organizationID).Preload("User").Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func GetOrganizationMember(db *gorm.DB, organizationID string, userID uint) (*OrganizationMember, error) {
+	var member OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func UpdateOrganizationMemberRole(db *gorm.DB, organizationID string, userID uint, role string) error {
+	return db.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", organizationID, userID).Update("role", role).Error
+}
+
+// RemoveOrganizationMember deletes the membership for good, so that the user
+// can be invited again
+func RemoveOrganizationMember(db *gorm.DB, organizationID string, userID uint) error {
+	return db.Unscoped().Delete(&OrganizationMember{}, "organization_id = ? AND user_id = ?", organizationID, userID).Error
+}
+
+// CountOrganizationOwners is used to keep at least one owner in every
+// organization
+func CountOrganizationOwners(db *gorm.DB, organizationID string) (int64, error) {
+	var count int64
+	if err := db.Model(&OrganizationMember{}).Where("organization_id = ? AND role = ?", organizationID, Role_OWNER).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func CountOrganizationProjects(db *gorm.DB, organizationID string) (int64, error) {
+	var count int64
+	if err := db.Model(&Project{}).Where("organization_id = ?", organizationID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -1,18 +1,25 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/burnerlee/compextAI/constants"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Project struct {
 	Base
-	UserID      uint   `json:"user_id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	// the owner of the project
+	UserID uint `json:"user_id"`
+	// the members of the organization get their role in the project
+	OrganizationID string `json:"organization_id" gorm:"index"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	// used to sign the webhook deliveries of the project
+	WebhookSecret string `json:"-"`
 }
 
 func CreateProject(db *gorm.DB, project *Project) error {
@@ -30,17 +37,45 @@ func GetProject(db *gorm.DB, projectID string) (*Project, error) {
 	return &project, nil
 }
 
+// accessibleProjects filters the projects the user owns or is a member of,
+// directly or through their organization
+func accessibleProjects(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Where("user_id = ? OR identifier IN (?) OR organization_id IN (?)",
+		userID,
+		db.Model(&ProjectMember{}).Select("project_id").Where("user_id = ?", userID),
+		db.Model(&OrganizationMember{}).Select("organization_id").Where("user_id = ?", userID))
+}
+
+// ErrAmbiguousProjectName is returned when the name matches several projects
+// shared with the user and none of their own
+var ErrAmbiguousProjectName = errors.New("several projects shared with you have this name, use the project id")
+
+// GetProjectByName returns the project with the name among the projects the
+// user can access. The names are only unique per owner, the project of the
+// user comes first, a name shared by several projects of other owners is
+// ambiguous.
 func GetProjectByName(db *gorm.DB, name string, userID uint) (*Project, error) {
-	var project Project
-	if err := db.First(&project, "name = ? AND user_id = ?", name, userID).Error; err != nil {
+	var projects []Project
+	if err := accessibleProjects(db, userID).
+		Where("name = ?", name).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "user_id = ? DESC, created_at ASC", Vars: []interface{}{userID}, WithoutParentheses: true}}).
+		Limit(2).
+		Find(&projects).Error; err != nil {
 		return nil, err
 	}
-	return &project, nil
+	if len(projects) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if projects[0].UserID != userID && len(projects) > 1 {
+		return nil, ErrAmbiguousProjectName
+	}
+	return &projects[0], nil
 }
 
+// GetAllProjects returns the projects the user can access
 func GetAllProjects(db *gorm.DB, userID uint) ([]Project, error) {
 	var projects []Project
-	if err := db.Where("user_id = ?", userID).Find(&projects).Error; err != nil {
+	if err := accessibleProjects(db, userID).Order("created_at ASC").Find(&projects).Error; err != nil {
 		return nil, err
 	}
 	return projects, nil
@@ -60,3 +95,11 @@ func UpdateProject(db *gorm.DB, project *Project) error {
 	}
 	return db.Model(&Project{}).Where("identifier = ?", project.Identifier).Updates(updateData).Error
 }
+
+// SetProjectWebhookSecretIfEmpty stores the webhook secret unless the project
+// already has one, so concurrent callers end up with the same secret.
+func SetProjectWebhookSecretIfEmpty(db *gorm.DB, projectID string, webhookSecret string) error {
+	return db.Model(&Project{}).
+		Where("identifier = ? AND (webhook_secret = '' OR webhook_secret IS NULL)", projectID).
+		Update("webhook_secret", webhookSecret).Error
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// the roles of the members of the projects and the organizations, every role
+// can do what the roles below it can do
+const (
+	// manages the members and the invitations, deletes the project
+	Role_OWNER = "owner"
+	// manages the templates, the tools, the endpoints, the webhooks and the budgets
+	Role_EDITOR = "editor"
+	// executes the threads and manages the threads and the messages
+	Role_EXECUTOR = "executor"
+	// reads everything
+	Role_VIEWER = "viewer"
+)
+
+var roleRanks = map[string]int{
+	Role_VIEWER:   1,
+	Role_EXECUTOR: 2,
+	Role_EDITOR:   3,
+	Role_OWNER:    4,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAllows reports whether the role can do what the required role can do,
+// the empty role allows nothing
+func RoleAllows(role, requiredRole string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[requiredRole]
+}
+
+// ProjectMember gives a user a role in a project, in addition to the owner of
+// the project and the members of its organization
+type ProjectMember struct {
+	Base
+	ProjectID string `json:"project_id" gorm:"uniqueIndex:idx_project_member"`
+	UserID    uint   `json:"user_id" gorm:"uniqueIndex:idx_project_member"`
+	User      User   `json:"user" gorm:"foreignKey:UserID"`
+	Role      string `json:"role"`
+}
+
+// AddProjectMember adds the user to the project, or changes its role when it
+// is already a member
+func AddProjectMember(db *gorm.DB, member *ProjectMember) error {
+	result := db.Model(&ProjectMember{}).
+		Where("project_id = ? AND user_id = ?", member.ProjectID, member.UserID).
+		Update("role", member.Role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	member.Identifier = fmt.Sprintf("%s%s", constants.PROJECT_MEMBER_ID_PREFIX, uuid.New().String())
+	return db.Create(member).Error
+}
+
+func GetProjectMembers(db *gorm.DB, projectID string) ([]ProjectMember, error) {
+	var members []ProjectMember
+	if err := db.Where("project_id = ?", projectID).Preload("User").Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func GetProjectMember(db *gorm.DB, projectID string, userID uint) (*ProjectMember, error) {
+	var member ProjectMember
+	if err := db.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func UpdateProjectMemberRole(db *gorm.DB, projectID string, userID uint, role string) error {
+	return db.Model(&ProjectMember{}).Where("project_id = ? AND user_id = ?", projectID, userID).Update("role", role).Error
+}
+
+// RemoveProjectMember deletes the membership for good, so that the user can
+// be invited again
+func RemoveProjectMember(db *gorm.DB, projectID string, userID uint) error {
+	return db.Unscoped().Delete(&ProjectMember{}, "project_id = ? AND user_id = ?", projectID, userID).Error
+}
+
+// GetProjectRole returns the highest role of the user in the project among
+// the ownership of the project, the membership of the project and the
+// membership of its organization. It returns an empty role when the user has
+// no access to the project.
+func GetProjectRole(db *gorm.DB, project *Project, userID uint) (string, error) {
+	if project.UserID == userID {
+		return Role_OWNER, nil
+	}
+
+	role := ""
+	member, err := GetProjectMember(db, project.Identifier, userID)
+	if err == nil {
+		role = member.Role
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	if project.OrganizationID != "" {
+		organizationMember, err := GetOrganizationMember(db, project.OrganizationID, userID)
+		if err == nil {
+			if roleRanks[organizationMember.Role] > roleRanks[role] {
+				role = organizationMember.Role
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+	}
+	return role, nil
+}
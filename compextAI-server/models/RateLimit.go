@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	RateLimitScope_USER    = "user"
+	RateLimitScope_PROJECT = "project"
+	RateLimitScope_MODEL   = "model"
+)
+
+// RateLimit overrides the default execution rate limit of a scope. An empty
+// subject applies to every user, project or model of the scope, otherwise the
+// subject is the user id, the project id or the model of the template.
+type RateLimit struct {
+	Base
+	Scope   string `json:"scope" gorm:"uniqueIndex:idx_rate_limits_scope_subject"`
+	Subject string `json:"subject" gorm:"uniqueIndex:idx_rate_limits_scope_subject"`
+	// 0 disables the limit
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// the requests allowed at once, defaults to the requests per minute
+	Burst int `json:"burst"`
+}
+
+// SetRateLimit creates the rate limit of the scope and subject or replaces
+// the existing one.
+func SetRateLimit(db *gorm.DB, rateLimit *RateLimit) (*RateLimit, error) {
+	rateLimitID := fmt.Sprintf("%s%s", constants.RATE_LIMIT_ID_PREFIX, uuid.New().String())
+	rateLimit.Identifier = rateLimitID
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"requests_per_minute", "burst", "updated_at"}),
+	}).Create(rateLimit).Error; err != nil {
+		return nil, err
+	}
+	return GetRateLimit(db, rateLimit.Scope, rateLimit.Subject)
+}
+
+func GetRateLimits(db *gorm.DB) ([]RateLimit, error) {
+	var rateLimits []RateLimit
+	if err := db.Order("scope ASC, subject ASC").Find(&rateLimits).Error; err != nil {
+		return nil, err
+	}
+	return rateLimits, nil
+}
+
+func GetRateLimit(db *gorm.DB, scope, subject string) (*RateLimit, error) {
+	var rateLimit RateLimit
+	if err := db.Where("scope = ? AND subject = ?", scope, subject).First(&rateLimit).Error; err != nil {
+		return nil, err
+	}
+	return &rateLimit, nil
+}
+
+func GetRateLimitByID(db *gorm.DB, rateLimitID string) (*RateLimit, error) {
+	var rateLimit RateLimit
+	if err := db.Where("identifier = ?", rateLimitID).First(&rateLimit).Error; err != nil {
+		return nil, err
+	}
+	return &rateLimit, nil
+}
+
+func DeleteRateLimit(db *gorm.DB, rateLimitID string) error {
+	// hard delete, a soft deleted row would keep the scope and subject taken
+	return db.Unscoped().Delete(&RateLimit{}, "identifier = ?", rateLimitID).Error
+}
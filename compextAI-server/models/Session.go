@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session authenticates the dashboard of a user after a login. Unlike the api
+// token it expires and can be revoked. Only the sha256 of the token is stored.
+type Session struct {
+	Base
+	UserID    uint       `json:"user_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	UserAgent string     `json:"user_agent"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+func CreateSession(db *gorm.DB, session *Session) (*Session, error) {
+	sessionID := fmt.Sprintf("%s%s", constants.SESSION_ID_PREFIX, uuid.New().String())
+	session.Identifier = sessionID
+	if err := db.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetActiveSessionByTokenHash returns the session of the token unless it is
+// expired or revoked
+func GetActiveSessionByTokenHash(db *gorm.DB, tokenHash string) (*Session, error) {
+	var session Session
+	if err := db.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", tokenHash, time.Now()).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func GetActiveUserSessions(db *gorm.DB, userID uint) ([]Session, error) {
+	var sessions []Session
+	if err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func GetSession(db *gorm.DB, sessionID string) (*Session, error) {
+	var session Session
+	if err := db.Where("identifier = ?", sessionID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func RevokeSession(db *gorm.DB, sessionID string) error {
+	return db.Model(&Session{}).Where("identifier = ? AND revoked_at IS NULL", sessionID).Update("revoked_at", time.Now()).Error
+}
+
+func RevokeUserSessions(db *gorm.DB, userID uint) error {
+	return db.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now()).Error
+}
+
+// DeleteInactiveSessions deletes the sessions that expired or were revoked
+// before the given time
+func DeleteInactiveSessions(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Unscoped().Delete(&Session{}, "expires_at < ? OR revoked_at < ?", before, before)
+	return result.RowsAffected, result.Error
+}
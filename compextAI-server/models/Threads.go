@@ -19,11 +19,11 @@ type Thread struct {
 	Metadata  json.RawMessage `json:"metadata" gorm:"type:jsonb;default:'{}'"`
 }
 
-func GetAllThreads(db *gorm.DB, userID uint, projectID string, searchQuery string, searchFiltersMap map[string]string, page, limit int) ([]Thread, int64, error) {
+func GetAllThreads(db *gorm.DB, projectID string, searchQuery string, searchFiltersMap map[string]string, page, limit int) ([]Thread, int64, error) {
 	offset := (page - 1) * limit
 	var total int64
 
-	query := db.Model(&Thread{}).Where("user_id = ? AND project_id = ?", userID, projectID)
+	query := db.Model(&Thread{}).Where("project_id = ?", projectID)
 
 	if searchQuery != "" {
 		query = query.Where("title LIKE ? OR identifier LIKE ?", "%"+searchQuery+"%", "%"+searchQuery+"%")
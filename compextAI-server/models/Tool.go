@@ -1,9 +1,121 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
 
 type ExecutionTool struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	InputSchema json.RawMessage `json:"input_schema"`
 }
+
+// ToolCall is a call to a tool requested by the model. Tool calls are stored
+// in the openai format whatever the provider of the model.
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name string `json:"name"`
+	// json encoded arguments of the call
+	Arguments string `json:"arguments"`
+}
+
+// ParseToolCalls decodes the tool calls of a message or an execution, empty
+// values decode to no tool calls.
+func ParseToolCalls(toolCallsJson json.RawMessage) ([]ToolCall, error) {
+	toolCalls := make([]ToolCall, 0)
+	if len(toolCallsJson) == 0 || string(toolCallsJson) == "null" || string(toolCallsJson) == "{}" {
+		return toolCalls, nil
+	}
+	if err := json.Unmarshal(toolCallsJson, &toolCalls); err != nil {
+		return nil, fmt.Errorf("error unmarshalling tool calls: %w", err)
+	}
+	return toolCalls, nil
+}
+
+// ProjectTool is a tool served over http that the server calls on behalf of
+// the model when an execution runs its tools.
+type ProjectTool struct {
+	Base
+	UserID      uint            `json:"user_id"`
+	ProjectID   string          `json:"project_id" gorm:"index"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema" gorm:"type:jsonb;default:'{}'"`
+	// the tool call arguments are POSTed to this url, the response body is
+	// returned to the model
+	URL string `json:"url"`
+	// request timeout in seconds
+	Timeout int `json:"timeout"`
+}
+
+func (t *ProjectTool) ExecutionTool() *ExecutionTool {
+	return &ExecutionTool{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: t.InputSchema,
+	}
+}
+
+func CreateProjectTool(db *gorm.DB, tool *ProjectTool) (*ProjectTool, error) {
+	toolID := fmt.Sprintf("%s%s", constants.PROJECT_TOOL_ID_PREFIX, uuid.New().String())
+	tool.Identifier = toolID
+	if err := db.Create(tool).Error; err != nil {
+		return nil, err
+	}
+	return tool, nil
+}
+
+func GetProjectTools(db *gorm.DB, projectID string) ([]ProjectTool, error) {
+	var tools []ProjectTool
+	if err := db.Where("project_id = ?", projectID).Order("created_at ASC").Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	return tools, nil
+}
+
+func GetProjectTool(db *gorm.DB, toolID string) (*ProjectTool, error) {
+	var tool ProjectTool
+	if err := db.Where("identifier = ?", toolID).First(&tool).Error; err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+func GetProjectToolByName(db *gorm.DB, projectID, name string) (*ProjectTool, error) {
+	var tool ProjectTool
+	if err := db.Where("project_id = ? AND name = ?", projectID, name).First(&tool).Error; err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+func UpdateProjectTool(db *gorm.DB, tool *ProjectTool) error {
+	updateData := make(map[string]interface{})
+	if tool.Description != "" {
+		updateData["description"] = tool.Description
+	}
+	if tool.InputSchema != nil {
+		updateData["input_schema"] = tool.InputSchema
+	}
+	if tool.URL != "" {
+		updateData["url"] = tool.URL
+	}
+	if tool.Timeout != 0 {
+		updateData["timeout"] = tool.Timeout
+	}
+	return db.Model(&ProjectTool{}).Where("identifier = ?", tool.Identifier).Updates(updateData).Error
+}
+
+func DeleteProjectTool(db *gorm.DB, toolID string) error {
+	return db.Delete(&ProjectTool{}, "identifier = ?", toolID).Error
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UsageAggregate is the token usage and the cost of the completed executions
+// of a project for a model and a day
+type UsageAggregate struct {
+	ProjectID        string    `json:"project_id"`
+	Model            string    `json:"model"`
+	Day              time.Time `json:"day"`
+	Executions       int64     `json:"executions"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	CachedTokens     int64     `json:"cached_tokens"`
+	ReasoningTokens  int64     `json:"reasoning_tokens"`
+	TotalTokens      int64     `json:"total_tokens"`
+	Cost             float64   `json:"cost"`
+}
+
+// GetUsageAggregates sums the usage of the completed executions of the projects
+// created in [from, to), by project, served model and day in UTC. The model
+// filter is ignored when empty.
+func GetUsageAggregates(db *gorm.DB, projectIDs []string, model string, from, to time.Time) ([]UsageAggregate, error) {
+	query := db.Model(&ThreadExecution{}).
+		Select(`project_id, served_model AS model, date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, count(*) AS executions,
+			sum(prompt_tokens) AS prompt_tokens, sum(completion_tokens) AS completion_tokens, sum(cached_tokens) AS cached_tokens,
+			sum(reasoning_tokens) AS reasoning_tokens, sum(total_tokens) AS total_tokens, sum(cost) AS cost`).
+		Where("project_id IN ? AND status = ? AND created_at >= ? AND created_at < ?", projectIDs, ThreadExecutionStatus_COMPLETED, from, to)
+	if model != "" {
+		query = query.Where("served_model = ?", model)
+	}
+
+	var usageAggregates []UsageAggregate
+	if err := query.Group("project_id, served_model, day").Order("day DESC, project_id, served_model").Scan(&usageAggregates).Error; err != nil {
+		return nil, err
+	}
+	return usageAggregates, nil
+}
@@ -3,28 +3,63 @@ package models
 import (
 	"encoding/json"
 
+	"github.com/burnerlee/compextAI/internal/secrets"
 	"gorm.io/gorm"
 )
 
 type User struct {
 	Base
-	Username                  string          `json:"username" gorm:"unique"`
-	Email                     string          `json:"email" gorm:"unique"`
-	Password                  string          `json:"password" gorm:"not null"`
-	APIToken                  string          `json:"api_token" gorm:"unique"`
-	OpenAIKey                 string          `json:"openai_key" gorm:"column:openai_key"`
-	AnthropicKey              string          `json:"anthropic_key" gorm:"column:anthropic_key"`
-	AzureKey                  string          `json:"azure_key" gorm:"column:azure_key"`
-	AzureEndpoint             string          `json:"azure_endpoint" gorm:"column:azure_endpoint"`
-	GoogleServiceAccountCreds json.RawMessage `json:"google_service_account_creds" gorm:"column:google_service_account_creds; type:jsonb"`
-}
-
-func GetUserIDByAPIToken(db *gorm.DB, token string) (uint, error) {
-	var user User
-	if err := db.Where("api_token = ?", token).First(&user).Error; err != nil {
-		return 0, err
+	Username string `json:"username" gorm:"unique"`
+	Email    string `json:"email" gorm:"unique"`
+	Password string `json:"-" gorm:"not null"`
+	// the single token of the users created before the api keys, the
+	// migrations move it to the api keys and leave it null
+	APIToken *string `json:"-" gorm:"unique"`
+	// the provider credentials of the users created before the provider
+	// credentials, the migrations move them. The executions set them to the
+	// credentials of the project, encrypted with the keyring of the secrets
+	// package, the getters decrypt them.
+	OpenAIKey     string `json:"-" gorm:"column:openai_key"`
+	AnthropicKey  string `json:"-" gorm:"column:anthropic_key"`
+	AzureKey      string `json:"-" gorm:"column:azure_key"`
+	AzureEndpoint string `json:"-" gorm:"column:azure_endpoint"`
+	// the encrypted credentials are stored as a json string
+	GoogleServiceAccountCreds json.RawMessage `json:"-" gorm:"column:google_service_account_creds; type:jsonb"`
+}
+
+func (u *User) GetOpenAIKey() (string, error) {
+	return secrets.Decrypt(u.OpenAIKey)
+}
+
+func (u *User) GetAnthropicKey() (string, error) {
+	return secrets.Decrypt(u.AnthropicKey)
+}
+
+func (u *User) GetAzureKey() (string, error) {
+	return secrets.Decrypt(u.AzureKey)
+}
+
+// GetEncryptedGoogleServiceAccountCreds returns the encrypted value of the
+// google credentials, the credentials stored before the encryption are a json
+// object and are returned as they are
+func (u *User) GetEncryptedGoogleServiceAccountCreds() string {
+	var encryptedCreds string
+	if err := json.Unmarshal(u.GoogleServiceAccountCreds, &encryptedCreds); err == nil && secrets.IsEncrypted(encryptedCreds) {
+		return encryptedCreds
+	}
+	return string(u.GoogleServiceAccountCreds)
+}
+
+func (u *User) GetGoogleServiceAccountCreds() (json.RawMessage, error) {
+	encryptedCreds := u.GetEncryptedGoogleServiceAccountCreds()
+	if !secrets.IsEncrypted(encryptedCreds) {
+		return u.GoogleServiceAccountCreds, nil
+	}
+	creds, err := secrets.Decrypt(encryptedCreds)
+	if err != nil {
+		return nil, err
 	}
-	return user.ID, nil
+	return json.RawMessage(creds), nil
 }
 
 func CreateUser(db *gorm.DB, user *User) error {
@@ -47,14 +82,54 @@ func GetUserByID(db *gorm.DB, id uint) (*User, error) {
 	return &user, nil
 }
 
-func UpdateUser(db *gorm.DB, user *User) error {
-	updateData := make(map[string]interface{})
-	if user.AnthropicKey != "" {
-		updateData["anthropic_key"] = user.AnthropicKey
+// ClearUserCredentials removes the credentials of the user once they are
+// moved to the provider credentials
+func ClearUserCredentials(db *gorm.DB, userID uint) error {
+	return db.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"openai_key":                   "",
+		"anthropic_key":                "",
+		"azure_key":                    "",
+		"azure_endpoint":               "",
+		"google_service_account_creds": gorm.Expr("NULL"),
+	}).Error
+}
+
+func UpdateUserPassword(db *gorm.DB, userID uint, passwordHash string) error {
+	return db.Model(&User{}).Where("id = ?", userID).Update("password", passwordHash).Error
+}
+
+// GetUsersWithPlaintextPasswords returns the users whose password is not a
+// bcrypt hash yet
+func GetUsersWithPlaintextPasswords(db *gorm.DB) ([]User, error) {
+	var users []User
+	if err := db.Where("password <> '' AND password NOT LIKE ?", "$2_$%").Find(&users).Error; err != nil {
+		return nil, err
 	}
-	if user.OpenAIKey != "" {
-		updateData["openai_key"] = user.OpenAIKey
+	return users, nil
+}
+
+// GetUsersWithCredentials returns the users with at least one provider
+// credential
+func GetUsersWithCredentials(db *gorm.DB) ([]User, error) {
+	var users []User
+	if err := db.Where("openai_key <> '' OR anthropic_key <> '' OR azure_key <> '' OR google_service_account_creds IS NOT NULL").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUsersWithLegacyAPITokens returns the users whose api token has not been
+// moved to the api keys yet
+func GetUsersWithLegacyAPITokens(db *gorm.DB) ([]User, error) {
+	var users []User
+	if err := db.Where("api_token IS NOT NULL AND api_token <> ''").Find(&users).Error; err != nil {
+		return nil, err
 	}
+	return users, nil
+}
 
-	return db.Model(user).Updates(updateData).Error
+// ClearUserAPIToken removes the api token once it is moved to the api keys,
+// null keeps the unique index satisfied for every user
+func ClearUserAPIToken(db *gorm.DB, userID uint) error {
+	return db.Model(&User{}).Where("id = ?", userID).Update("api_token", gorm.Expr("NULL")).Error
 }
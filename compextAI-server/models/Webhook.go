@@ -0,0 +1,147 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/burnerlee/compextAI/constants"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	WebhookDeliveryStatus_PENDING   = "pending"
+	WebhookDeliveryStatus_DELIVERED = "delivered"
+	WebhookDeliveryStatus_FAILED    = "failed"
+)
+
+// ProjectWebhook is a default webhook of a project, it is notified of every
+// finished execution of the project.
+type ProjectWebhook struct {
+	Base
+	UserID    uint   `json:"user_id"`
+	ProjectID string `json:"project_id" gorm:"index"`
+	URL       string `json:"url"`
+}
+
+// WebhookDelivery logs a single webhook notification and its attempts. It
+// also acts as the queue of the webhook dispatcher.
+type WebhookDelivery struct {
+	Base
+	ProjectID          string          `json:"project_id" gorm:"index"`
+	ThreadExecutionID  string          `json:"thread_execution_id" gorm:"index"`
+	WebhookID          string          `json:"webhook_id"`
+	URL                string          `json:"url"`
+	Event              string          `json:"event"`
+	Payload            json.RawMessage `json:"payload" gorm:"type:jsonb;default:'{}'"`
+	Status             string          `json:"status" gorm:"index"`
+	Attempts           int             `json:"attempts"`
+	NextAttemptAt      time.Time       `json:"next_attempt_at" gorm:"index"`
+	ResponseStatusCode int             `json:"response_status_code"`
+	ResponseBody       string          `json:"response_body"`
+	LastError          string          `json:"last_error"`
+	DeliveredAt        *time.Time      `json:"delivered_at"`
+}
+
+func CreateProjectWebhook(db *gorm.DB, webhook *ProjectWebhook) (*ProjectWebhook, error) {
+	webhookID := fmt.Sprintf("%s%s", constants.PROJECT_WEBHOOK_ID_PREFIX, uuid.New().String())
+	webhook.Identifier = webhookID
+	if err := db.Create(webhook).Error; err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func GetProjectWebhooks(db *gorm.DB, projectID string) ([]ProjectWebhook, error) {
+	var webhooks []ProjectWebhook
+	if err := db.Where("project_id = ?", projectID).Order("created_at ASC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func GetProjectWebhook(db *gorm.DB, webhookID string) (*ProjectWebhook, error) {
+	var webhook ProjectWebhook
+	if err := db.Where("identifier = ?", webhookID).First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func DeleteProjectWebhook(db *gorm.DB, webhookID string) error {
+	return db.Delete(&ProjectWebhook{}, "identifier = ?", webhookID).Error
+}
+
+func CreateWebhookDelivery(db *gorm.DB, delivery *WebhookDelivery) (*WebhookDelivery, error) {
+	deliveryID := fmt.Sprintf("%s%s", constants.WEBHOOK_DELIVERY_ID_PREFIX, uuid.New().String())
+	delivery.Identifier = deliveryID
+	if delivery.Status == "" {
+		delivery.Status = WebhookDeliveryStatus_PENDING
+	}
+	if delivery.NextAttemptAt.IsZero() {
+		delivery.NextAttemptAt = time.Now()
+	}
+	if err := db.Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ClaimNextWebhookDelivery locks the oldest pending delivery that is due with
+// SKIP LOCKED and pushes its next attempt into the future, so that no other
+// dispatcher picks it up while it is being sent. It returns
+// gorm.ErrRecordNotFound when there is nothing to send.
+func ClaimNextWebhookDelivery(db *gorm.DB, lease time.Duration) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", WebhookDeliveryStatus_PENDING, time.Now()).
+			Order("next_attempt_at ASC").
+			First(&delivery).Error; err != nil {
+			return err
+		}
+
+		delivery.Attempts++
+		return tx.Model(&WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": time.Now().Add(lease),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func UpdateWebhookDeliveryAttempt(db *gorm.DB, delivery *WebhookDelivery) error {
+	return db.Model(&WebhookDelivery{}).Where("identifier = ?", delivery.Identifier).Updates(map[string]interface{}{
+		"status":               delivery.Status,
+		"next_attempt_at":      delivery.NextAttemptAt,
+		"response_status_code": delivery.ResponseStatusCode,
+		"response_body":        delivery.ResponseBody,
+		"last_error":           delivery.LastError,
+		"delivered_at":         delivery.DeliveredAt,
+	}).Error
+}
+
+func GetWebhookDeliveriesByProjectID(db *gorm.DB, projectID string, threadExecutionID string, page, limit int) ([]WebhookDelivery, int64, error) {
+	offset := (page - 1) * limit
+	var total int64
+
+	query := db.Model(&WebhookDelivery{}).Where("project_id = ?", projectID)
+	if threadExecutionID != "" {
+		query = query.Where("thread_execution_id = ?", threadExecutionID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var deliveries []WebhookDelivery
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyPasswordHash is compared against when the user does not exist, so that
+// the response time does not reveal which usernames are taken
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("compext-dummy-password"), bcrypt.DefaultCost)
+
+func HashPassword(password string) (string, error) {
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		return "", errors.New("password must be at most 72 bytes long")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHash reports whether the stored password is a bcrypt hash, the
+// passwords of the users created before hashing were stored in plaintext
+func IsPasswordHash(storedPassword string) bool {
+	return strings.HasPrefix(storedPassword, "$2a$") || strings.HasPrefix(storedPassword, "$2b$") || strings.HasPrefix(storedPassword, "$2y$")
+}
+
+// CheckPassword compares the password with the stored one, which may still
+// be in plaintext
+func CheckPassword(storedPassword, password string) bool {
+	if storedPassword == "" {
+		CheckMissingUserPassword(password)
+		return false
+	}
+	if !IsPasswordHash(storedPassword) {
+		return subtle.ConstantTimeCompare([]byte(storedPassword), []byte(password)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password)) == nil
+}
+
+// CheckMissingUserPassword takes as long as CheckPassword for a user that
+// does not exist
+func CheckMissingUserPassword(password string) {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
+// HashToken returns the sha256 of the token, the tokens are random so they are
+// stored as a plain digest
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
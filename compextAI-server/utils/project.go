@@ -7,13 +7,29 @@ import (
 	"gorm.io/gorm"
 )
 
-func GetProjectIDFromName(db *gorm.DB, name string, userID uint) (string, error) {
-	project, err := models.GetProjectByName(db, name, userID)
-	if err != nil {
-		return "", err
+// GetProjectIDFromName resolves the name among the projects the user can
+// access and checks that the user has at least the role in the project. A
+// request scoped to a project resolves the name to that project only, the
+// user may own another project with the same name.
+func GetProjectIDFromName(db *gorm.DB, name string, userID uint, projectScope, role string) (string, error) {
+	var project *models.Project
+	var err error
+	if projectScope != "" {
+		project, err = models.GetProject(db, projectScope)
+		if err != nil {
+			return "", err
+		}
+		if project.Name != name {
+			return "", errors.New("you do not have access to this project")
+		}
+	} else {
+		project, err = models.GetProjectByName(db, name, userID)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	hasAccess, err := CheckProjectAccess(db, project.Identifier, userID)
+	hasAccess, err := CheckProjectAccess(db, project.Identifier, userID, projectScope, role)
 	if err != nil {
 		return "", err
 	}
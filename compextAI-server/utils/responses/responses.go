@@ -2,6 +2,7 @@ package responses
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 )
 
@@ -14,3 +15,26 @@ func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 func Error(w http.ResponseWriter, statusCode int, message string) {
 	JSON(w, statusCode, map[string]interface{}{"error": message})
 }
+
+// SSEHeaders prepares the response for server-sent events.
+func SSEHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+}
+
+// SSE writes a single server-sent event and flushes it to the client.
+func SSE(w http.ResponseWriter, event string, data interface{}) error {
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, dataJson); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
@@ -9,6 +9,18 @@ import (
 	"gorm.io/gorm"
 )
 
+// set by the auth middleware, along with X-User-ID
+const (
+	// the session of the requests authenticated with a session token
+	SESSION_ID_HEADER = "X-Session-ID"
+	// the api key of the requests authenticated with an api key
+	API_KEY_ID_HEADER = "X-API-Key-ID"
+	// the project the api key of the request is scoped to, if any
+	API_KEY_PROJECT_ID_HEADER = "X-API-Key-Project-ID"
+	// the scope of the api key of the request
+	API_KEY_SCOPE_HEADER = "X-API-Key-Scope"
+)
+
 func GetUserIDFromRequest(r *http.Request) (int, error) {
 	userID := r.Header.Get("X-User-ID")
 	if userID == "" {
@@ -23,47 +35,100 @@ func GetUserIDFromRequest(r *http.Request) (int, error) {
 	return userIDInt, nil
 }
 
-func CheckThreadAccess(db *gorm.DB, threadID string, userID uint) (bool, error) {
+// GetProjectScopeFromRequest returns the only project the request can access,
+// or an empty string when it can access all the projects of the user
+func GetProjectScopeFromRequest(r *http.Request) string {
+	return r.Header.Get(API_KEY_PROJECT_ID_HEADER)
+}
+
+// IsReadOnlyRequest reports whether the request is authenticated with a read
+// only api key, which must not read the secrets of the project either
+func IsReadOnlyRequest(r *http.Request) bool {
+	return r.Header.Get(API_KEY_SCOPE_HEADER) == models.APIKeyScope_READ_ONLY
+}
+
+// InProjectScope reports whether a request with the project scope can access
+// the project
+func InProjectScope(projectScope, projectID string) bool {
+	return projectScope == "" || projectScope == projectID
+}
+
+// CheckProjectRole reports whether the user has at least the role in the
+// project and the project is in the scope of the request. The resources
+// created before the projects have no project, only their owner can access
+// them.
+func CheckProjectRole(db *gorm.DB, projectID string, ownerID, userID uint, projectScope, role string) (bool, error) {
+	if !InProjectScope(projectScope, projectID) {
+		return false, nil
+	}
+	if projectID == "" {
+		return ownerID == userID, nil
+	}
+
+	project, err := models.GetProject(db, projectID)
+	if err != nil {
+		return false, err
+	}
+	projectRole, err := models.GetProjectRole(db, project, userID)
+	if err != nil {
+		return false, err
+	}
+	return models.RoleAllows(projectRole, role), nil
+}
+
+func CheckThreadAccess(db *gorm.DB, threadID string, userID uint, projectScope, role string) (bool, error) {
 	thread, err := models.GetThread(db, threadID)
 	if err != nil {
 		return false, err
 	}
 
-	return thread.UserID == userID, nil
+	return CheckProjectRole(db, thread.ProjectID, thread.UserID, userID, projectScope, role)
 }
 
-func CheckMessageAccess(db *gorm.DB, messageID string, userID uint) (bool, error) {
+func CheckMessageAccess(db *gorm.DB, messageID string, userID uint, projectScope, role string) (bool, error) {
 	message, err := models.GetMessage(db, messageID)
 	if err != nil {
 		return false, err
 	}
 
-	return message.Thread.UserID == userID, nil
+	return CheckProjectRole(db, message.Thread.ProjectID, message.Thread.UserID, userID, projectScope, role)
 }
 
-func CheckThreadExecutionAccess(db *gorm.DB, executionID string, userID uint) (bool, error) {
+func CheckThreadExecutionAccess(db *gorm.DB, executionID string, userID uint, projectScope, role string) (bool, error) {
 	threadExecution, err := models.GetThreadExecutionByID(db, executionID)
 	if err != nil {
 		return false, err
 	}
 
-	return threadExecution.UserID == userID, nil
+	return CheckProjectRole(db, threadExecution.ProjectID, threadExecution.UserID, userID, projectScope, role)
 }
 
-func CheckThreadExecutionParamsTemplateAccess(db *gorm.DB, templateID string, userID uint) (bool, error) {
+func CheckThreadExecutionParamsTemplateAccess(db *gorm.DB, templateID string, userID uint, projectScope, role string) (bool, error) {
 	threadExecutionParamsTemplate, err := models.GetThreadExecutionParamsTemplateByID(db, templateID)
 	if err != nil {
 		return false, err
 	}
 
-	return threadExecutionParamsTemplate.UserID == userID, nil
+	return CheckProjectRole(db, threadExecutionParamsTemplate.ProjectID, threadExecutionParamsTemplate.UserID, userID, projectScope, role)
 }
 
-func CheckProjectAccess(db *gorm.DB, projectID string, userID uint) (bool, error) {
+func CheckProjectAccess(db *gorm.DB, projectID string, userID uint, projectScope, role string) (bool, error) {
 	project, err := models.GetProject(db, projectID)
 	if err != nil {
 		return false, err
 	}
 
-	return project.UserID == userID, nil
+	return CheckProjectRole(db, project.Identifier, project.UserID, userID, projectScope, role)
+}
+
+func CheckOrganizationAccess(db *gorm.DB, organizationID string, userID uint, role string) (bool, error) {
+	member, err := models.GetOrganizationMember(db, organizationID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return models.RoleAllows(member.Role, role), nil
 }
@@ -53,8 +53,8 @@ def wait_for_completion(thread_execution_id: str):
     )
     if status.status == "completed":
         return
-    if status.status == "failed":
-        raise Exception("Thread execution failed")
+    if status.status in ("failed", "timed_out", "cancelled"):
+        raise Exception(f"Thread execution {status.status}")
     time.sleep(1)
     wait_for_completion(thread_execution_id)
 